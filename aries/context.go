@@ -16,6 +16,7 @@ type C struct {
 	User      string
 	UserLevel int // 0 for normal user. 0 with empty User is anonymous.
 	UserData  any
+	Scopes    []string // Permission scopes granted to the user's token.
 
 	Req     *http.Request
 	Resp    http.ResponseWriter
@@ -0,0 +1,45 @@
+package aries
+
+import (
+	"strings"
+
+	"shanhu.io/std/errcode"
+)
+
+// ScopeGranted checks if the want scope is granted by the list of granted
+// scopes. A granted scope "*" grants all scopes, and a granted scope that
+// ends with ":*", like "keys:*", grants all scopes with the same prefix,
+// like "keys:read" and "keys:write".
+func ScopeGranted(granted []string, want string) bool {
+	for _, s := range granted {
+		if s == want || s == "*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(s, "*"); ok {
+			if strings.HasSuffix(prefix, ":") &&
+				strings.HasPrefix(want, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasScope checks if the context's scopes grant the given scope.
+func (c *C) HasScope(scope string) bool {
+	return ScopeGranted(c.Scopes, scope)
+}
+
+// RequireScope wraps a service so that it only serves signed-in users whose
+// tokens are granted with the given scope.
+func RequireScope(scope string, s Service) Func {
+	return func(c *C) error {
+		if c.User == "" {
+			return NeedSignIn
+		}
+		if !c.HasScope(scope) {
+			return errcode.Unauthorizedf("scope %q required", scope)
+		}
+		return s.Serve(c)
+	}
+}
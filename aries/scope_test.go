@@ -0,0 +1,47 @@
+package aries
+
+import (
+	"testing"
+
+	"shanhu.io/std/errcode"
+)
+
+func TestScopeGranted(t *testing.T) {
+	for _, test := range []struct {
+		granted []string
+		want    string
+		ok      bool
+	}{
+		{nil, "keys:write", false},
+		{[]string{"keys:write"}, "keys:write", true},
+		{[]string{"keys:read"}, "keys:write", false},
+		{[]string{"keys:*"}, "keys:write", true},
+		{[]string{"keys:*"}, "keysx:write", false},
+		{[]string{"k*"}, "keys:write", false},
+		{[]string{"*"}, "keys:write", true},
+		{[]string{"roles:read", "keys:write"}, "keys:write", true},
+	} {
+		got := ScopeGranted(test.granted, test.want)
+		if got != test.ok {
+			t.Errorf(
+				"ScopeGranted(%q, %q), got %t, want %t",
+				test.granted, test.want, got, test.ok,
+			)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	f := RequireScope("keys:write", StringFunc("ok"))
+
+	c := new(C)
+	if err := f(c); err != NeedSignIn {
+		t.Errorf("anonymous user got %v, want NeedSignIn", err)
+	}
+
+	c.User = "robot"
+	c.Scopes = []string{"keys:read"}
+	if err := f(c); !errcode.IsUnauthorized(err) {
+		t.Errorf("user without scope got %v, want unauthorized", err)
+	}
+}
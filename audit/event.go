@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Actions of events.
+const (
+	SignIn       = "signin"
+	MFAVerify    = "mfa.verify"
+	Exchange     = "exchange"
+	RoleCreate   = "role.create"
+	RoleRemove   = "role.remove"
+	RoleDisable  = "role.disable"
+	RoleEnable   = "role.enable"
+	RolePassCode = "role.passcode"
+	RoleSetup    = "role.setup"
+
+	RoleRemoveKey  = "role.remove-key"
+	RoleSetScopes  = "role.set-scopes"
+	RoleSetContact = "role.set-contact"
+)
+
+// Results of events.
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Event is an entry in the audit log.
+type Event struct {
+	Seq  uint64
+	Time int64 // Unix nanoseconds.
+
+	Actor    string `json:",omitempty"` // Who performs the action.
+	Action   string
+	Target   string `json:",omitempty"` // Who or what is acted on.
+	RemoteIP string `json:",omitempty"`
+	Detail   string `json:",omitempty"`
+
+	Result string
+	Error  string `json:",omitempty"`
+
+	// Prev is the hash of the previous event. Hash is the hash of this
+	// event, which covers Prev, so that the events form a chain, and an
+	// event cannot be changed or removed without breaking the chain.
+	Prev string `json:",omitempty"`
+	Hash string
+}
+
+func (e *Event) hash() (string, error) {
+	cp := *e
+	cp.Hash = ""
+	bs, err := json.Marshal(&cp)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (e *Event) setResult(err error) {
+	if err == nil {
+		e.Result = ResultOK
+		return
+	}
+	e.Result = ResultError
+	e.Error = err.Error()
+}
+
+// Event keys are fixed-width hex of the sequence number, so that they sort
+// in the order of the sequence in an ordered KV.
+func eventKey(seq uint64) string { return fmt.Sprintf("%016x", seq) }
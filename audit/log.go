@@ -0,0 +1,181 @@
+// Package audit provides an append-only audit log of authentication and
+// role changes. Events are chained by hashes for tamper evidence.
+package audit
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// Config is the configuration for creating an audit log.
+type Config struct {
+	Now func() time.Time
+}
+
+// Log is an audit log that saves events in an ordered key-value store.
+type Log struct {
+	kv  *pisces.KV
+	now func() time.Time
+
+	mu   sync.Mutex
+	head *Event // Last appended event; nil when not loaded.
+}
+
+// New creates a new audit log that saves events in kv, which must be an
+// ordered key-value store.
+func New(kv *pisces.KV, config *Config) *Log {
+	if config == nil {
+		config = new(Config)
+	}
+	return &Log{
+		kv:  kv,
+		now: timeutil.NowFunc(config.Now),
+	}
+}
+
+func (l *Log) loadHead() (*Event, error) {
+	if l.head != nil {
+		return l.head, nil
+	}
+	var head *Event
+	it := &pisces.Iter{
+		Make: func() any { return new(Event) },
+		Do: func(_ string, v any) error {
+			head = v.(*Event)
+			return nil
+		},
+	}
+	p := &pisces.KVPartial{N: 1, Desc: true}
+	if err := l.kv.WalkPartial(p, it); err != nil {
+		return nil, errcode.Annotate(err, "read last event")
+	}
+	if head == nil {
+		head = new(Event) // Empty log.
+	}
+	l.head = head
+	return head, nil
+}
+
+// maxAppendTries is the number of times that Append tries to save an
+// event when other writers keep taking the next sequence number.
+const maxAppendTries = 10
+
+// tryAppend tries to save the event after the current head. It returns
+// false when another writer has taken the sequence number of the event.
+func (l *Log) tryAppend(e *Event) (bool, error) {
+	head, err := l.loadHead()
+	if err != nil {
+		return false, err
+	}
+	e.Seq = head.Seq + 1
+	e.Time = l.now().UnixNano()
+	e.Prev = head.Hash
+	e.Hash = ""
+	h, err := e.hash()
+	if err != nil {
+		return false, errcode.Annotate(err, "hash event")
+	}
+	e.Hash = h
+
+	saveErr := l.kv.AddClass(eventKey(e.Seq), e.Target, e)
+	if saveErr == nil {
+		l.head = e
+		return true, nil
+	}
+
+	// Reload the head to see if another writer has appended.
+	l.head = nil
+	newHead, err := l.loadHead()
+	if err != nil {
+		return false, err
+	}
+	if newHead.Seq >= e.Seq {
+		return false, nil
+	}
+	return false, errcode.Annotate(saveErr, "save event")
+}
+
+// Append appends an event to the log. It sets the sequence number, the time
+// and the hashes of the event. When another writer appends to the same
+// store at the same time, it reloads the head and tries again.
+func (l *Log) Append(e *Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := 0; i < maxAppendTries; i++ {
+		ok, err := l.tryAppend(e)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return errcode.Internalf(
+		"save event: sequence taken %d times", maxAppendTries,
+	)
+}
+
+// Record appends an event with the result of err. The actor defaults to the
+// user of c, and the remote IP is read from c when c is not nil. Failures
+// are logged rather than returned, so that auditing does not change the
+// result of the audited operation. Recording on a nil log is a no-op.
+func (l *Log) Record(c *aries.C, e *Event, err error) {
+	if l == nil {
+		return
+	}
+	if c != nil {
+		if e.Actor == "" {
+			e.Actor = c.User
+		}
+		if c.Req != nil {
+			e.RemoteIP = aries.RemoteIPString(c)
+		}
+	}
+	e.setResult(err)
+	if err := l.Append(e); err != nil {
+		log.Printf("audit %s on %q: %s", e.Action, e.Target, err)
+	}
+}
+
+// Verify walks through the log and checks the hash chain. It returns an
+// error on the first broken event.
+func (l *Log) Verify() error {
+	var prev *Event
+	it := &pisces.Iter{
+		Make: func() any { return new(Event) },
+		Do: func(_ string, v any) error {
+			e := v.(*Event)
+			want := uint64(1)
+			prevHash := ""
+			if prev != nil {
+				want = prev.Seq + 1
+				prevHash = prev.Hash
+			}
+			if e.Seq != want {
+				return errcode.Internalf(
+					"event %d: want sequence %d", e.Seq, want,
+				)
+			}
+			if e.Prev != prevHash {
+				return errcode.Internalf("event %d: chain broken", e.Seq)
+			}
+			h, err := e.hash()
+			if err != nil {
+				return errcode.Annotatef(err, "hash event %d", e.Seq)
+			}
+			if h != e.Hash {
+				return errcode.Internalf("event %d: hash mismatch", e.Seq)
+			}
+			prev = e
+			return nil
+		},
+	}
+	return l.kv.Walk(it)
+}
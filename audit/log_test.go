@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/pisces"
+)
+
+func TestLog(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	kv := pisces.NewOrderedMemKV()
+	l := New(kv, &Config{Now: func() time.Time { return now }})
+
+	c := &aries.C{
+		User: "admin",
+		Req:  httptest.NewRequest("GET", "/", nil),
+	}
+	for i, target := range []string{"alice", "bob", "alice"} {
+		now = start.Add(time.Duration(i) * time.Hour)
+		l.Record(c, &Event{Action: RoleCreate, Target: target}, nil)
+	}
+	now = start.Add(3 * time.Hour)
+	l.Record(nil, &Event{
+		Actor:  "alice",
+		Action: SignIn,
+		Target: "alice",
+	}, fmt.Errorf("denied"))
+
+	all, err := l.Query(&Query{})
+	if err != nil {
+		t.Fatal("query all: ", err)
+	}
+	if len(all) != 4 {
+		t.Fatalf("got %d events, want 4", len(all))
+	}
+	if e := all[0]; e.Actor != "admin" || e.RemoteIP != "192.0.2.1" {
+		t.Errorf("got actor %q from %q", e.Actor, e.RemoteIP)
+	}
+	if e := all[3]; e.Result != ResultError || e.Error != "denied" {
+		t.Errorf("got result %q, error %q", e.Result, e.Error)
+	}
+
+	for _, test := range []struct {
+		q    *Query
+		want []uint64
+	}{
+		{q: &Query{Target: "alice"}, want: []uint64{1, 3, 4}},
+		{q: &Query{Actor: "alice"}, want: []uint64{4}},
+		{q: &Query{Target: "alice", Limit: 1}, want: []uint64{4}},
+		{
+			q: &Query{
+				Since: start.Add(time.Hour),
+				Until: start.Add(3 * time.Hour),
+			},
+			want: []uint64{2, 3},
+		},
+	} {
+		events, err := l.Query(test.q)
+		if err != nil {
+			t.Fatalf("query %+v: %s", test.q, err)
+		}
+		var got []uint64
+		for _, e := range events {
+			got = append(got, e.Seq)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("query %+v got %v, want %v", test.q, got, test.want)
+		}
+	}
+
+	if err := l.Verify(); err != nil {
+		t.Fatal("verify: ", err)
+	}
+
+	// A new log on the same store continues the chain.
+	l2 := New(kv, nil)
+	if err := l2.Append(&Event{Action: SignIn, Target: "bob"}); err != nil {
+		t.Fatal("append: ", err)
+	}
+	if err := l2.Verify(); err != nil {
+		t.Fatal("verify after reopen: ", err)
+	}
+
+	// Tampering breaks the chain.
+	e := all[1]
+	e.Target = "mallory"
+	if err := kv.Replace(eventKey(e.Seq), e); err != nil {
+		t.Fatal("replace: ", err)
+	}
+	if err := l.Verify(); err == nil {
+		t.Error("tampered log verified")
+	}
+}
+
+func TestLogNil(t *testing.T) {
+	var l *Log
+	l.Record(nil, &Event{Action: SignIn}, nil) // Does not panic.
+}
+
+func TestLogTwoWriters(t *testing.T) {
+	kv := pisces.NewOrderedMemKV()
+	l1 := New(kv, nil)
+	l2 := New(kv, nil)
+	for i, l := range []*Log{l1, l2, l1, l2} {
+		e := &Event{Action: SignIn, Target: "alice"}
+		if err := l.Append(e); err != nil {
+			t.Fatalf("append %d: %s", i, err)
+		}
+		if want := uint64(i + 1); e.Seq != want {
+			t.Errorf("append %d: got sequence %d, want %d", i, e.Seq, want)
+		}
+	}
+	if err := l1.Verify(); err != nil {
+		t.Fatal("verify: ", err)
+	}
+}
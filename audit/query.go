@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/pisces"
+)
+
+// Query selects events from the log.
+type Query struct {
+	// Target selects the events that act on a user. Empty selects all.
+	Target string `json:",omitempty"`
+
+	// Actor selects the events performed by a user. Empty selects all.
+	Actor string `json:",omitempty"`
+
+	// Since and Until select the events in a time range. Zero time does
+	// not limit that end. Until is exclusive.
+	Since time.Time
+	Until time.Time
+
+	// Limit keeps only the most recent events. Zero is no limit.
+	Limit int `json:",omitempty"`
+}
+
+func (q *Query) match(e *Event) bool {
+	if q.Target != "" && e.Target != q.Target {
+		return false
+	}
+	if q.Actor != "" && e.Actor != q.Actor {
+		return false
+	}
+	t := time.Unix(0, e.Time)
+	if !q.Since.IsZero() && t.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !t.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Query returns the events that match the query, in the order of appending.
+func (l *Log) Query(q *Query) ([]*Event, error) {
+	var events []*Event
+	it := &pisces.Iter{
+		Make: func() any { return new(Event) },
+		Do: func(_ string, v any) error {
+			if e := v.(*Event); q.match(e) {
+				events = append(events, e)
+			}
+			return nil
+		},
+	}
+	var err error
+	if q.Target != "" {
+		err = l.kv.WalkClass(q.Target, it)
+	} else {
+		err = l.kv.Walk(it)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[len(events)-q.Limit:]
+	}
+	return events, nil
+}
+
+// QueryResult is the result of a query.
+type QueryResult struct {
+	Events []*Event
+}
+
+func (l *Log) apiQuery(c *aries.C, q *Query) (*QueryResult, error) {
+	events, err := l.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryResult{Events: events}, nil
+}
+
+// API returns the API router for querying the log. It should only be
+// mounted where admins can access.
+func (l *Log) API() *aries.Router {
+	r := aries.NewRouter()
+	r.Call("query", l.apiQuery)
+	return r
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/creds"
+	"shanhu.io/g/flagutil"
+	"shanhu.io/g/subcmd"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/g/unixhttp"
+	"shanhu.io/std/errcode"
+)
+
+var flags = flagutil.NewFactory("creds")
+
+type loginFlags struct {
+	store string
+	user  string
+	key   string
+}
+
+func declareLoginFlags(set *flagutil.FlagSet) *loginFlags {
+	f := new(loginFlags)
+	set.StringVar(&f.store, "store", defaultStore(), storeUsage)
+	set.StringVar(&f.user, "user", "", "user name; default is current user")
+	set.StringVar(&f.key, "key", "", "private key file")
+	return f
+}
+
+func newLogin(server string, f *loginFlags) (*creds.Login, error) {
+	if server == "" {
+		return nil, errcode.InvalidArgf("server missing; use cmd@server")
+	}
+	ep, err := creds.NewEndpoint(server)
+	if err != nil {
+		return nil, err
+	}
+	if f.user != "" {
+		ep.User = f.user
+	}
+	ep.PemFile = f.key
+
+	store, err := openStore(f.store)
+	if err != nil {
+		return nil, errcode.Annotate(err, "open store")
+	}
+	ep.Store = store
+	return creds.NewLogin(ep)
+}
+
+func cmdLogin(server string, args []string) error {
+	set := flags.New()
+	f := declareLoginFlags(set)
+	set.ParseArgs(args)
+
+	lg, err := newLogin(server, f)
+	if err != nil {
+		return err
+	}
+	if _, err := lg.GetToken(); err != nil {
+		return errcode.Annotate(err, "login")
+	}
+	return nil
+}
+
+func cmdLogout(server string, args []string) error {
+	set := flags.New()
+	f := declareLoginFlags(set)
+	set.ParseArgs(args)
+
+	lg, err := newLogin(server, f)
+	if err != nil {
+		return err
+	}
+	return lg.Logout()
+}
+
+func cmdToken(server string, args []string) error {
+	set := flags.New()
+	f := declareLoginFlags(set)
+	set.ParseArgs(args)
+
+	lg, err := newLogin(server, f)
+	if err != nil {
+		return err
+	}
+	tok, err := lg.Token()
+	if err != nil {
+		return err
+	}
+	fmt.Println(tok)
+	return nil
+}
+
+func cmdList(args []string) error {
+	set := flags.New()
+	storeSpec := set.String("store", defaultStore(), storeUsage)
+	set.ParseArgs(args)
+
+	store, err := openStore(*storeSpec)
+	if err != nil {
+		return errcode.Annotate(err, "open store")
+	}
+	list, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tUSER\tEXPIRES")
+	for _, c := range list {
+		c.FixTime()
+		expires := "-"
+		if c.ExpiresTime != nil {
+			expires = timeutil.Time(c.ExpiresTime).Format(
+				"2006-01-02 15:04:05",
+			)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Server, c.User, expires)
+	}
+	return w.Flush()
+}
+
+func cmdAgent(args []string) error {
+	set := flags.New()
+	sock := set.String("sock", "creds-agent.sock", "unix socket to listen on")
+	storeSpec := set.String("store", "mem", storeUsage)
+	set.ParseArgs(args)
+
+	store, err := openStore(*storeSpec)
+	if err != nil {
+		return errcode.Annotate(err, "open store")
+	}
+	lis, err := unixhttp.ListenPrivate(*sock)
+	if err != nil {
+		return errcode.Annotate(err, "listen")
+	}
+	log.Printf("serve on %q", *sock)
+	return http.Serve(lis, aries.Serve(creds.NewCredsAgent(store)))
+}
+
+func main() {
+	cmds := subcmd.New()
+	cmds.AddHost("login", "signs in a server and caches the creds", cmdLogin)
+	cmds.AddHost("logout", "removes the cached creds of a server", cmdLogout)
+	cmds.AddHost("token", "prints a valid token of a server", cmdToken)
+	cmds.Add("list", "lists the cached creds", cmdList)
+	cmds.Add("agent", "serves a creds store on a unix socket", cmdAgent)
+	cmds.Main()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"shanhu.io/g/creds"
+	"shanhu.io/g/termutil"
+	"shanhu.io/std/errcode"
+)
+
+const storeUsage = `creds store; ` +
+	`"home", "mem", "file:<path>" or "agent:<socket>"`
+
+func defaultStore() string {
+	if s, ok := os.LookupEnv("SHANHU_CREDS_STORE"); ok {
+		return s
+	}
+	return "home"
+}
+
+func filePassphrase() ([]byte, error) {
+	if s, ok := os.LookupEnv("SHANHU_CREDS_PASSPHRASE"); ok {
+		return []byte(s), nil
+	}
+	return termutil.ReadPassword("Creds passphrase: ")
+}
+
+// openStore opens a creds store by its spec.
+func openStore(spec string) (creds.CredsStore, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "home":
+		return creds.NewHomeCredsStore(), nil
+	case "mem":
+		return creds.NewMemCredsStore(), nil
+	case "file":
+		if arg == "" {
+			return nil, errcode.InvalidArgf("file path missing")
+		}
+		pass, err := filePassphrase()
+		if err != nil {
+			return nil, errcode.Annotate(err, "read passphrase")
+		}
+		return creds.NewFileCredsStore(arg, pass), nil
+	case "agent":
+		if arg == "" {
+			return nil, errcode.InvalidArgf("socket path missing")
+		}
+		return creds.NewAgentCredsStore(arg), nil
+	}
+	return nil, errcode.InvalidArgf("unknown store %q", spec)
+}
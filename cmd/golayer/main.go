@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"shanhu.io/g/gocheck"
+	"shanhu.io/g/golayer"
+	"shanhu.io/std/lexing"
+)
+
+func errExit(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(-1)
+}
+
+func writeErrs(format, root string, errs []*lexing.Error) error {
+	switch format {
+	case "text":
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s (%s)\n", err, err.Code)
+		}
+		return nil
+	case "json":
+		return gocheck.WriteJSON(os.Stdout, errs)
+	case "sarif":
+		return gocheck.WriteSARIF(os.Stdout, errs, root)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func main() {
+	dir := flag.String("dir", ".", "root directory of the module")
+	rulesFile := flag.String(
+		"rules", "", "rules file; default is golayer.json in the module",
+	)
+	format := flag.String(
+		"format", "text", "output format: text, json or sarif",
+	)
+	flag.Parse()
+
+	root, err := filepath.Abs(*dir)
+	errExit(err)
+	if *rulesFile == "" {
+		*rulesFile = filepath.Join(root, golayer.RulesFile)
+	}
+	rules, err := golayer.ReadRules(*rulesFile)
+	errExit(err)
+
+	errs, err := golayer.CheckModule(root, rules)
+	errExit(err)
+	errExit(writeErrs(*format, root, errs))
+	if len(errs) > 0 {
+		os.Exit(-1)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"shanhu.io/g/flagutil"
+	"shanhu.io/g/gotags"
+	"shanhu.io/g/subcmd"
+	"shanhu.io/std/errcode"
+)
+
+var flags = flagutil.NewFactory("gotags")
+
+const indexUsage = "symbol index file"
+
+func cmdIndex(args []string) error {
+	set := flags.New()
+	dir := set.String("dir", ".", "root directory of the packages")
+	out := set.String("index", gotags.IndexFile, indexUsage)
+	patterns := set.ParseArgs(args)
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	config := &gotags.IndexConfig{Dir: *dir}
+	idx, err := gotags.BuildIndex(config, patterns...)
+	if err != nil {
+		return err
+	}
+	return idx.Save(*out)
+}
+
+func cmdUpdate(args []string) error {
+	set := flags.New()
+	dir := set.String("dir", ".", "root directory of the packages")
+	f := set.String("index", gotags.IndexFile, indexUsage)
+	files := set.ParseArgs(args)
+
+	idx, err := gotags.ReadIndex(*f)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		changed, err := idx.Changed(*dir)
+		if err != nil {
+			return err
+		}
+		files = changed
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	config := &gotags.IndexConfig{Dir: *dir}
+	if err := idx.Update(config, files); err != nil {
+		return err
+	}
+	fmt.Printf("%d files updated\n", len(files))
+	return idx.Save(*f)
+}
+
+func readQuery(args []string) (*gotags.Index, string, error) {
+	set := flags.New()
+	f := set.String("index", gotags.IndexFile, indexUsage)
+	args = set.ParseArgs(args)
+	if len(args) != 1 {
+		return nil, "", errcode.InvalidArgf("expect one symbol name")
+	}
+	idx, err := gotags.ReadIndex(*f)
+	if err != nil {
+		return nil, "", err
+	}
+	return idx, args[0], nil
+}
+
+func printSymbols(syms []*gotags.Symbol) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, s := range syms {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Pos, s.Kind, s.ID)
+	}
+	return w.Flush()
+}
+
+func find(idx *gotags.Index, name string) ([]*gotags.Symbol, error) {
+	syms := idx.Find(name)
+	if len(syms) == 0 {
+		return nil, errcode.NotFoundf("symbol %q not found", name)
+	}
+	return syms, nil
+}
+
+func cmdDef(args []string) error {
+	idx, name, err := readQuery(args)
+	if err != nil {
+		return err
+	}
+	syms, err := find(idx, name)
+	if err != nil {
+		return err
+	}
+	return printSymbols(syms)
+}
+
+func cmdRefs(args []string) error {
+	idx, name, err := readQuery(args)
+	if err != nil {
+		return err
+	}
+	syms, err := find(idx, name)
+	if err != nil {
+		return err
+	}
+	for _, s := range syms {
+		for _, pos := range idx.Refs(s.ID) {
+			fmt.Printf("%s\t%s\n", pos, s.ID)
+		}
+	}
+	return nil
+}
+
+func cmdImpl(args []string) error {
+	idx, name, err := readQuery(args)
+	if err != nil {
+		return err
+	}
+	syms, err := find(idx, name)
+	if err != nil {
+		return err
+	}
+	var impls []*gotags.Symbol
+	for _, s := range syms {
+		impls = append(impls, idx.Implementations(s.ID)...)
+	}
+	return printSymbols(impls)
+}
+
+func main() {
+	cmds := subcmd.New()
+	cmds.Add("index", "builds the symbol index of packages", cmdIndex)
+	cmds.Add(
+		"update", "re-indexes the packages of changed files", cmdUpdate,
+	)
+	cmds.Add("def", "prints the definitions of a symbol", cmdDef)
+	cmds.Add("refs", "prints the references to a symbol", cmdRefs)
+	cmds.Add("impl", "prints the implementations of a symbol", cmdImpl)
+	cmds.Main()
+}
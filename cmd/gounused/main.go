@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shanhu.io/g/gocheck"
+	"shanhu.io/g/gounused"
+	"shanhu.io/g/osutil"
+	"shanhu.io/std/lexing"
+)
+
+func errExit(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(-1)
+}
+
+func writeErrs(format, root string, errs []*lexing.Error) error {
+	switch format {
+	case "text":
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s (%s)\n", err, err.Code)
+		}
+		return nil
+	case "json":
+		return gocheck.WriteJSON(os.Stdout, errs)
+	case "sarif":
+		return gocheck.WriteSARIF(os.Stdout, errs, root)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func readConfig(root, f string) (*gounused.Config, error) {
+	if f != "" {
+		return gounused.ReadConfig(f)
+	}
+	f = filepath.Join(root, gounused.ConfigFile)
+	ok, err := osutil.IsRegular(f)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return new(gounused.Config), nil
+	}
+	return gounused.ReadConfig(f)
+}
+
+func main() {
+	dir := flag.String("dir", ".", "root directory of the module")
+	configFile := flag.String(
+		"config", "", "config file; default is gounused.json in the module",
+	)
+	deps := flag.String(
+		"deps", "",
+		"comma separated dirs of more dependent modules, relative to -dir",
+	)
+	tests := flag.Bool("tests", false, "count the references from tests")
+	format := flag.String(
+		"format", "text", "output format: text, json or sarif",
+	)
+	flag.Parse()
+
+	root, err := filepath.Abs(*dir)
+	errExit(err)
+	config, err := readConfig(root, *configFile)
+	errExit(err)
+	if *deps != "" {
+		config.Deps = append(config.Deps, strings.Split(*deps, ",")...)
+	}
+	if *tests {
+		config.Tests = true
+	}
+
+	errs, err := gounused.Detect(root, nil, config)
+	errExit(err)
+	errExit(writeErrs(*format, root, errs))
+	if len(errs) > 0 {
+		os.Exit(-1)
+	}
+}
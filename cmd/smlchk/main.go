@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"shanhu.io/g/gocheck"
 	"shanhu.io/g/goload"
@@ -18,14 +19,23 @@ func errExit(err error) {
 	os.Exit(-1)
 }
 
-func errsExit(errs []*lexing.Error) {
-	if len(errs) == 0 {
-		return
-	}
-	for _, err := range errs {
-		fmt.Fprintln(os.Stderr, err)
+func writeErrs(format string, errs []*lexing.Error) error {
+	switch format {
+	case "text":
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return nil
+	case "json":
+		return gocheck.WriteJSON(os.Stdout, errs)
+	case "sarif":
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		return gocheck.WriteSARIF(os.Stdout, errs, wd)
 	}
-	os.Exit(-1)
+	return fmt.Errorf("unknown format %q", format)
 }
 
 func main() {
@@ -33,16 +43,32 @@ func main() {
 	textHeight := flag.Int("height", 300, "maximum height for a single file")
 	textWidth := flag.Int("width", 80, "maximum width for a single file")
 	verbose := flag.Bool("v", false, "prints package names")
+	format := flag.String(
+		"format", "text", "output format: text, json or sarif",
+	)
+	disable := flag.String("disable", "", "comma separated rules to skip")
 	flag.Parse()
 
+	config := &gocheck.Config{
+		Height: *textHeight,
+		Width:  *textWidth,
+	}
+	if *disable != "" {
+		config.Disable = strings.Split(*disable, ",")
+	}
+
 	pkgs, err := goload.ListPkgs(*path)
 	errExit(err)
 
+	var errs []*lexing.Error
 	for _, pkg := range pkgs {
 		if *verbose {
-			fmt.Println(pkg)
+			fmt.Fprintln(os.Stderr, pkg)
 		}
-		errs := gocheck.CheckAll(pkg, *textHeight, *textWidth)
-		errsExit(errs)
+		errs = append(errs, gocheck.Check(pkg, config)...)
+	}
+	errExit(writeErrs(*format, errs))
+	if len(errs) > 0 {
+		os.Exit(-1)
 	}
 }
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -9,7 +12,6 @@ import (
 
 	"shanhu.io/g/dags"
 	"shanhu.io/g/godep"
-	"shanhu.io/g/goload"
 )
 
 func exitIf(err error) {
@@ -41,42 +43,111 @@ func saveLayoutBytes(bs []byte, f string) {
 	exitIf(os.WriteFile(f, bs, 0644))
 }
 
-func saveLayout(g *dags.Graph, f string) {
-	m, err := dags.LayoutJSON(g)
-	exitIf(err)
-	saveLayoutBytes(m, f)
+// layoutCache saves the last layout, so that the next layout can be
+// updated incrementally.
+type layoutCache struct {
+	Graph *dags.Graph
+	View  *dags.MapView
 }
 
-func repoDep(repo string) (*dags.Graph, error) {
-	if repo == "" {
-		return godep.StdDep()
+func readCache(f string) (*layoutCache, error) {
+	bs, err := os.ReadFile(f)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return new(layoutCache), nil
+		}
+		return nil, err
 	}
+	c := new(layoutCache)
+	if err := json.Unmarshal(bs, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
 
-	pkgs, err := goload.ListPkgs(repo)
+func layout(g *dags.Graph, cacheFile string) (*dags.MapView, error) {
+	if cacheFile == "" {
+		_, v, err := dags.Layout(g)
+		return v, err
+	}
+
+	c, err := readCache(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	v, err := dags.LayoutIncremental(c.Graph, c.View, g)
 	if err != nil {
 		return nil, err
 	}
-	g, err := godep.PkgDep(pkgs)
+	bs, err := json.Marshal(&layoutCache{Graph: g, View: v})
 	if err != nil {
 		return nil, err
 	}
+	if err := os.WriteFile(cacheFile, bs, 0644); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
 
-	repoSlash := repo + "/"
-	return g.Rename(func(name string) (string, error) {
-		if name == repo {
-			return "~", nil
-		}
-		return strings.TrimPrefix(name, repoSlash), nil
-	})
+func saveLayout(g *dags.Graph, f, cacheFile string) {
+	v, err := layout(g, cacheFile)
+	exitIf(err)
+	bs, err := json.MarshalIndent(dags.Output(v), "", "    ")
+	exitIf(err)
+	saveLayoutBytes(bs, f)
+}
+
+func export(g *dags.Graph, format, f, cacheFile string) {
+	buf := new(bytes.Buffer)
+	switch format {
+	case "json":
+		saveLayout(g, f, cacheFile)
+		return
+	case "svg":
+		v, err := layout(g, cacheFile)
+		exitIf(err)
+		exitIf(dags.WriteSVG(buf, v))
+	case "dot":
+		exitIf(dags.WriteDOT(buf, g, "godep"))
+	case "graphml":
+		exitIf(dags.WriteGraphML(buf, g, "godep"))
+	default:
+		exitIf(fmt.Errorf("unknown format %q", format))
+	}
+	exitIf(os.WriteFile(f, buf.Bytes(), 0644))
 }
 
 func main() {
 	repo := flag.String("repo", "", "repository to generate the dependency map")
+	dir := flag.String(
+		"dir", "", "module directory to generate the dependency map, "+
+			"using the go command in module mode; overrides -repo",
+	)
+	tests := flag.Bool(
+		"tests", false,
+		"include test imports as \"_test\" nodes, with -dir",
+	)
+	modules := flag.Bool(
+		"modules", false, "generate the module level map, with -dir",
+	)
 	out := flag.String("out", "godag.json", "output JSON file")
+	cache := flag.String(
+		"cache", "", "layout cache file for incremental layout",
+	)
+	format := flag.String(
+		"format", "json", "output format: json, svg, dot or graphml",
+	)
 	flag.Parse()
 
-	g, e := repoDep(*repo)
-	exitIf(e)
+	var g *dags.Graph
+	var err error
+	if *dir != "" {
+		config := &godep.Config{Dir: *dir, Tests: *tests}
+		g, err = godep.DirDep(config, *modules)
+	} else {
+		g, err = godep.RepoDep(*repo)
+	}
+	exitIf(err)
 
-	saveLayout(g, *out)
+	export(g, *format, *out, *cache)
 }
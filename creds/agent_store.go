@@ -0,0 +1,83 @@
+package creds
+
+import (
+	"shanhu.io/g/aries"
+	"shanhu.io/g/httputil"
+)
+
+type agentServerRequest struct {
+	Server string
+}
+
+type agentList struct {
+	Creds []*Creds
+}
+
+// NewCredsAgent creates a service that serves a creds store over HTTP. It
+// is normally served on a unix domain socket that is only accessible by
+// the user, so that processes of the user can share creds without saving
+// them in the home directory.
+func NewCredsAgent(s CredsStore) aries.Service {
+	r := aries.NewRouter()
+	r.Call("read", func(c *aries.C, req *agentServerRequest) (
+		*Creds, error,
+	) {
+		return s.Read(req.Server)
+	})
+	r.Call("write", func(c *aries.C, req *Creds) error {
+		return s.Write(req)
+	})
+	r.Call("remove", func(c *aries.C, req *agentServerRequest) error {
+		return s.Remove(req.Server)
+	})
+	r.Call("list", func(c *aries.C) (*agentList, error) {
+		list, err := s.List()
+		if err != nil {
+			return nil, err
+		}
+		return &agentList{Creds: list}, nil
+	})
+	return r
+}
+
+// AgentCredsStore is a creds store that talks to a creds agent over a unix
+// domain socket.
+type AgentCredsStore struct {
+	client *httputil.Client
+}
+
+// NewAgentCredsStore creates a creds store that uses the creds agent
+// listening on the given unix domain socket.
+func NewAgentCredsStore(sock string) *AgentCredsStore {
+	return &AgentCredsStore{client: httputil.NewUnixClient(sock)}
+}
+
+// Read reads the creds of the server.
+func (s *AgentCredsStore) Read(server string) (*Creds, error) {
+	c := new(Creds)
+	req := &agentServerRequest{Server: server}
+	if err := s.client.Call("/read", req, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Write saves the creds.
+func (s *AgentCredsStore) Write(c *Creds) error {
+	return s.client.Call("/write", c, nil)
+}
+
+// Remove removes the creds of the server.
+func (s *AgentCredsStore) Remove(server string) error {
+	req := &agentServerRequest{Server: server}
+	return s.client.Call("/remove", req, nil)
+}
+
+// List lists all the creds.
+func (s *AgentCredsStore) List() ([]*Creds, error) {
+	list := new(agentList)
+	if err := s.client.Call("/list", nil, list); err != nil {
+		return nil, err
+	}
+	return list.Creds, nil
+}
@@ -1,31 +1,107 @@
 package creds
 
 import (
+	"os"
+	"sort"
+	"strings"
+
 	"shanhu.io/std/errcode"
 )
 
-type credsStore interface {
-	read() (*Creds, error)
-	write(c *Creds) error
+// CredsStore saves the cached credentials of logins, one for each server.
+type CredsStore interface {
+	// Read reads the creds of the server. It returns a not found error if
+	// there is no creds saved for the server.
+	Read(server string) (*Creds, error)
+
+	// Write saves the creds, replacing the existing one of the same server.
+	Write(c *Creds) error
+
+	// Remove removes the creds of the server. It is not an error if there
+	// is no creds saved for the server.
+	Remove(server string) error
+
+	// List lists all the saved creds, sorted by server.
+	List() ([]*Creds, error)
 }
 
-type homeCredsStore struct {
-	file string
+func sortCreds(list []*Creds) {
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Server < list[j].Server
+	})
 }
 
-func newHomeCredsStore(server string) *homeCredsStore {
-	f := Filename(server) + ".json"
-	return &homeCredsStore{file: f}
+// HomeCredsStore saves creds as JSON files under the home directory. This
+// is the default store.
+type HomeCredsStore struct{}
+
+// NewHomeCredsStore creates a creds store that saves creds in the home
+// directory.
+func NewHomeCredsStore() *HomeCredsStore { return &HomeCredsStore{} }
+
+func homeCredsFile(server string) string {
+	return Filename(server) + ".json"
 }
 
-func (s *homeCredsStore) read() (*Creds, error) {
+// Read reads the creds of the server.
+func (s *HomeCredsStore) Read(server string) (*Creds, error) {
 	creds := &Creds{}
-	if err := ReadHomeJSONFile(s.file, creds); err != nil {
+	if err := ReadHomeJSONFile(homeCredsFile(server), creds); err != nil {
 		return nil, errcode.FromOS(err)
 	}
 	return creds, nil
 }
 
-func (s *homeCredsStore) write(c *Creds) error {
-	return errcode.FromOS(WriteHomeJSONFile(s.file, c))
+// Write saves the creds.
+func (s *HomeCredsStore) Write(c *Creds) error {
+	return errcode.FromOS(WriteHomeJSONFile(homeCredsFile(c.Server), c))
+}
+
+// Remove removes the creds of the server.
+func (s *HomeCredsStore) Remove(server string) error {
+	p, err := HomeFile(homeCredsFile(server))
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List lists all the creds in the home directory. JSON files that are not
+// creds are ignored.
+func (s *HomeCredsStore) List() ([]*Creds, error) {
+	h, err := Home()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(h)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var list []*Creds
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		creds := &Creds{}
+		if err := ReadHomeJSONFile(name, creds); err != nil {
+			continue
+		}
+		if creds.Server == "" || creds.Token == "" {
+			continue
+		}
+		if homeCredsFile(creds.Server) != name {
+			continue
+		}
+		list = append(list, creds)
+	}
+	sortCreds(list)
+	return list, nil
 }
@@ -0,0 +1,86 @@
+package creds
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/g/unixhttp"
+	"shanhu.io/std/errcode"
+)
+
+func testCredsStore(t *testing.T, s CredsStore) {
+	t.Helper()
+
+	const server = "https://shanhu.io"
+	if _, err := s.Read(server); !errcode.IsNotFound(err) {
+		t.Fatalf("read missing creds got %v, want not found", err)
+	}
+
+	for _, c := range []*Creds{{
+		Server: server,
+		Creds:  signinapi.Creds{User: "h8liu", Token: "tok1"},
+	}, {
+		Server: "https://example.com",
+		Creds:  signinapi.Creds{User: "robot", Token: "tok2"},
+	}} {
+		if err := s.Write(c); err != nil {
+			t.Fatalf("write creds of %q: %s", c.Server, err)
+		}
+	}
+
+	c, err := s.Read(server)
+	if err != nil {
+		t.Fatal("read creds: ", err)
+	}
+	if c.User != "h8liu" || c.Token != "tok1" {
+		t.Errorf("got creds %+v", c)
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal("list creds: ", err)
+	}
+	if len(list) != 2 || list[0].Server != "https://example.com" {
+		t.Errorf("got %d creds, want 2 sorted by server", len(list))
+	}
+
+	if err := s.Remove(server); err != nil {
+		t.Fatal("remove creds: ", err)
+	}
+	if err := s.Remove(server); err != nil {
+		t.Fatal("remove missing creds: ", err)
+	}
+	if _, err := s.Read(server); !errcode.IsNotFound(err) {
+		t.Errorf("read removed creds got %v, want not found", err)
+	}
+}
+
+func TestMemCredsStore(t *testing.T) {
+	testCredsStore(t, NewMemCredsStore())
+}
+
+func TestFileCredsStore(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "creds")
+	testCredsStore(t, NewFileCredsStore(f, []byte("passphrase")))
+
+	wrong := NewFileCredsStore(f, []byte("wrong"))
+	if _, err := wrong.List(); !errcode.IsUnauthorized(err) {
+		t.Errorf("list with wrong passphrase got %v", err)
+	}
+}
+
+func TestAgentCredsStore(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	lis, err := unixhttp.Listen(sock)
+	if err != nil {
+		t.Fatal("listen: ", err)
+	}
+	s := &http.Server{Handler: aries.Serve(NewCredsAgent(NewMemCredsStore()))}
+	go s.Serve(lis)
+	defer s.Close()
+
+	testCredsStore(t, NewAgentCredsStore(sock))
+}
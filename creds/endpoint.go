@@ -28,6 +28,10 @@ type Endpoint struct {
 	// Optional transport for creating the client.
 	Transport http.RoundTripper
 
+	// Optional store for caching the creds. If nil, creds are cached in
+	// the home directory, unless Homeless is true.
+	Store CredsStore
+
 	Homeless bool // If true, will not look into the home folder for caches.
 	NoTTY    bool // If true, will not fail if the key is encrypted.
 }
@@ -0,0 +1,179 @@
+package creds
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"shanhu.io/g/osutil"
+	"shanhu.io/g/rand"
+	"shanhu.io/std/errcode"
+)
+
+// encryptedCredsFile is the content of an encrypted creds file. The data
+// is a JSON map from servers to creds, sealed with AES-GCM, with a key
+// derived from the passphrase using Argon2id.
+type encryptedCredsFile struct {
+	Salt  []byte
+	Nonce []byte
+	Data  []byte
+}
+
+func credsFileCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	key := argon2.IDKey(passphrase, salt, 1, 64*1024, 4, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// FileCredsStore saves all creds in a single file, encrypted with a
+// passphrase. It is for machines where the home directory is shared.
+type FileCredsStore struct {
+	file       string
+	passphrase []byte
+
+	mu sync.Mutex
+}
+
+// NewFileCredsStore creates a creds store that saves creds in file,
+// encrypted with the passphrase.
+func NewFileCredsStore(file string, passphrase []byte) *FileCredsStore {
+	return &FileCredsStore{
+		file:       file,
+		passphrase: passphrase,
+	}
+}
+
+func (s *FileCredsStore) load() (map[string]*Creds, error) {
+	bs, err := osutil.ReadPrivateFile(s.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]*Creds), nil
+		}
+		return nil, err
+	}
+
+	f := new(encryptedCredsFile)
+	if err := json.Unmarshal(bs, f); err != nil {
+		return nil, errcode.Annotate(err, "decode creds file")
+	}
+	c, err := credsFileCipher(s.passphrase, f.Salt)
+	if err != nil {
+		return nil, errcode.Annotate(err, "make cipher")
+	}
+	plain, err := c.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, errcode.Unauthorizedf("wrong passphrase or bad file")
+	}
+
+	m := make(map[string]*Creds)
+	if err := json.Unmarshal(plain, &m); err != nil {
+		return nil, errcode.Annotate(err, "decode creds")
+	}
+	return m, nil
+}
+
+func (s *FileCredsStore) save(m map[string]*Creds) error {
+	plain, err := json.Marshal(m)
+	if err != nil {
+		return errcode.Annotate(err, "encode creds")
+	}
+	salt := rand.Bytes(16)
+	c, err := credsFileCipher(s.passphrase, salt)
+	if err != nil {
+		return errcode.Annotate(err, "make cipher")
+	}
+	nonce := rand.Bytes(c.NonceSize())
+	f := &encryptedCredsFile{
+		Salt:  salt,
+		Nonce: nonce,
+		Data:  c.Seal(nil, nonce, plain, nil),
+	}
+	bs, err := json.Marshal(f)
+	if err != nil {
+		return errcode.Annotate(err, "encode creds file")
+	}
+
+	// Writes to a temp file first, so that the file is never half written.
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), ".creds-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// Read reads the creds of the server.
+func (s *FileCredsStore) Read(server string) (*Creds, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	c, ok := m[server]
+	if !ok {
+		return nil, errcode.NotFoundf("creds not found")
+	}
+	return c, nil
+}
+
+// Write saves the creds.
+func (s *FileCredsStore) Write(c *Creds) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return err
+	}
+	m[c.Server] = c
+	return s.save(m)
+}
+
+// Remove removes the creds of the server.
+func (s *FileCredsStore) Remove(server string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := m[server]; !ok {
+		return nil
+	}
+	delete(m, server)
+	return s.save(m)
+}
+
+// List lists all the creds.
+func (s *FileCredsStore) List() ([]*Creds, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var list []*Creds
+	for _, c := range m {
+		list = append(list, c)
+	}
+	sortCreds(list)
+	return list, nil
+}
@@ -30,7 +30,7 @@ func LoginWithKey(p *Endpoint) (*Creds, error) {
 // Login is a helper stub to perform login actions.
 type Login struct {
 	endPoint   *Endpoint
-	credsStore credsStore
+	credsStore CredsStore
 	creds      *Creds // cached creds
 }
 
@@ -58,9 +58,9 @@ func NewLogin(p *Endpoint) (*Login, error) {
 		cp.PemFile = pem
 	}
 
-	lg := &Login{endPoint: &cp}
-	if !p.Homeless {
-		lg.credsStore = newHomeCredsStore(p.Server.String())
+	lg := &Login{endPoint: &cp, credsStore: p.Store}
+	if lg.credsStore == nil && !p.Homeless {
+		lg.credsStore = NewHomeCredsStore()
 	}
 	return lg, nil
 }
@@ -92,14 +92,14 @@ func (lg *Login) check(cs *Creds) (bool, error) {
 // Token returns the login token for the login. If a valid token is already
 // cached, it returns the cached one.
 func (lg *Login) Token() (string, error) {
-	if lg.endPoint.Homeless {
+	if lg.credsStore == nil {
 		// Nothing cached anywhere, just return a new one.
 		return lg.GetToken()
 	}
 
 	cs := lg.creds
-	if cs == nil && lg.credsStore != nil {
-		newCreds, err := lg.credsStore.read()
+	if cs == nil {
+		newCreds, err := lg.credsStore.Read(lg.endPoint.Server.String())
 		if err != nil {
 			if errcode.IsNotFound(err) {
 				return lg.GetToken()
@@ -119,12 +119,54 @@ func (lg *Login) Token() (string, error) {
 		return "", err
 	}
 	if !ok {
+		if canRefresh(cs, time.Now()) {
+			if tok, err := lg.refresh(cs); err == nil {
+				return tok, nil
+			}
+		}
 		return lg.GetToken()
 	}
 
 	return cs.Token, nil
 }
 
+func (lg *Login) save(cs *Creds) error {
+	lg.creds = cs
+
+	// If there is a store, also cache it in the store.
+	if lg.credsStore != nil {
+		if err := lg.credsStore.Write(cs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refresh uses the refresh token in the cached creds to get a new token,
+// without signing in again with the key.
+func (lg *Login) refresh(cs *Creds) (string, error) {
+	ep := lg.endPoint
+	newCreds, err := refreshCreds(
+		context.TODO(), ep.Transport, ep.Server, cs,
+	)
+	if err != nil {
+		return "", err
+	}
+	if err := lg.save(newCreds); err != nil {
+		return "", err
+	}
+	return newCreds.Token, nil
+}
+
+// Logout removes the cached creds of the login.
+func (lg *Login) Logout() error {
+	lg.creds = nil
+	if lg.credsStore == nil {
+		return nil
+	}
+	return lg.credsStore.Remove(lg.endPoint.Server.String())
+}
+
 // Do performs the login and returns the credentials.
 // It does not read or write the credential cache file.
 func (lg *Login) Do() (*Creds, error) {
@@ -138,15 +180,8 @@ func (lg *Login) GetToken() (string, error) {
 	if err != nil {
 		return "", err
 	}
-
-	// cache it
-	lg.creds = cs
-
-	// If not homeless, also cache it in home directory.
-	if lg.credsStore != nil {
-		if err := lg.credsStore.write(cs); err != nil {
-			return "", err
-		}
+	if err := lg.save(cs); err != nil {
+		return "", err
 	}
 	return cs.Creds.Token, nil
 }
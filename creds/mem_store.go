@@ -0,0 +1,65 @@
+package creds
+
+import (
+	"sync"
+
+	"shanhu.io/std/errcode"
+)
+
+// MemCredsStore saves creds in memory. Creds are lost when the process
+// exits.
+type MemCredsStore struct {
+	mu sync.Mutex
+	m  map[string]*Creds
+}
+
+// NewMemCredsStore creates a new in-memory creds store.
+func NewMemCredsStore() *MemCredsStore {
+	return &MemCredsStore{m: make(map[string]*Creds)}
+}
+
+func copyCreds(c *Creds) *Creds {
+	cp := *c
+	return &cp
+}
+
+// Read reads the creds of the server.
+func (s *MemCredsStore) Read(server string) (*Creds, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.m[server]
+	if !ok {
+		return nil, errcode.NotFoundf("creds not found")
+	}
+	return copyCreds(c), nil
+}
+
+// Write saves the creds.
+func (s *MemCredsStore) Write(c *Creds) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[c.Server] = copyCreds(c)
+	return nil
+}
+
+// Remove removes the creds of the server.
+func (s *MemCredsStore) Remove(server string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, server)
+	return nil
+}
+
+// List lists all the creds.
+func (s *MemCredsStore) List() ([]*Creds, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*Creds
+	for _, c := range s.m {
+		list = append(list, copyCreds(c))
+	}
+	sortCreds(list)
+	return list, nil
+}
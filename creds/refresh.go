@@ -0,0 +1,47 @@
+package creds
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"shanhu.io/g/httputil"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// canRefresh checks if the creds carries a refresh token that has not
+// expired yet.
+func canRefresh(cs *Creds, now time.Time) bool {
+	if cs.RefreshToken == "" {
+		return false
+	}
+	if cs.RefreshExpiresTime == nil {
+		return true
+	}
+	return now.Before(timeutil.Time(cs.RefreshExpiresTime))
+}
+
+// refreshCreds exchanges the refresh token in cs for new creds.
+func refreshCreds(
+	ctx context.Context, tr http.RoundTripper, server *url.URL, cs *Creds,
+) (*Creds, error) {
+	c := &httputil.Client{Server: server, Transport: tr}
+	req := &signinapi.RefreshRequest{RefreshToken: cs.RefreshToken}
+	got := new(signinapi.Creds)
+	if err := c.CallContext(ctx, "/refresh", req, got); err != nil {
+		return nil, errcode.Annotate(err, "refresh")
+	}
+	if got.User != cs.User {
+		return nil, errcode.Internalf(
+			"refresh as user %q, got %q", cs.User, got.User,
+		)
+	}
+	got.FixTime()
+	return &Creds{
+		Server: server.String(),
+		Creds:  *got,
+	}, nil
+}
@@ -0,0 +1,122 @@
+package dags
+
+import (
+	"sort"
+	"strings"
+)
+
+// StrongComponents returns the strongly connected components of a graph.
+// The nodes in each component are sorted, and the components are sorted by
+// their first nodes.
+func StrongComponents(g *Graph) [][]string {
+	t := &tarjan{
+		g:       g,
+		index:   make(map[string]int),
+		low:     make(map[string]int),
+		onStack: make(map[string]bool),
+	}
+	for _, name := range sortedNodeNames(g) {
+		if _, ok := t.index[name]; !ok {
+			t.visit(name)
+		}
+	}
+	sort.Slice(t.comps, func(i, j int) bool {
+		return t.comps[i][0] < t.comps[j][0]
+	})
+	return t.comps
+}
+
+type tarjan struct {
+	g       *Graph
+	next    int
+	index   map[string]int
+	low     map[string]int
+	stack   []string
+	onStack map[string]bool
+	comps   [][]string
+}
+
+func (t *tarjan) visit(name string) {
+	t.index[name] = t.next
+	t.low[name] = t.next
+	t.next++
+	t.stack = append(t.stack, name)
+	t.onStack[name] = true
+
+	for _, out := range t.g.Nodes[name] {
+		if _, ok := t.index[out]; !ok {
+			t.visit(out)
+			t.low[name] = min(t.low[name], t.low[out])
+		} else if t.onStack[out] {
+			t.low[name] = min(t.low[name], t.index[out])
+		}
+	}
+
+	if t.low[name] != t.index[name] {
+		return
+	}
+	var comp []string
+	for {
+		n := len(t.stack) - 1
+		top := t.stack[n]
+		t.stack = t.stack[:n]
+		t.onStack[top] = false
+		comp = append(comp, top)
+		if top == name {
+			break
+		}
+	}
+	sort.Strings(comp)
+	t.comps = append(t.comps, comp)
+}
+
+// Condensation is a DAG where each strongly connected component of a graph
+// is condensed into a single node.
+type Condensation struct {
+	Graph *Graph
+
+	// Members maps a node in the condensed graph to the nodes in the
+	// component.
+	Members map[string][]string
+
+	// Of maps a node in the original graph to the node of its component.
+	Of map[string]string
+}
+
+// Condense condenses the strongly connected components of a graph, so that
+// graphs with circles can still be laid out. A component of a single node
+// keeps the name of the node; a larger component is named by joining the
+// names of its nodes with "+". Self loops are removed.
+func Condense(g *Graph) *Condensation {
+	c := &Condensation{
+		Members: make(map[string][]string),
+		Of:      make(map[string]string),
+	}
+	for _, comp := range StrongComponents(g) {
+		name := strings.Join(comp, "+")
+		c.Members[name] = comp
+		for _, n := range comp {
+			c.Of[n] = name
+		}
+	}
+
+	nodes := make(map[string][]string)
+	for name, comp := range c.Members {
+		outs := make(map[string]bool)
+		for _, n := range comp {
+			for _, out := range g.Nodes[n] {
+				if to := c.Of[out]; to != name {
+					outs[to] = true
+				}
+			}
+		}
+		var list []string
+		for out := range outs {
+			list = append(list, out)
+		}
+		sort.Strings(list)
+		nodes[name] = list
+	}
+	c.Graph = NewGraph(nodes)
+	return c
+}
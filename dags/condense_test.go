@@ -0,0 +1,42 @@
+package dags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCondense(t *testing.T) {
+	g := NewGraph(map[string][]string{
+		"a": {"b"},
+		"b": {"c", "d"},
+		"c": {"a"},
+		"d": {"e"},
+		"e": {"d", "e", "f"},
+		"f": nil,
+	})
+
+	comps := StrongComponents(g)
+	wantComps := [][]string{{"a", "b", "c"}, {"d", "e"}, {"f"}}
+	if !reflect.DeepEqual(comps, wantComps) {
+		t.Errorf("got components %q, want %q", comps, wantComps)
+	}
+
+	c := Condense(g)
+	want := map[string][]string{
+		"a+b+c": {"d+e"},
+		"d+e":   {"f"},
+		"f":     nil,
+	}
+	if !reflect.DeepEqual(c.Graph.Nodes, want) {
+		t.Errorf("got condensed graph %v, want %v", c.Graph.Nodes, want)
+	}
+	if c.Of["e"] != "d+e" {
+		t.Errorf("got component %q for e, want d+e", c.Of["e"])
+	}
+	if err := CheckDAG(c.Graph); err != nil {
+		t.Errorf("condensed graph is not a DAG: %s", err)
+	}
+	if _, _, err := Layout(c.Graph); err != nil {
+		t.Errorf("layout condensed graph: %s", err)
+	}
+}
@@ -0,0 +1,80 @@
+package dags
+
+import (
+	"sort"
+)
+
+// Edge is an edge in a graph.
+type Edge struct {
+	From string
+	To   string
+}
+
+// GraphDiff is the difference between two versions of a graph.
+type GraphDiff struct {
+	AddedNodes   []string
+	RemovedNodes []string
+	AddedEdges   []*Edge
+	RemovedEdges []*Edge
+}
+
+// Empty returns true if the two versions are the same.
+func (d *GraphDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0
+}
+
+func edgeSet(g *Graph) map[Edge]bool {
+	ret := make(map[Edge]bool)
+	for from, outs := range g.Nodes {
+		for _, to := range outs {
+			ret[Edge{From: from, To: to}] = true
+		}
+	}
+	return ret
+}
+
+func sortEdges(edges []*Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+}
+
+// Diff compares two versions of a graph. The results are sorted.
+func Diff(old, cur *Graph) *GraphDiff {
+	d := new(GraphDiff)
+	for name := range cur.Nodes {
+		if _, ok := old.Nodes[name]; !ok {
+			d.AddedNodes = append(d.AddedNodes, name)
+		}
+	}
+	for name := range old.Nodes {
+		if _, ok := cur.Nodes[name]; !ok {
+			d.RemovedNodes = append(d.RemovedNodes, name)
+		}
+	}
+	sort.Strings(d.AddedNodes)
+	sort.Strings(d.RemovedNodes)
+
+	oldEdges := edgeSet(old)
+	curEdges := edgeSet(cur)
+	for e := range curEdges {
+		if !oldEdges[e] {
+			d.AddedEdges = append(d.AddedEdges, &Edge{From: e.From, To: e.To})
+		}
+	}
+	for e := range oldEdges {
+		if !curEdges[e] {
+			d.RemovedEdges = append(
+				d.RemovedEdges, &Edge{From: e.From, To: e.To},
+			)
+		}
+	}
+	sortEdges(d.AddedEdges)
+	sortEdges(d.RemovedEdges)
+	return d
+}
@@ -0,0 +1,36 @@
+package dags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := NewGraph(map[string][]string{
+		"a": {"b", "c"},
+		"b": nil,
+		"c": nil,
+	})
+	cur := NewGraph(map[string][]string{
+		"a": {"b", "d"},
+		"b": {"d"},
+		"d": nil,
+	})
+
+	d := Diff(old, cur)
+	want := &GraphDiff{
+		AddedNodes:   []string{"d"},
+		RemovedNodes: []string{"c"},
+		AddedEdges:   []*Edge{{"a", "d"}, {"b", "d"}},
+		RemovedEdges: []*Edge{{"a", "c"}},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("got diff %+v, want %+v", d, want)
+	}
+	if d.Empty() {
+		t.Error("diff should not be empty")
+	}
+	if d := Diff(cur, cur); !d.Empty() {
+		t.Errorf("got diff %+v on same graph", d)
+	}
+}
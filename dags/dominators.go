@@ -0,0 +1,79 @@
+package dags
+
+import (
+	"fmt"
+)
+
+// Dominators computes the immediate dominators of the nodes that can be
+// reached from the root. A node d dominates node n if every path from the
+// root to n goes through d. The result maps each reachable node other than
+// the root to its immediate dominator. The graph can have circles.
+func Dominators(g *Graph, root string) (map[string]string, error) {
+	if _, ok := g.Nodes[root]; !ok {
+		return nil, fmt.Errorf("root %q not found", root)
+	}
+
+	// Numbers the nodes in reverse post order with a depth-first search.
+	var post []string
+	visited := make(map[string]bool)
+	var visit func(n string)
+	visit = func(n string) {
+		visited[n] = true
+		for _, out := range sortedOuts(g, n) {
+			if !visited[out] {
+				visit(out)
+			}
+		}
+		post = append(post, n)
+	}
+	visit(root)
+
+	order := make(map[string]int) // Post order numbers.
+	for i, n := range post {
+		order[n] = i
+	}
+	preds := make(map[string][]string)
+	for _, n := range post {
+		for _, out := range g.Nodes[n] {
+			preds[out] = append(preds[out], n)
+		}
+	}
+
+	// The iterative algorithm by Cooper, Harvey and Kennedy.
+	idom := map[string]string{root: root}
+	intersect := func(a, b string) string {
+		for a != b {
+			for order[a] < order[b] {
+				a = idom[a]
+			}
+			for order[b] < order[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(post) - 2; i >= 0; i-- { // Skips the root.
+			n := post[i]
+			newIdom := ""
+			for _, p := range preds[n] {
+				if _, ok := idom[p]; !ok {
+					continue
+				}
+				if newIdom == "" {
+					newIdom = p
+				} else {
+					newIdom = intersect(p, newIdom)
+				}
+			}
+			if idom[n] != newIdom {
+				idom[n] = newIdom
+				changed = true
+			}
+		}
+	}
+
+	delete(idom, root)
+	return idom, nil
+}
@@ -0,0 +1,38 @@
+package dags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDominators(t *testing.T) {
+	g := NewGraph(map[string][]string{
+		"r": {"a", "b"},
+		"a": {"c"},
+		"b": {"c", "d"},
+		"c": {"e"},
+		"d": {"e"},
+		"e": {"f", "a"},
+		"f": nil,
+		"x": {"f"}, // Not reachable from r.
+	})
+	idom, err := Dominators(g, "r")
+	if err != nil {
+		t.Fatal("dominators: ", err)
+	}
+	want := map[string]string{
+		"a": "r",
+		"b": "r",
+		"c": "r",
+		"d": "b",
+		"e": "r",
+		"f": "e",
+	}
+	if !reflect.DeepEqual(idom, want) {
+		t.Errorf("got %v, want %v", idom, want)
+	}
+
+	if _, err := Dominators(g, "missing"); err == nil {
+		t.Error("want error on missing root")
+	}
+}
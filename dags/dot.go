@@ -0,0 +1,33 @@
+package dags
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotID(s string) string { return `"` + dotEscaper.Replace(s) + `"` }
+
+// WriteDOT writes the graph in Graphviz DOT format. Nodes and edges are
+// written in sorted order, so that the output is stable.
+func WriteDOT(w io.Writer, g *Graph, name string) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "digraph %s {\n", dotID(name))
+	fmt.Fprintln(out, "\trankdir=LR;")
+	fmt.Fprintln(out, "\tnode [shape=box];")
+
+	names := sortedNodeNames(g)
+	for _, n := range names {
+		fmt.Fprintf(out, "\t%s;\n", dotID(n))
+	}
+	for _, n := range names {
+		for _, to := range sortedOuts(g, n) {
+			fmt.Fprintf(out, "\t%s -> %s;\n", dotID(n), dotID(to))
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
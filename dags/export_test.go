@@ -0,0 +1,104 @@
+package dags
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func checkWellFormedXML(t *testing.T, bs []byte) {
+	t.Helper()
+	dec := xml.NewDecoder(bytes.NewReader(bs))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("invalid XML: %s\n%s", err, bs)
+		}
+	}
+}
+
+func exportTestGraph() *Graph {
+	return NewGraph(map[string][]string{
+		"a":     {"c", "b"},
+		"b":     {"c"},
+		"c":     nil,
+		`x"<y>`: {"a"},
+	})
+}
+
+func TestWriteDOT(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := WriteDOT(buf, exportTestGraph(), "deps"); err != nil {
+		t.Fatal("write DOT: ", err)
+	}
+	want := strings.Join([]string{
+		`digraph "deps" {`,
+		"\trankdir=LR;",
+		"\tnode [shape=box];",
+		"\t\"a\";",
+		"\t\"b\";",
+		"\t\"c\";",
+		"\t\"x\\\"<y>\";",
+		"\t\"a\" -> \"b\";",
+		"\t\"a\" -> \"c\";",
+		"\t\"b\" -> \"c\";",
+		"\t\"x\\\"<y>\" -> \"a\";",
+		"}",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("got DOT:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := WriteGraphML(buf, exportTestGraph(), "deps"); err != nil {
+		t.Fatal("write GraphML: ", err)
+	}
+	checkWellFormedXML(t, buf.Bytes())
+
+	doc := new(graphML)
+	if err := xml.Unmarshal(buf.Bytes(), doc); err != nil {
+		t.Fatal("parse GraphML: ", err)
+	}
+	if n := len(doc.Graph.Nodes); n != 4 {
+		t.Errorf("got %d nodes, want 4", n)
+	}
+	if n := len(doc.Graph.Edges); n != 4 {
+		t.Errorf("got %d edges, want 4", n)
+	}
+	if e := doc.Graph.Edges[3]; e.Source != `x"<y>` || e.Target != "a" {
+		t.Errorf("got edge %+v", e)
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	_, v, err := Layout(exportTestGraph())
+	if err != nil {
+		t.Fatal("layout: ", err)
+	}
+	v.AssignDisplayName(strings.ToUpper)
+
+	buf := new(bytes.Buffer)
+	if err := WriteSVG(buf, v); err != nil {
+		t.Fatal("write SVG: ", err)
+	}
+	checkWellFormedXML(t, buf.Bytes())
+
+	svg := buf.String()
+	if n := strings.Count(svg, `<path class="edge"`); n != 3 {
+		t.Errorf("got %d edges, want 3 critical edges", n)
+	}
+	if n := strings.Count(svg, `<rect class="node"`); n != 4 {
+		t.Errorf("got %d nodes, want 4", n)
+	}
+	if !strings.Contains(svg, ">X&#34;&lt;Y&gt;</text>") {
+		t.Errorf("escaped display name not found in:\n%s", svg)
+	}
+}
@@ -128,3 +128,20 @@ func (g *Graph) Rename(f func(string) (string, error)) (*Graph, error) {
 
 	return ret, nil
 }
+
+// sortedNodeNames returns the sorted names of the nodes.
+func sortedNodeNames(g *Graph) []string {
+	names := make([]string, 0, len(g.Nodes))
+	for name := range g.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedOuts returns a sorted copy of the outputs of a node.
+func sortedOuts(g *Graph, name string) []string {
+	outs := append([]string(nil), g.Nodes[name]...)
+	sort.Strings(outs)
+	return outs
+}
@@ -0,0 +1,64 @@
+package dags
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type graphMLNode struct {
+	ID string `xml:"id,attr"`
+}
+
+type graphMLEdge struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string         `xml:"id,attr"`
+	EdgeDefault string         `xml:"edgedefault,attr"`
+	Nodes       []*graphMLNode `xml:"node"`
+	Edges       []*graphMLEdge `xml:"edge"`
+}
+
+type graphML struct {
+	XMLName xml.Name      `xml:"graphml"`
+	XMLNS   string        `xml:"xmlns,attr"`
+	Graph   *graphMLGraph `xml:"graph"`
+}
+
+// WriteGraphML writes the graph in GraphML format. Nodes and edges are
+// written in sorted order, so that the output is stable.
+func WriteGraphML(w io.Writer, g *Graph, name string) error {
+	graph := &graphMLGraph{
+		ID:          name,
+		EdgeDefault: "directed",
+	}
+	for _, n := range sortedNodeNames(g) {
+		graph.Nodes = append(graph.Nodes, &graphMLNode{ID: n})
+		for _, to := range sortedOuts(g, n) {
+			graph.Edges = append(graph.Edges, &graphMLEdge{
+				ID:     fmt.Sprintf("e%d", len(graph.Edges)),
+				Source: n,
+				Target: to,
+			})
+		}
+	}
+
+	doc := &graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graph,
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,175 @@
+package dags
+
+import (
+	"fmt"
+	"sort"
+)
+
+// incGraph is a graph with both directions of edges, for laying out
+// incrementally.
+type incGraph struct {
+	outs   map[string][]string
+	ins    map[string][]string
+	layers map[string]int // min layers
+	sorted []string       // sorted by layers, then names
+}
+
+func graphIns(g *Graph) (map[string][]string, error) {
+	ins := make(map[string][]string, len(g.Nodes))
+	for name := range g.Nodes {
+		ins[name] = nil
+	}
+	for from, outs := range g.Nodes {
+		for _, to := range outs {
+			if _, ok := g.Nodes[to]; !ok {
+				return nil, fmt.Errorf("missing node %q for %q", to, from)
+			}
+			ins[to] = append(ins[to], from)
+		}
+	}
+	return ins, nil
+}
+
+func newIncGraph(g *Graph) (*incGraph, error) {
+	ins, err := graphIns(g)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assigns min layers in topological order.
+	layers := make(map[string]int, len(g.Nodes))
+	nhit := make(map[string]int, len(g.Nodes))
+	sorted := make([]string, 0, len(g.Nodes))
+	var cur []string
+	for name := range g.Nodes {
+		if len(ins[name]) == 0 {
+			cur = append(cur, name)
+		}
+	}
+	for layer := 0; len(cur) > 0; layer++ {
+		sort.Strings(cur)
+		sorted = append(sorted, cur...)
+
+		var next []string
+		for _, name := range cur {
+			layers[name] = layer
+			for _, out := range g.Nodes[name] {
+				nhit[out]++
+				if nhit[out] == len(ins[out]) {
+					next = append(next, out)
+				}
+			}
+		}
+		cur = next
+	}
+	if len(layers) != len(g.Nodes) {
+		// Lets NewMap find the circle for the error message.
+		if _, err := NewMap(g); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf(
+			"graph has circle: %d nodes cannot be sorted",
+			len(g.Nodes)-len(layers),
+		)
+	}
+
+	return &incGraph{
+		outs:   g.Nodes,
+		ins:    ins,
+		layers: layers,
+		sorted: sorted,
+	}, nil
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	if len(a) == 1 {
+		return a[0] == b[0]
+	}
+	set := make(map[string]bool)
+	for _, s := range a {
+		set[s] = true
+	}
+	for _, s := range b {
+		if !set[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// affected returns the nodes that need to be placed again: nodes that are
+// new, or have different edges than in the old graph, and all the nodes
+// that depend on them. The other nodes have the same ancestors as in the
+// old graph, and hence the same layers and critical inputs.
+func (g *incGraph) affected(
+	old *Graph, oldIns map[string][]string,
+) map[string]bool {
+	ret := make(map[string]bool)
+	var stack []string
+	for _, name := range g.sorted {
+		ins, ok := oldIns[name]
+		if ok && sameSet(ins, g.ins[name]) &&
+			sameSet(old.Nodes[name], g.outs[name]) {
+			continue
+		}
+		ret[name] = true
+		stack = append(stack, name)
+	}
+
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, out := range g.outs[name] {
+			if !ret[out] {
+				ret[out] = true
+				stack = append(stack, out)
+			}
+		}
+	}
+	return ret
+}
+
+// reaches returns the nodes that can be reached from the node and have
+// a min layer lower than the given layer.
+func (g *incGraph) reaches(from string, layer int) map[string]bool {
+	visited := make(map[string]bool)
+	stack := []string{from}
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, out := range g.outs[name] {
+			if visited[out] || g.layers[out] >= layer {
+				continue
+			}
+			visited[out] = true
+			stack = append(stack, out)
+		}
+	}
+	return visited
+}
+
+// critIns returns the critical inputs of a node: the inputs that cannot
+// reach the node via another input.
+func (g *incGraph) critIns(name string) []string {
+	ins := g.ins[name]
+	layer := g.layers[name]
+	var ret []string
+	for _, in := range ins {
+		reached := g.reaches(in, layer)
+		crit := true
+		for _, other := range ins {
+			if other != in && reached[other] {
+				crit = false
+				break
+			}
+		}
+		if crit {
+			ret = append(ret, in)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,174 @@
+package dags
+
+import (
+	"sort"
+)
+
+// incNode is a node that is placed in an incremental layout.
+type incNode struct {
+	name     string
+	x, y     int
+	critIns  []string
+	critOuts []string
+}
+
+type incLayout struct {
+	g     *incGraph
+	nodes map[string]*incNode
+	taken map[int]map[int]bool // Taken slots, indexed by x and then y.
+}
+
+func (l *incLayout) tak(x int) map[int]bool {
+	m, ok := l.taken[x]
+	if !ok {
+		m = make(map[int]bool)
+		l.taken[x] = m
+	}
+	return m
+}
+
+// take marks the slots taken by a placed node, and by its critical output
+// edges that go across layers.
+func (l *incLayout) take(n *incNode) {
+	tak := l.tak(n.x)
+	tak[n.y-1] = true
+	tak[n.y] = true
+	tak[n.y+1] = true
+
+	xmax := n.x
+	for _, out := range n.critOuts {
+		if x := l.nodes[out].x; x > xmax {
+			xmax = x
+		}
+	}
+	for x := n.x + 1; x < xmax; x++ {
+		l.tak(x)[n.y] = true
+	}
+}
+
+// findY finds a free slot that is close to the average position of the
+// critical inputs. Unlike LayoutMap, it never uses negative positions, so
+// that the nodes that are not affected do not need to move.
+func (l *incLayout) findY(n *incNode) int {
+	y := 0
+	if len(n.critIns) > 0 {
+		sum := 0
+		for _, in := range n.critIns {
+			sum += l.nodes[in].y
+		}
+		y = (sum + len(n.critIns)/2) / len(n.critIns)
+	}
+
+	tak := l.tak(n.x)
+	for offset := 0; ; offset++ {
+		if !tak[y+offset] {
+			return y + offset
+		}
+		if y-offset >= 0 && !tak[y-offset] {
+			return y - offset
+		}
+	}
+}
+
+// LayoutIncremental lays out graph g by updating prev, which is a layout of
+// graph old. Nodes that are not affected by the changes keep their
+// positions; only new nodes, nodes with changed edges and nodes that depend
+// on them are placed again. When old or prev is nil, it performs a full
+// layout.
+func LayoutIncremental(old *Graph, prev *MapView, g *Graph) (
+	*MapView, error,
+) {
+	if old == nil || prev == nil || prev.IsTopDown {
+		_, v, err := Layout(g)
+		return v, err
+	}
+
+	oldIns, err := graphIns(old)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := newIncGraph(g)
+	if err != nil {
+		return nil, err
+	}
+
+	affected := cur.affected(old, oldIns)
+	l := &incLayout{
+		g:     cur,
+		nodes: make(map[string]*incNode),
+		taken: make(map[int]map[int]bool),
+	}
+
+	// Places the x positions, and finds the critical edges.
+	for _, name := range cur.sorted {
+		n := &incNode{name: name}
+		l.nodes[name] = n
+		if p := prev.Nodes[name]; p != nil && !affected[name] {
+			n.x = p.X
+			n.y = p.Y
+			n.critIns = p.CritIns
+			continue
+		}
+
+		affected[name] = true // Also affected if missing in prev.
+		n.x = cur.layers[name]
+		for _, in := range cur.ins[name] {
+			if x := l.nodes[in].x + 1; x > n.x {
+				n.x = x
+			}
+		}
+		n.critIns = cur.critIns(name)
+	}
+	for _, name := range cur.sorted {
+		for _, in := range l.nodes[name].critIns {
+			inNode := l.nodes[in]
+			inNode.critOuts = append(inNode.critOuts, name)
+		}
+	}
+
+	// Places the y positions of the affected nodes around the nodes that
+	// stay.
+	var placing []*incNode
+	for _, name := range cur.sorted {
+		n := l.nodes[name]
+		if affected[name] {
+			placing = append(placing, n)
+		} else {
+			l.take(n)
+		}
+	}
+	sort.SliceStable(placing, func(i, j int) bool {
+		a, b := placing[i], placing[j]
+		if a.x != b.x {
+			return a.x < b.x
+		}
+		return len(a.critIns) > len(b.critIns)
+	})
+	for _, n := range placing {
+		n.y = l.findY(n)
+		l.take(n)
+	}
+
+	return l.view(), nil
+}
+
+func (l *incLayout) view() *MapView {
+	v := &MapView{Nodes: make(map[string]*MapNodeView)}
+	for name, n := range l.nodes {
+		sort.Strings(n.critOuts)
+		v.Nodes[name] = &MapNodeView{
+			Name:     name,
+			X:        n.x,
+			Y:        n.y,
+			CritIns:  n.critIns,
+			CritOuts: n.critOuts,
+		}
+		if n.x+1 > v.Width {
+			v.Width = n.x + 1
+		}
+		if n.y+1 > v.Height {
+			v.Height = n.y + 1
+		}
+	}
+	return v
+}
@@ -0,0 +1,74 @@
+package dags
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// makeBenchGraph makes a graph like a large repository: many packages in
+// clusters, where packages mostly depend on packages in the same cluster,
+// and every cluster depends on a few common packages.
+func makeBenchGraph(nCluster, clusterSize int) *Graph {
+	r := rand.New(rand.NewSource(1))
+	nodes := make(map[string][]string)
+
+	const nCommon = 10
+	for i := range nCommon {
+		var outs []string
+		if i > 0 {
+			outs = []string{fmt.Sprintf("common%d", i-1)}
+		}
+		nodes[fmt.Sprintf("common%d", i)] = nil
+		for _, out := range outs {
+			nodes[out] = append(nodes[out], fmt.Sprintf("common%d", i))
+		}
+	}
+
+	for c := range nCluster {
+		name := func(i int) string { return fmt.Sprintf("c%d/p%d", c, i) }
+		for i := range clusterSize {
+			nodes[name(i)] = nil
+		}
+		common := fmt.Sprintf("common%d", r.Intn(nCommon))
+		nodes[common] = append(nodes[common], name(0))
+		for i := 1; i < clusterSize; i++ {
+			for range 3 {
+				from := name(r.Intn(i))
+				nodes[from] = append(nodes[from], name(i))
+			}
+		}
+	}
+	return NewGraph(nodes)
+}
+
+// changeBenchGraph adds a new package into a cluster.
+func changeBenchGraph(g *Graph) *Graph {
+	ret := copyGraph(g)
+	ret.Nodes["c7/new"] = nil
+	ret.Nodes["c7/p3"] = append(ret.Nodes["c7/p3"], "c7/new")
+	return ret
+}
+
+func BenchmarkLayoutFull(b *testing.B) {
+	g := changeBenchGraph(makeBenchGraph(200, 100))
+	for b.Loop() {
+		if _, _, err := Layout(g); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLayoutIncremental(b *testing.B) {
+	old := makeBenchGraph(200, 100)
+	_, prev, err := Layout(old)
+	if err != nil {
+		b.Fatal(err)
+	}
+	g := changeBenchGraph(old)
+	for b.Loop() {
+		if _, err := LayoutIncremental(old, prev, g); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
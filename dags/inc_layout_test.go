@@ -0,0 +1,110 @@
+package dags
+
+import (
+	"testing"
+)
+
+func copyGraph(g *Graph) *Graph {
+	nodes := make(map[string][]string)
+	for k, vs := range g.Nodes {
+		nodes[k] = append([]string(nil), vs...)
+	}
+	return NewGraph(nodes)
+}
+
+func checkNoOverlap(t *testing.T, v *MapView) {
+	t.Helper()
+	type pos struct{ x, y int }
+	taken := make(map[pos]string)
+	for name, n := range v.Nodes {
+		p := pos{n.X, n.Y}
+		if other, ok := taken[p]; ok {
+			t.Errorf("%q and %q both at %v", name, other, p)
+		}
+		taken[p] = name
+		if n.X >= v.Width || n.Y >= v.Height || n.Y < 0 {
+			t.Errorf("%q at %v is out of the map", name, p)
+		}
+		for _, out := range n.CritOuts {
+			if v.Nodes[out].X <= n.X {
+				t.Errorf("edge %q->%q goes backward", name, out)
+			}
+		}
+	}
+}
+
+func TestLayoutIncremental(t *testing.T) {
+	old := NewGraph(map[string][]string{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"d"},
+		"d": nil,
+		"e": {"f"},
+		"f": nil,
+	})
+	_, prev, err := Layout(old)
+	if err != nil {
+		t.Fatal("layout: ", err)
+	}
+
+	// Unchanged graph keeps all the positions.
+	v, err := LayoutIncremental(old, prev, copyGraph(old))
+	if err != nil {
+		t.Fatal("incremental layout: ", err)
+	}
+	for name, n := range prev.Nodes {
+		got := v.Nodes[name]
+		if got.X != n.X || got.Y != n.Y {
+			t.Errorf("%q moved from (%d, %d) to (%d, %d)",
+				name, n.X, n.Y, got.X, got.Y)
+		}
+	}
+
+	g := copyGraph(old)
+	g.Nodes["g"] = nil
+	g.Nodes["f"] = []string{"g"}
+	g.Nodes["d"] = []string{"h"}
+	g.Nodes["h"] = nil
+	g.Nodes["a"] = []string{"b", "c", "d"} // A redundant edge.
+
+	v, err = LayoutIncremental(old, prev, g)
+	if err != nil {
+		t.Fatal("incremental layout: ", err)
+	}
+	if len(v.Nodes) != len(g.Nodes) {
+		t.Fatalf("got %d nodes, want %d", len(v.Nodes), len(g.Nodes))
+	}
+	for _, name := range []string{"b", "c", "e"} {
+		n, got := prev.Nodes[name], v.Nodes[name]
+		if got.X != n.X || got.Y != n.Y {
+			t.Errorf("unaffected %q moved from (%d, %d) to (%d, %d)",
+				name, n.X, n.Y, got.X, got.Y)
+		}
+	}
+	checkNoOverlap(t, v)
+
+	if ins := v.Nodes["d"].CritIns; len(ins) != 2 {
+		t.Errorf("got critical ins %q of d, want b and c", ins)
+	}
+	if outs := v.Nodes["a"].CritOuts; len(outs) != 2 {
+		t.Errorf("got critical outs %q of a, want b and c", outs)
+	}
+
+	// Removing nodes.
+	g2 := g.Remove("c")
+	v2, err := LayoutIncremental(g, v, g2)
+	if err != nil {
+		t.Fatal("incremental layout after removing: ", err)
+	}
+	if _, ok := v2.Nodes["c"]; ok {
+		t.Error("removed node still in layout")
+	}
+	checkNoOverlap(t, v2)
+
+	// Circles are errors.
+	g3 := copyGraph(g2)
+	g3.Nodes["h"] = []string{"a"}
+	if _, err := LayoutIncremental(g2, v2, g3); err == nil {
+		t.Error("want error on graph with circle")
+	}
+}
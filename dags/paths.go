@@ -0,0 +1,101 @@
+package dags
+
+import (
+	"fmt"
+)
+
+// LongestPath returns the critical path of a DAG: the path that has the
+// largest total weight of its nodes. When weight is nil, every node weighs
+// one, and the path is the longest chain of dependencies. It returns the
+// path and its total weight. Ties are broken by node names.
+func LongestPath(g *Graph, weight func(name string) int) (
+	[]string, int, error,
+) {
+	order, err := TopoSort(g)
+	if err != nil {
+		return nil, 0, err
+	}
+	if weight == nil {
+		weight = func(string) int { return 1 }
+	}
+
+	// dist is the largest weight of the paths that end at a node.
+	dist := make(map[string]int)
+	prev := make(map[string]string)
+	for _, name := range order {
+		dist[name] += weight(name)
+		for _, out := range sortedOuts(g, name) {
+			if _, ok := prev[out]; !ok || dist[name] > dist[out] {
+				dist[out] = dist[name]
+				prev[out] = name
+			}
+		}
+	}
+
+	end := ""
+	for _, name := range order {
+		if end == "" || dist[name] > dist[end] {
+			end = name
+		}
+	}
+	if end == "" {
+		return nil, 0, nil
+	}
+
+	var path []string
+	for n := end; ; {
+		path = append(path, n)
+		p, ok := prev[n]
+		if !ok {
+			break
+		}
+		n = p
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, dist[end], nil
+}
+
+// ShortestPath returns a path with the fewest edges from one node to
+// another. It returns nil if the target cannot be reached. The graph can
+// have circles.
+func ShortestPath(g *Graph, from, to string) ([]string, error) {
+	for _, n := range []string{from, to} {
+		if _, ok := g.Nodes[n]; !ok {
+			return nil, fmt.Errorf("node %q not found", n)
+		}
+	}
+
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 && !hasKey(prev, to) {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, out := range sortedOuts(g, cur) {
+			if hasKey(prev, out) {
+				continue
+			}
+			prev[out] = cur
+			queue = append(queue, out)
+		}
+	}
+	if !hasKey(prev, to) {
+		return nil, nil
+	}
+
+	var path []string
+	for n := to; n != from; n = prev[n] {
+		path = append(path, n)
+	}
+	path = append(path, from)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+func hasKey(m map[string]string, k string) bool {
+	_, ok := m[k]
+	return ok
+}
@@ -0,0 +1,79 @@
+package dags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLongestPath(t *testing.T) {
+	g := NewGraph(map[string][]string{
+		"a": {"b", "d"},
+		"b": {"c"},
+		"c": {"e"},
+		"d": {"e"},
+		"e": nil,
+	})
+	path, n, err := LongestPath(g, nil)
+	if err != nil {
+		t.Fatal("longest path: ", err)
+	}
+	if want := []string{"a", "b", "c", "e"}; !reflect.DeepEqual(path, want) {
+		t.Errorf("got path %q, want %q", path, want)
+	}
+	if n != 4 {
+		t.Errorf("got length %d, want 4", n)
+	}
+
+	weights := map[string]int{"d": 10}
+	weight := func(name string) int { return weights[name] + 1 }
+	path, n, err = LongestPath(g, weight)
+	if err != nil {
+		t.Fatal("weighted longest path: ", err)
+	}
+	if want := []string{"a", "d", "e"}; !reflect.DeepEqual(path, want) {
+		t.Errorf("got weighted path %q, want %q", path, want)
+	}
+	if n != 13 {
+		t.Errorf("got weight %d, want 13", n)
+	}
+
+	path, _, err = LongestPath(NewGraph(nil), nil)
+	if err != nil || path != nil {
+		t.Errorf("got %q, %v on empty graph", path, err)
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	g := NewGraph(map[string][]string{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"a", "d"},
+		"d": {"e"},
+		"e": {"a"},
+		"f": nil,
+	})
+	for _, test := range []struct {
+		from, to string
+		want     []string
+	}{
+		{"a", "e", []string{"a", "b", "d", "e"}},
+		{"e", "c", []string{"e", "a", "c"}},
+		{"a", "a", []string{"a"}},
+		{"a", "f", nil},
+	} {
+		got, err := ShortestPath(g, test.from, test.to)
+		if err != nil {
+			t.Fatalf("path %s->%s: %s", test.from, test.to, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf(
+				"path %s->%s got %q, want %q",
+				test.from, test.to, got, test.want,
+			)
+		}
+	}
+
+	if _, err := ShortestPath(g, "a", "x"); err == nil {
+		t.Error("want error on missing node")
+	}
+}
@@ -0,0 +1,16 @@
+package dags
+
+// TransitiveReduction returns the transitive reduction of a DAG: the graph
+// with the fewest edges that has the same reachability. The kept edges are
+// the critical edges of the map of the graph.
+func TransitiveReduction(g *Graph) (*Graph, error) {
+	m, err := NewMap(g)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string][]string)
+	for name, node := range m.Nodes {
+		nodes[name] = makeNodeList(node.CritOuts)
+	}
+	return NewGraph(nodes), nil
+}
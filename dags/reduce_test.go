@@ -0,0 +1,35 @@
+package dags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransitiveReduction(t *testing.T) {
+	g := NewGraph(map[string][]string{
+		"a": {"b", "c", "d"},
+		"b": {"d"},
+		"c": {"d"},
+		"d": {"e"},
+		"e": nil,
+	})
+	r, err := TransitiveReduction(g)
+	if err != nil {
+		t.Fatal("reduce: ", err)
+	}
+	want := map[string][]string{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"d"},
+		"d": {"e"},
+		"e": nil,
+	}
+	if !reflect.DeepEqual(r.Nodes, want) {
+		t.Errorf("got %v, want %v", r.Nodes, want)
+	}
+
+	circle := NewGraph(map[string][]string{"a": {"b"}, "b": {"a"}})
+	if _, err := TransitiveReduction(circle); err == nil {
+		t.Error("want error on graph with circle")
+	}
+}
@@ -0,0 +1,102 @@
+package dags
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Sizes in pixels for rendering a map view into SVG.
+const (
+	svgColWidth  = 180
+	svgRowHeight = 14
+	svgNodeWidth = 150
+	svgNodeH     = 22
+	svgMargin    = 10
+)
+
+func svgEscape(s string) string {
+	b := new(strings.Builder)
+	xml.EscapeText(b, []byte(s))
+	return b.String()
+}
+
+func svgNodeLabel(n *MapNodeView) string {
+	if n.DisplayName != "" {
+		return n.DisplayName
+	}
+	return n.Name
+}
+
+func svgPos(n *MapNodeView) (x, y int) {
+	x = svgMargin + n.X*svgColWidth
+	y = svgMargin + n.Y*svgRowHeight + svgRowHeight/2
+	return x, y
+}
+
+// WriteSVG renders a map view into a standalone SVG image, with the nodes,
+// the critical edges and the labels.
+func WriteSVG(w io.Writer, v *MapView) error {
+	names := make([]string, 0, len(v.Nodes))
+	for name := range v.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	width := 2*svgMargin + v.Width*svgColWidth
+	height := 2*svgMargin + v.Height*svgRowHeight + svgNodeH
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out,
+		`<svg xmlns="http://www.w3.org/2000/svg" `+
+			`width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height,
+	)
+	fmt.Fprintln(out, `<style>`+
+		`.edge{fill:none;stroke:#999;stroke-width:1}`+
+		`.node{fill:#f4f4f4;stroke:#666;stroke-width:1}`+
+		`text{font-family:monospace;font-size:11px;fill:#222}`+
+		`</style>`)
+
+	fmt.Fprintln(out, `<g class="edges">`)
+	for _, name := range names {
+		n := v.Nodes[name]
+		x1, y1 := svgPos(n)
+		x1 += svgNodeWidth
+		for _, outName := range n.CritOuts {
+			to, ok := v.Nodes[outName]
+			if !ok {
+				return fmt.Errorf("missing node %q for %q", outName, name)
+			}
+			x2, y2 := svgPos(to)
+			mid := (x1 + x2) / 2
+			fmt.Fprintf(out,
+				`<path class="edge" d="M%d %d C%d %d %d %d %d %d"/>`+"\n",
+				x1, y1, mid, y1, mid, y2, x2, y2,
+			)
+		}
+	}
+	fmt.Fprintln(out, `</g>`)
+
+	fmt.Fprintln(out, `<g class="nodes">`)
+	for _, name := range names {
+		n := v.Nodes[name]
+		x, y := svgPos(n)
+		fmt.Fprintf(out, `<g><title>%s</title>`, svgEscape(name))
+		fmt.Fprintf(out,
+			`<rect class="node" x="%d" y="%d" width="%d" height="%d" `+
+				`rx="3"/>`,
+			x, y-svgNodeH/2, svgNodeWidth, svgNodeH,
+		)
+		fmt.Fprintf(out,
+			`<text x="%d" y="%d" dominant-baseline="middle">%s</text>`,
+			x+4, y, svgEscape(svgNodeLabel(n)),
+		)
+		fmt.Fprintln(out, `</g>`)
+	}
+	fmt.Fprintln(out, `</g>`)
+	fmt.Fprintln(out, `</svg>`)
+	return out.Flush()
+}
@@ -0,0 +1,114 @@
+package dagvis
+
+import (
+	"shanhu.io/g/aries"
+	"shanhu.io/g/dags"
+)
+
+// GraphInfo is the information of a named graph.
+type GraphInfo struct {
+	Name   string
+	Repo   string `json:",omitempty"`
+	File   string `json:",omitempty"`
+	Loaded bool
+	Nodes  int `json:",omitempty"`
+}
+
+// GraphList is the list of graphs served.
+type GraphList struct {
+	Graphs []*GraphInfo
+}
+
+// GetRequest is the request for fetching the layout of a graph.
+type GetRequest struct {
+	Name string
+
+	// Focus is an optional list of nodes to focus on. When set, only the
+	// nodes, their direct inputs and outputs, and the nodes in between are
+	// shown.
+	Focus []string `json:",omitempty"`
+
+	// Reverse layouts the graph from right to left, which shows the
+	// reverse dependencies.
+	Reverse bool `json:",omitempty"`
+}
+
+// SearchRequest is the request for searching nodes in a graph.
+type SearchRequest struct {
+	Name  string
+	Query string
+	Limit int `json:",omitempty"`
+}
+
+// SearchResult is the result of searching nodes in a graph.
+type SearchResult struct {
+	Nodes []string
+}
+
+// NameRequest is a request that only has the name of a graph.
+type NameRequest struct {
+	Name string
+}
+
+const defaultSearchLimit = 50
+
+func (gs *graphs) info(name string) *GraphInfo {
+	e := gs.entries[name]
+	info := &GraphInfo{
+		Name: name,
+		Repo: e.src.Repo,
+		File: e.src.File,
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.loaded != nil {
+		info.Loaded = true
+		info.Nodes = len(e.loaded.graph.Nodes)
+	}
+	return info
+}
+
+func (gs *graphs) apiList(c *aries.C) (*GraphList, error) {
+	list := new(GraphList)
+	for _, name := range gs.names {
+		list.Graphs = append(list.Graphs, gs.info(name))
+	}
+	return list, nil
+}
+
+func (gs *graphs) apiGet(c *aries.C, req *GetRequest) (*dags.M, error) {
+	return gs.view(req.Name, req.Focus, req.Reverse)
+}
+
+func (gs *graphs) apiSearch(c *aries.C, req *SearchRequest) (
+	*SearchResult, error,
+) {
+	g, err := gs.get(req.Name, false)
+	if err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	return &SearchResult{Nodes: g.search(req.Query, limit)}, nil
+}
+
+func (gs *graphs) apiReload(c *aries.C, req *NameRequest) (
+	*GraphInfo, error,
+) {
+	if _, err := gs.get(req.Name, true); err != nil {
+		return nil, err
+	}
+	return gs.info(req.Name), nil
+}
+
+func (gs *graphs) api() *aries.Router {
+	r := aries.NewRouter()
+	r.Call("list", gs.apiList)
+	r.Call("get", gs.apiGet)
+	r.Call("search", gs.apiSearch)
+	r.Call("reload", gs.apiReload)
+	return r
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"io"
@@ -26,7 +27,39 @@ func writeOutput(out string, bs []byte) error {
 	return os.WriteFile(out, bs, 0644)
 }
 
-func layout(in, out string) error {
+func export(g *dags.Graph, format string) ([]byte, error) {
+	if format == "dot" || format == "graphml" {
+		buf := new(bytes.Buffer)
+		var err error
+		if format == "dot" {
+			err = dags.WriteDOT(buf, g, "dag")
+		} else {
+			err = dags.WriteGraphML(buf, g, "dag")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	_, v, err := dags.Layout(g)
+	if err != nil {
+		return nil, errcode.Annotate(err, "layout graph")
+	}
+	switch format {
+	case "json":
+		return json.Marshal(dags.Output(v))
+	case "svg":
+		buf := new(bytes.Buffer)
+		if err := dags.WriteSVG(buf, v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, errcode.InvalidArgf("unknown format %q", format)
+}
+
+func layout(in, out, format string) error {
 	bs, err := readInput(in)
 	if err != nil {
 		return errcode.Annotate(err, "read input")
@@ -39,13 +72,7 @@ func layout(in, out string) error {
 		return errcode.Annotate(err, "parse graph")
 	}
 
-	_, v, err := dags.Layout(g)
-	if err != nil {
-		return errcode.Annotate(err, "layout graph")
-	}
-
-	m := dags.Output(v)
-	outBytes, err := json.Marshal(m)
+	outBytes, err := export(g, format)
 	if err != nil {
 		return errcode.Annotate(err, "encode output")
 	}
@@ -60,9 +87,12 @@ func layout(in, out string) error {
 func main() {
 	in := flag.String("in", "", "input file")
 	out := flag.String("out", "", "output file")
+	format := flag.String(
+		"format", "json", "output format: json, svg, dot or graphml",
+	)
 	flag.Parse()
 
-	if err := layout(*in, *out); err != nil {
+	if err := layout(*in, *out, *format); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,214 @@
+package dagvis
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"shanhu.io/g/dags"
+	"shanhu.io/std/errcode"
+)
+
+// loadedGraph is a graph loaded from a source. It is not changed after
+// loading, except the cached layouts.
+type loadedGraph struct {
+	graph *dags.Graph
+	m     *dags.Map
+	stamp string
+
+	views map[bool]*dags.M // full layouts, keyed by if reversed
+}
+
+func newLoadedGraph(g *dags.Graph, stamp string) (*loadedGraph, error) {
+	m, err := dags.NewMap(g)
+	if err != nil {
+		return nil, err
+	}
+	return &loadedGraph{
+		graph: g,
+		m:     m,
+		stamp: stamp,
+		views: make(map[bool]*dags.M),
+	}, nil
+}
+
+func layoutGraph(g *dags.Graph, rev bool) (*dags.M, error) {
+	layout := dags.Layout
+	if rev {
+		layout = dags.RevLayout
+	}
+	_, v, err := layout(g)
+	if err != nil {
+		return nil, err
+	}
+	return dags.Output(v), nil
+}
+
+// focusGraph returns the closure graph of the given nodes and their direct
+// inputs and outputs.
+func (g *loadedGraph) focusGraph(nodes []string) (*dags.Graph, error) {
+	set := make(map[string]bool)
+	for _, name := range nodes {
+		node, ok := g.m.Nodes[name]
+		if !ok {
+			return nil, errcode.NotFoundf("node %q not found", name)
+		}
+		set[name] = true
+		for in := range node.Ins {
+			set[in] = true
+		}
+		for out := range node.Outs {
+			set[out] = true
+		}
+	}
+	var lst []string
+	for name := range set {
+		lst = append(lst, name)
+	}
+	sort.Strings(lst)
+
+	m := dags.Closure(g.m, lst)
+	ret := make(map[string][]string)
+	for name, node := range m.Nodes {
+		var outs []string
+		for out := range node.Outs {
+			outs = append(outs, out)
+		}
+		sort.Strings(outs)
+		ret[name] = outs
+	}
+	return dags.NewGraph(ret), nil
+}
+
+// search returns the sorted names of the nodes that contain the query,
+// case insensitively.
+func (g *loadedGraph) search(query string, limit int) []string {
+	query = strings.ToLower(query)
+	var ret []string
+	for name := range g.graph.Nodes {
+		if strings.Contains(strings.ToLower(name), query) {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret
+}
+
+// graphEntry is a named graph that is loaded on demand, and reloaded when
+// the source changes.
+type graphEntry struct {
+	src *Source
+
+	mu      sync.Mutex
+	loaded  *loadedGraph
+	checked time.Time
+}
+
+// graphs is a set of named graphs.
+type graphs struct {
+	entries  map[string]*graphEntry
+	names    []string
+	now      func() time.Time
+	interval time.Duration // minimum interval for checking sources
+}
+
+func newGraphs(
+	srcs []*Source, now func() time.Time, interval time.Duration,
+) (*graphs, error) {
+	ret := &graphs{
+		entries:  make(map[string]*graphEntry),
+		now:      now,
+		interval: interval,
+	}
+	for _, src := range srcs {
+		if err := src.check(); err != nil {
+			return nil, err
+		}
+		if _, ok := ret.entries[src.Name]; ok {
+			return nil, errcode.InvalidArgf("duplicate graph %q", src.Name)
+		}
+		ret.entries[src.Name] = &graphEntry{src: src}
+		ret.names = append(ret.names, src.Name)
+	}
+	return ret, nil
+}
+
+func (gs *graphs) entry(name string) (*graphEntry, error) {
+	e, ok := gs.entries[name]
+	if !ok {
+		return nil, errcode.NotFoundf("graph %q not found", name)
+	}
+	return e, nil
+}
+
+// get returns the loaded graph of the given name. The source is checked
+// for changes at most once per interval, unless force is true.
+func (gs *graphs) get(name string, force bool) (*loadedGraph, error) {
+	e, err := gs.entry(name)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := gs.now()
+	if e.loaded != nil && !force && now.Sub(e.checked) < gs.interval {
+		return e.loaded, nil
+	}
+	stamp, err := e.src.fingerprint()
+	if err != nil {
+		return nil, errcode.Annotatef(err, "check graph %q", name)
+	}
+	e.checked = now
+	if e.loaded != nil && e.loaded.stamp == stamp {
+		return e.loaded, nil
+	}
+
+	g, err := e.src.load()
+	if err != nil {
+		return nil, errcode.Annotatef(err, "load graph %q", name)
+	}
+	loaded, err := newLoadedGraph(g, stamp)
+	if err != nil {
+		return nil, errcode.Annotatef(err, "load graph %q", name)
+	}
+	e.loaded = loaded
+	return loaded, nil
+}
+
+// view returns the layout of a named graph. When focus is not empty, it
+// only layouts the closure of the focused nodes.
+func (gs *graphs) view(name string, focus []string, rev bool) (
+	*dags.M, error,
+) {
+	g, err := gs.get(name, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(focus) > 0 {
+		sub, err := g.focusGraph(focus)
+		if err != nil {
+			return nil, err
+		}
+		return layoutGraph(sub, rev)
+	}
+
+	e := gs.entries[name]
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if v, ok := g.views[rev]; ok {
+		return v, nil
+	}
+	v, err := layoutGraph(g.graph, rev)
+	if err != nil {
+		return nil, err
+	}
+	g.views[rev] = v
+	return v, nil
+}
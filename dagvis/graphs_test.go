@@ -0,0 +1,167 @@
+package dagvis
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/dags"
+	"shanhu.io/g/httputil"
+	"shanhu.io/g/jsonutil"
+)
+
+func writeGraph(t *testing.T, f string, nodes map[string][]string) {
+	t.Helper()
+	if err := jsonutil.WriteFile(f, nodes); err != nil {
+		t.Fatal("write graph: ", err)
+	}
+}
+
+func nodeNames(m *dags.M) []string {
+	var names []string
+	for name := range m.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestGraphs(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "g.json")
+	writeGraph(t, f, map[string][]string{
+		"app":    {"server", "cli"},
+		"server": {"store"},
+		"cli":    {"store"},
+		"store":  {"bytes"},
+		"bytes":  nil,
+	})
+
+	now := time.Now()
+	gs, err := newGraphs(
+		[]*Source{{Name: "g", File: f}},
+		func() time.Time { return now },
+		time.Minute,
+	)
+	if err != nil {
+		t.Fatal("new graphs: ", err)
+	}
+
+	m, err := gs.view("g", nil, false)
+	if err != nil {
+		t.Fatal("view: ", err)
+	}
+	if len(m.Nodes) != 5 {
+		t.Errorf("got %d nodes, want 5", len(m.Nodes))
+	}
+	rev, err := gs.view("g", nil, true)
+	if err != nil {
+		t.Fatal("reverse view: ", err)
+	}
+	if len(rev.Nodes) != 5 {
+		t.Errorf("got %d nodes in reverse view, want 5", len(rev.Nodes))
+	}
+
+	focus, err := gs.view("g", []string{"server"}, false)
+	if err != nil {
+		t.Fatal("focus: ", err)
+	}
+	// cli is in between app and store.
+	want := []string{"app", "cli", "server", "store"}
+	if got := nodeNames(focus); !reflect.DeepEqual(got, want) {
+		t.Errorf("focus got nodes %q, want %q", got, want)
+	}
+	if _, err := gs.view("g", []string{"nothing"}, false); err == nil {
+		t.Error("want error on focusing missing node")
+	}
+
+	// Changes are not picked up until the check interval passes.
+	writeGraph(t, f, map[string][]string{"app": {"cli"}, "cli": nil})
+	if m, _ := gs.view("g", nil, false); len(m.Nodes) != 5 {
+		t.Errorf("got %d nodes before interval, want 5", len(m.Nodes))
+	}
+	now = now.Add(time.Minute)
+	if m, _ := gs.view("g", nil, false); len(m.Nodes) != 2 {
+		t.Errorf("got %d nodes after change, want 2", len(m.Nodes))
+	}
+
+	if _, err := gs.view("missing", nil, false); err == nil {
+		t.Error("want error on missing graph")
+	}
+}
+
+func TestGraphsAPI(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "g.json")
+	writeGraph(t, f, map[string][]string{
+		"net/http": {"net"},
+		"net":      {"io"},
+		"io":       nil,
+	})
+	gs, err := newGraphs(
+		[]*Source{{Name: "g", File: f}}, time.Now, time.Hour,
+	)
+	if err != nil {
+		t.Fatal("new graphs: ", err)
+	}
+	s := httptest.NewServer(aries.Serve(gs.api()))
+	defer s.Close()
+
+	client, err := httputil.NewClient(s.URL)
+	if err != nil {
+		t.Fatal("make client: ", err)
+	}
+	call := func(p string, req, resp any) {
+		t.Helper()
+		if err := client.Call(p, req, resp); err != nil {
+			t.Fatalf("call %q: %s", p, err)
+		}
+	}
+
+	list := new(GraphList)
+	call("/list", nil, list)
+	if len(list.Graphs) != 1 || list.Graphs[0].Loaded {
+		t.Errorf("got list %+v, want one unloaded graph", list.Graphs)
+	}
+
+	search := new(SearchResult)
+	call("/search", &SearchRequest{Name: "g", Query: "NET"}, search)
+	if want := []string{"net", "net/http"}; !reflect.DeepEqual(
+		search.Nodes, want,
+	) {
+		t.Errorf("search got %q, want %q", search.Nodes, want)
+	}
+
+	m := new(dags.M)
+	call("/get", &GetRequest{Name: "g", Focus: []string{"io"}}, m)
+	if got, want := nodeNames(m), []string{"io", "net"}; !reflect.DeepEqual(
+		got, want,
+	) {
+		t.Errorf("get focused got %q, want %q", got, want)
+	}
+
+	writeGraph(t, f, map[string][]string{"io": nil})
+	info := new(GraphInfo)
+	call("/reload", &NameRequest{Name: "g"}, info)
+	if !info.Loaded || info.Nodes != 1 {
+		t.Errorf("got %+v after reload, want 1 node", info)
+	}
+
+	if err := client.Call("/get", &GetRequest{Name: "x"}, m); err == nil {
+		t.Error("want error on getting missing graph")
+	}
+}
+
+func TestSourceCheck(t *testing.T) {
+	for _, src := range []*Source{
+		{Repo: "shanhu.io/g"},
+		{Name: "g"},
+		{Name: "g", Repo: "shanhu.io/g", File: "g.json"},
+	} {
+		if err := src.check(); err == nil {
+			t.Errorf("want error on invalid source %+v", src)
+		}
+	}
+}
@@ -3,27 +3,70 @@ package dagvis
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"shanhu.io/g/aries"
 	"shanhu.io/g/dags"
+	"shanhu.io/g/jsonutil"
 	"shanhu.io/g/osutil"
 	"shanhu.io/std/errcode"
 )
 
+// Config is the configuration of the server, saved in "etc/dagvis.json"
+// under the home directory.
+type Config struct {
+	Graphs []*Source
+
+	// CheckInterval is the minimum interval for checking if the source of
+	// a graph is changed, like "2s". Default is 2 seconds.
+	CheckInterval string `json:",omitempty"`
+}
+
 type server struct {
-	dag    *dags.M
+	dag    *dags.M // pre-computed graph, when no graph source is set
+	graphs *graphs
 	static *aries.StaticFiles
 	tmpls  *aries.Templates
 }
 
+func (s *server) indexGraph(c *aries.C) (string, *dags.M, error) {
+	if len(s.graphs.names) == 0 {
+		return "", s.dag, nil
+	}
+
+	q := c.Req.URL.Query()
+	name := q.Get("graph")
+	if name == "" {
+		name = s.graphs.names[0]
+	}
+	var focus []string
+	if f := q.Get("focus"); f != "" {
+		focus = strings.Split(f, ",")
+	}
+	m, err := s.graphs.view(name, focus, q.Get("rev") != "")
+	if err != nil {
+		return "", nil, err
+	}
+	return name, m, nil
+}
+
 func (s *server) serveIndex(c *aries.C) error {
+	name, m, err := s.indexGraph(c)
+	if err != nil {
+		return err
+	}
+
 	pageData := struct {
+		Name  string `json:",omitempty"`
 		Graph *dags.M
 	}{
-		Graph: s.dag,
+		Name:  name,
+		Graph: m,
 	}
 
 	dat := struct {
@@ -35,12 +78,18 @@ func (s *server) serveIndex(c *aries.C) error {
 	return s.tmpls.Serve(c, "dagview.html", &dat)
 }
 
-func makeService(home string) (aries.Service, error) {
-	h, err := osutil.NewHome(home)
-	if err != nil {
-		return nil, errcode.Annotate(err, "make new home")
+func readConfig(h *osutil.Home) (*Config, error) {
+	config := new(Config)
+	if err := jsonutil.ReadFile(h.Etc("dagvis.json"), config); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return config, nil
+		}
+		return nil, errcode.Annotate(err, "read config")
 	}
+	return config, nil
+}
 
+func readDAGView(h *osutil.Home) (*dags.M, error) {
 	m := new(dags.M)
 	dagBytes, err := os.ReadFile(h.Var("dagview.json"))
 	if err != nil {
@@ -49,12 +98,50 @@ func makeService(home string) (aries.Service, error) {
 	if err := json.Unmarshal(dagBytes, m); err != nil {
 		return nil, errcode.Annotate(err, "parse dagview.json")
 	}
+	return m, nil
+}
+
+func makeService(home string, repos []string) (aries.Service, error) {
+	h, err := osutil.NewHome(home)
+	if err != nil {
+		return nil, errcode.Annotate(err, "make new home")
+	}
+
+	config, err := readConfig(h)
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		config.Graphs = append(config.Graphs, &Source{
+			Name: repo,
+			Repo: repo,
+		})
+	}
+	interval := 2 * time.Second
+	if config.CheckInterval != "" {
+		d, err := time.ParseDuration(config.CheckInterval)
+		if err != nil {
+			return nil, errcode.Annotate(err, "parse check interval")
+		}
+		interval = d
+	}
+	gs, err := newGraphs(config.Graphs, time.Now, interval)
+	if err != nil {
+		return nil, err
+	}
 
 	s := &server{
-		dag:    m,
+		graphs: gs,
 		static: aries.NewStaticFiles(h.Lib("static")),
 		tmpls:  aries.NewTemplates(h.Lib("tmpl"), nil),
 	}
+	if len(gs.names) == 0 {
+		m, err := readDAGView(h)
+		if err != nil {
+			return nil, err
+		}
+		s.dag = m
+	}
 
 	serveStatic := s.static.Serve
 
@@ -63,6 +150,7 @@ func makeService(home string) (aries.Service, error) {
 	r.Get("style.css", serveStatic)
 	r.Dir("js", serveStatic)
 	r.Dir("jslib", serveStatic)
+	r.DirService("api", gs.api())
 
 	return r, nil
 }
@@ -71,9 +159,16 @@ func makeService(home string) (aries.Service, error) {
 func Main() {
 	addr := aries.DeclareAddrFlag("")
 	home := flag.String("home", ".", "home dir")
+	repos := flag.String(
+		"repos", "", "comma separated Go repositories to serve graphs of",
+	)
 	flag.Parse()
 
-	s, err := makeService(*home)
+	var repoList []string
+	if *repos != "" {
+		repoList = strings.Split(*repos, ",")
+	}
+	s, err := makeService(*home, repoList)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,86 @@
+package dagvis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"shanhu.io/g/dags"
+	"shanhu.io/g/godep"
+	"shanhu.io/g/goload"
+	"shanhu.io/g/hashutil"
+	"shanhu.io/g/jsonutil"
+	"shanhu.io/std/errcode"
+)
+
+// Source is the source of a named graph. Exactly one of Repo and File
+// should be set.
+type Source struct {
+	Name string
+
+	// Repo is the Go package path to load the package dependency graph
+	// from, like the smldag command.
+	Repo string `json:",omitempty"`
+
+	// File is a JSON file that saves the nodes of a graph, mapping from
+	// node names to their outputs.
+	File string `json:",omitempty"`
+}
+
+func (s *Source) check() error {
+	if s.Name == "" {
+		return errcode.InvalidArgf("graph name missing")
+	}
+	if (s.Repo == "") == (s.File == "") {
+		return errcode.InvalidArgf(
+			"graph %q needs exactly one of repo and file", s.Name,
+		)
+	}
+	return nil
+}
+
+func (s *Source) load() (*dags.Graph, error) {
+	if s.File != "" {
+		g := new(dags.Graph)
+		if err := jsonutil.ReadFile(s.File, &g.Nodes); err != nil {
+			return nil, errcode.Annotate(err, "read graph file")
+		}
+		return g, nil
+	}
+	return godep.RepoDep(s.Repo)
+}
+
+// fingerprint returns a hash that changes when the source changes. For a
+// repo, it covers the names, sizes and modification times of all the Go
+// files of all the packages.
+func (s *Source) fingerprint() (string, error) {
+	if s.File != "" {
+		return hashutil.HashFile(s.File)
+	}
+
+	res, err := goload.ScanPkgs(s.Repo, nil)
+	if err != nil {
+		return "", errcode.Annotate(err, "scan packages")
+	}
+	var pkgs []string
+	for p := range res.Pkgs {
+		pkgs = append(pkgs, p)
+	}
+	sort.Strings(pkgs)
+
+	b := new(strings.Builder)
+	for _, p := range pkgs {
+		pkg := res.Pkgs[p].Build
+		fmt.Fprintln(b, p)
+		for _, f := range pkg.GoFiles {
+			info, err := os.Stat(filepath.Join(pkg.Dir, f))
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintln(b, f, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return hashutil.HashStr(b.String()), nil
+}
@@ -0,0 +1,127 @@
+package gocheck
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"shanhu.io/std/lexing"
+)
+
+// ConfigFunc returns the config of the package in a directory.
+type ConfigFunc func(dir string) (*Config, error)
+
+func ruleEnabled(c *Config, r *Rule) bool {
+	for _, enabled := range enabledRules(c) {
+		if enabled.Name == r.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// analysisPos converts a position into a token position in the files. A
+// position that is out of the lines is moved to the end of the file. Nil
+// positions are moved to the package clause of the first file.
+func analysisPos(
+	fset *token.FileSet, files []*ast.File, p *lexing.Pos,
+) token.Pos {
+	if len(files) == 0 {
+		return token.NoPos
+	}
+	if p == nil {
+		return files[0].Package
+	}
+	for _, f := range files {
+		tf := fset.File(f.Pos())
+		if tf.Name() != p.File {
+			continue
+		}
+		if p.Line < 1 || p.Line > tf.LineCount() {
+			return tf.Pos(tf.Size())
+		}
+		end := tf.Size()
+		if p.Line < tf.LineCount() {
+			end = tf.Offset(tf.LineStart(p.Line+1)) - 1
+		}
+		offset := tf.Offset(tf.LineStart(p.Line)) + max(p.Col-1, 0)
+		return tf.Pos(min(offset, end))
+	}
+	return files[0].Package
+}
+
+// analyzerName returns a valid analyzer name for a rule name, where the
+// characters that are not allowed in Go identifiers are replaced by '_'.
+func analyzerName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// NewAnalyzer ports a rule into an analyzer of the go/analysis framework.
+// The diagnostics use the rule name as category, and respect the ignore
+// comments. config can be nil for using the default config.
+func NewAnalyzer(r *Rule, config ConfigFunc) *analysis.Analyzer {
+	run := func(pass *analysis.Pass) (any, error) {
+		if len(pass.Files) == 0 {
+			return nil, nil
+		}
+
+		var c *Config
+		if config != nil {
+			f := pass.Fset.File(pass.Files[0].Pos()).Name()
+			got, err := config(filepath.Dir(f))
+			if err != nil {
+				return nil, err
+			}
+			c = got
+		}
+		if !ruleEnabled(c, r) {
+			return nil, nil
+		}
+
+		p := &Pass{
+			Fset:   pass.Fset,
+			Files:  pass.Files,
+			Info:   pass.TypesInfo,
+			Pkg:    pass.Pkg,
+			Config: c,
+		}
+		ignores := newIgnoreSet(pass.Fset, pass.Files)
+		for _, err := range r.Check(p) {
+			if err.Code == "" {
+				err.Code = r.Name
+			}
+			if ignores.ignored(err) {
+				continue
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:      analysisPos(pass.Fset, pass.Files, err.Pos),
+				Category: err.Code,
+				Message:  errMessage(err),
+			})
+		}
+		return nil, nil
+	}
+
+	return &analysis.Analyzer{
+		Name: analyzerName(r.Name),
+		Doc:  r.Doc,
+		Run:  run,
+	}
+}
+
+// Analyzers returns the analyzers of all the registered rules.
+func Analyzers(config ConfigFunc) []*analysis.Analyzer {
+	var ret []*analysis.Analyzer
+	for _, r := range Rules() {
+		ret = append(ret, NewAnalyzer(r, config))
+	}
+	return ret
+}
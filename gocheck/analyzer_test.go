@@ -0,0 +1,75 @@
+package gocheck
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	analysischecker "golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestAnalyzers(t *testing.T) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.LoadAllSyntax,
+	}, "./testdata/rules")
+	if err != nil {
+		t.Fatal("load: ", err)
+	}
+
+	config := func(dir string) (*Config, error) {
+		if filepath.Base(dir) != "rules" {
+			t.Errorf("got config dir %q", dir)
+		}
+		return &Config{Width: 60, Disable: []string{"filedep"}}, nil
+	}
+	g, err := analysischecker.Analyze(Analyzers(config), pkgs, nil)
+	if err != nil {
+		t.Fatal("analyze: ", err)
+	}
+
+	var got []*ruleResult
+	for act := range g.All() {
+		if !act.IsRoot {
+			continue
+		}
+		if act.Err != nil {
+			t.Errorf("analyzer %s: %s", act.Analyzer.Name, act.Err)
+		}
+		for _, d := range act.Diagnostics {
+			pos := act.Package.Fset.Position(d.Pos)
+			if d.Category != act.Analyzer.Name {
+				t.Errorf("got category %q", d.Category)
+			}
+			got = append(got, &ruleResult{
+				rule: d.Category,
+				file: filepath.Base(pos.Filename),
+				line: pos.Line,
+			})
+		}
+	}
+	want := []*ruleResult{
+		{"linecomment", "a.go", 3},
+		{"linecomment", "b.go", 3},
+		{"rect", "b.go", 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for _, r := range got {
+			t.Errorf("got %+v", r)
+		}
+	}
+}
+
+func TestAnalyzerName(t *testing.T) {
+	for _, test := range []struct{ rule, want string }{
+		{"rect", "rect"},
+		{"test-nofunc", "test_nofunc"},
+	} {
+		if got := analyzerName(test.rule); got != test.want {
+			t.Errorf(
+				"analyzerName(%q): got %q, want %q",
+				test.rule, got, test.want,
+			)
+		}
+	}
+}
@@ -4,31 +4,44 @@ import (
 	"go/build"
 	"go/token"
 
-	"golang.org/x/tools/go/packages"
 	"shanhu.io/std/errcode"
 	"shanhu.io/std/lexing"
 )
 
+func (c *checker) check(config *Config) []*lexing.Error {
+	if err := checkConfig(config); err != nil {
+		return lexing.SingleErr(err)
+	}
+	p := &Pass{
+		Fset:   c.fset,
+		Files:  c.files,
+		Info:   c.info,
+		Pkg:    c.pkg,
+		Config: config,
+	}
+
+	var errs []*lexing.Error
+	for _, r := range enabledRules(config) {
+		for _, err := range r.Check(p) {
+			if err.Code == "" {
+				err.Code = r.Name
+			}
+			errs = append(errs, err)
+		}
+	}
+	return newIgnoreSet(c.fset, c.files).filter(errs)
+}
+
 // ModCheckAll performs all checks on the package.
 func ModCheckAll(dir, pkg string, h, w int) []*lexing.Error {
-	var loadMode packages.LoadMode
-	for _, m := range []packages.LoadMode{
-		packages.NeedTypes,
-		packages.NeedFiles,
-		packages.NeedTypesInfo,
-		packages.NeedModule,
-		packages.NeedSyntax,
-	} {
-		loadMode |= m
-	}
+	return ModCheck(dir, pkg, &Config{Height: h, Width: w})
+}
 
+// ModCheck runs the rules enabled in the config on the package in module
+// mode.
+func ModCheck(dir, pkg string, config *Config) []*lexing.Error {
 	fset := token.NewFileSet()
-	config := &packages.Config{
-		Mode: loadMode,
-		Dir:  dir,
-		Fset: fset,
-	}
-	pkgs, err := packages.Load(config, pkg)
+	pkgs, err := Load(&LoadConfig{Dir: dir, Fset: fset}, pkg)
 	if err != nil {
 		return lexing.SingleErr(err)
 	}
@@ -46,11 +59,16 @@ func ModCheckAll(dir, pkg string, h, w int) []*lexing.Error {
 		info:  p.TypesInfo,
 		pkg:   p.Types,
 	}
-	return c.checkAll(h, w)
+	return c.check(config)
 }
 
 // CheckAll checks everything for a package.
 func CheckAll(path string, h, w int) []*lexing.Error {
+	return Check(path, &Config{Height: h, Width: w})
+}
+
+// Check runs the rules enabled in the config on the package in GOPATH mode.
+func Check(path string, config *Config) []*lexing.Error {
 	l, err := newLoaderPath(&build.Default, path, nil)
 	if err != nil {
 		return lexing.SingleErr(err)
@@ -59,5 +77,5 @@ func CheckAll(path string, h, w int) []*lexing.Error {
 	if err != nil {
 		return lexing.SingleErr(err)
 	}
-	return c.checkAll(h, w)
+	return c.check(config)
 }
@@ -8,7 +8,6 @@ import (
 	"sort"
 
 	"shanhu.io/g/dags"
-	"shanhu.io/std/lexing"
 )
 
 type checker struct {
@@ -54,26 +53,3 @@ func (c *checker) depGraph() (*dags.Graph, error) {
 	}
 	return &dags.Graph{Nodes: ret}, nil
 }
-
-func (c *checker) checkRect(h, w int) []*lexing.Error {
-	names := listFileNames(c.fset, c.files)
-	return CheckRect(names, h, w)
-}
-
-func (c *checker) checkAll(h, w int) []*lexing.Error {
-	g, err := c.depGraph()
-	if err != nil {
-		return lexing.SingleErr(err)
-	}
-
-	if err := dags.CheckDAG(g); err != nil {
-		return lexing.SingleErr(err)
-	}
-
-	names := listFileNames(c.fset, c.files)
-	if errs := CheckRect(names, h, w); errs != nil {
-		return errs
-	}
-
-	return CheckLineComment(c.fset, c.files)
-}
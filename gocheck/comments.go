@@ -23,6 +23,9 @@ func validLineCommentContent(s string) bool {
 	if strings.HasPrefix(s, "go:build ") {
 		return true
 	}
+	if strings.HasPrefix(s, "gocheck:ignore ") {
+		return true
+	}
 	return false
 }
 
@@ -0,0 +1,35 @@
+package gocheck
+
+// Default text limits for the rect rule.
+const (
+	DefaultHeight = 300
+	DefaultWidth  = 80
+)
+
+// Config configures the rules to run on a package.
+type Config struct {
+	// Height and Width are the limits of the rect rule. Zero values use
+	// DefaultHeight and DefaultWidth.
+	Height int `json:",omitempty"`
+	Width  int `json:",omitempty"`
+
+	// Disable lists the rules to skip.
+	Disable []string `json:",omitempty"`
+
+	// Enable lists the rules that are off by default to run.
+	Enable []string `json:",omitempty"`
+}
+
+func (c *Config) rect() (h, w int) {
+	h, w = DefaultHeight, DefaultWidth
+	if c == nil {
+		return h, w
+	}
+	if c.Height > 0 {
+		h = c.Height
+	}
+	if c.Width > 0 {
+		w = c.Width
+	}
+	return h, w
+}
@@ -0,0 +1,51 @@
+package gocheck
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"shanhu.io/g/jsonutil"
+	"shanhu.io/std/errcode"
+)
+
+// ConfigFile is the name of the config file in a package directory.
+const ConfigFile = "gocheck.json"
+
+// ReadConfig reads a config file.
+func ReadConfig(f string) (*Config, error) {
+	c := new(Config)
+	if err := jsonutil.ReadFile(f, c); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(c); err != nil {
+		return nil, errcode.Annotatef(err, "check %q", f)
+	}
+	return c, nil
+}
+
+// FindConfig reads the config file that is the nearest to dir, searching
+// upwards until the root directory. It returns an empty config when there
+// is no config file.
+func FindConfig(dir, root string) (*Config, error) {
+	for {
+		f := filepath.Join(dir, ConfigFile)
+		c, err := ReadConfig(f)
+		if err == nil {
+			return c, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, errcode.Annotatef(err, "read %q", f)
+		}
+
+		if dir == root {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return new(Config), nil
+}
@@ -0,0 +1,79 @@
+package gocheck
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"shanhu.io/std/lexing"
+)
+
+const ignorePrefix = "//gocheck:ignore "
+
+type ignoreKey struct {
+	file string
+	line int
+	rule string
+}
+
+// ignoreSet saves the lines where rules are suppressed by comments like
+// "//gocheck:ignore rect linecomment". A comment suppresses the rules on
+// its own line, and on the next line.
+type ignoreSet map[ignoreKey]bool
+
+func newIgnoreSet(fset *token.FileSet, files []*ast.File) ignoreSet {
+	s := make(ignoreSet)
+	for _, f := range files {
+		for _, g := range f.Comments {
+			for _, c := range g.List {
+				s.addComment(fset.Position(c.Slash), c.Text)
+			}
+		}
+	}
+	return s
+}
+
+func (s ignoreSet) addComment(pos token.Position, text string) {
+	after, ok := strings.CutPrefix(text, ignorePrefix)
+	if !ok {
+		return
+	}
+	names := strings.FieldsFunc(after, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	for _, name := range names {
+		for _, line := range []int{pos.Line, pos.Line + 1} {
+			s[ignoreKey{file: pos.Filename, line: line, rule: name}] = true
+		}
+	}
+}
+
+func (s ignoreSet) ignored(err *lexing.Error) bool {
+	if err.Pos == nil {
+		return false
+	}
+	return s[ignoreKey{
+		file: err.Pos.File,
+		line: err.Pos.Line,
+		rule: err.Code,
+	}]
+}
+
+func (s ignoreSet) filter(errs []*lexing.Error) []*lexing.Error {
+	var ret []*lexing.Error
+	for _, err := range errs {
+		if !s.ignored(err) {
+			ret = append(ret, err)
+		}
+	}
+	return ret
+}
+
+// FilterIgnored removes the errors that are suppressed by the
+// "//gocheck:ignore" comments in the files, where the error codes are
+// the rule names.
+func FilterIgnored(
+	fset *token.FileSet, files []*ast.File, errs []*lexing.Error,
+) []*lexing.Error {
+	return newIgnoreSet(fset, files).filter(errs)
+}
@@ -0,0 +1,44 @@
+package gocheck
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/packages"
+	"shanhu.io/std/errcode"
+)
+
+// LoadMode is the mode that packages are loaded with for checking.
+const LoadMode = packages.NeedName | packages.NeedTypes |
+	packages.NeedFiles | packages.NeedTypesInfo | packages.NeedModule |
+	packages.NeedSyntax | packages.NeedForTest
+
+// LoadConfig configures how packages are loaded in module mode.
+type LoadConfig struct {
+	Dir   string         // directory to load the packages in
+	Env   []string       // environment of the go command; nil for current
+	Tests bool           // also load the test variants of the packages
+	Fset  *token.FileSet // file set to use; a new one if nil
+}
+
+// Load loads the packages that match the patterns in module mode, with
+// the syntax trees and the type information that the checks need. Errors
+// in the loaded packages are left in the packages.
+func Load(config *LoadConfig, patterns ...string) (
+	[]*packages.Package, error,
+) {
+	fset := config.Fset
+	if fset == nil {
+		fset = token.NewFileSet()
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  LoadMode,
+		Dir:   config.Dir,
+		Env:   config.Env,
+		Tests: config.Tests,
+		Fset:  fset,
+	}, patterns...)
+	if err != nil {
+		return nil, errcode.Annotate(err, "load packages")
+	}
+	return pkgs, nil
+}
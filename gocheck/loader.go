@@ -56,7 +56,9 @@ func (l *loader) listFiles() ([]*ast.File, error) {
 	var files []*ast.File
 	for _, baseName := range srcFiles {
 		filename := filepath.Join(l.buildPkg.Dir, baseName)
-		f, err := parser.ParseFile(l.fset, filename, nil, 0)
+		f, err := parser.ParseFile(
+			l.fset, filename, nil, parser.ParseComments,
+		)
 		if err != nil {
 			return nil, err
 		}
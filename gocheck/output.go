@@ -0,0 +1,170 @@
+package gocheck
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+
+	"shanhu.io/std/lexing"
+)
+
+// Finding is a check result in JSON format.
+type Finding struct {
+	Rule    string `json:",omitempty"`
+	File    string `json:",omitempty"`
+	Line    int    `json:",omitempty"`
+	Col     int    `json:",omitempty"`
+	Message string
+}
+
+func errMessage(err *lexing.Error) string {
+	if err.Err == nil {
+		return ""
+	}
+	return err.Err.Error()
+}
+
+// Findings converts check results into findings.
+func Findings(errs []*lexing.Error) []*Finding {
+	ret := make([]*Finding, 0, len(errs))
+	for _, err := range errs {
+		f := &Finding{
+			Rule:    err.Code,
+			Message: errMessage(err),
+		}
+		if err.Pos != nil {
+			f.File = err.Pos.File
+			f.Line = err.Pos.Line
+			f.Col = err.Pos.Col
+		}
+		ret = append(ret, f)
+	}
+	return ret
+}
+
+// WriteJSON writes the check results as a JSON list of findings.
+func WriteJSON(w io.Writer, errs []*lexing.Error) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Findings(errs))
+}
+
+// SARIF 2.1.0 log format, which code hosting services use for annotating
+// pull requests. Only the parts that gocheck uses are defined here.
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Version string      `json:"version"`
+	Schema  string      `json:"$schema"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    *sarifTool     `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver *sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string       `json:"name"`
+	Rules []*sarifRule `json:"rules,omitempty"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID               string     `json:"id"`
+	ShortDescription *sarifText `json:"shortDescription,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string           `json:"ruleId,omitempty"`
+	Level     string           `json:"level"`
+	Message   *sarifText       `json:"message"`
+	Locations []*sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation *sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion   `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// sarifURI returns the file path relative to root in slash form, when the
+// file is under root.
+func sarifURI(file, root string) string {
+	if root != "" {
+		rel, err := filepath.Rel(root, file)
+		if err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(file)
+}
+
+func sarifResultOf(err *lexing.Error, root string) *sarifResult {
+	ret := &sarifResult{
+		RuleID:  err.Code,
+		Level:   "error",
+		Message: &sarifText{Text: errMessage(err)},
+	}
+	if err.Pos != nil && err.Pos.File != "" {
+		ret.Locations = []*sarifLocation{{
+			PhysicalLocation: &sarifPhysicalLocation{
+				ArtifactLocation: &sarifArtifact{
+					URI: sarifURI(err.Pos.File, root),
+				},
+				Region: &sarifRegion{
+					StartLine:   err.Pos.Line,
+					StartColumn: err.Pos.Col,
+				},
+			},
+		}}
+	}
+	return ret
+}
+
+// WriteSARIF writes the check results in SARIF format. File paths under
+// root are written as relative paths.
+func WriteSARIF(w io.Writer, errs []*lexing.Error, root string) error {
+	driver := &sarifDriver{Name: "gocheck"}
+	for _, r := range Rules() {
+		driver.Rules = append(driver.Rules, &sarifRule{
+			ID:               r.Name,
+			ShortDescription: &sarifText{Text: r.Doc},
+		})
+	}
+	run := &sarifRun{
+		Tool:    &sarifTool{Driver: driver},
+		Results: make([]*sarifResult, 0, len(errs)),
+	}
+	for _, err := range errs {
+		run.Results = append(run.Results, sarifResultOf(err, root))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&sarifLog{
+		Version: "2.1.0",
+		Schema:  sarifSchema,
+		Runs:    []*sarifRun{run},
+	})
+}
@@ -0,0 +1,159 @@
+package gocheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"sync"
+
+	"shanhu.io/g/dags"
+	"shanhu.io/std/errcode"
+	"shanhu.io/std/lexing"
+)
+
+// Pass is a loaded package for rules to check.
+type Pass struct {
+	Fset   *token.FileSet
+	Files  []*ast.File
+	Info   *types.Info
+	Pkg    *types.Package
+	Config *Config
+}
+
+// Rule is a named check on a package. The errors returned by Check use the
+// rule name as their code when the code is not set.
+type Rule struct {
+	Name string
+	Doc  string
+
+	// Off disables the rule unless it is enabled in the config.
+	Off bool
+
+	Check func(p *Pass) []*lexing.Error
+}
+
+var builtinRules = []*Rule{{
+	Name:  "filedep",
+	Doc:   "files in a package do not depend on each other in circles",
+	Check: checkFileDep,
+}, {
+	Name:  "rect",
+	Doc:   "files are within the height and width limits",
+	Check: checkRect,
+}, {
+	Name:  "linecomment",
+	Doc:   "line comments start with a space",
+	Check: checkLineComment,
+}}
+
+type registry struct {
+	mu    sync.Mutex
+	rules map[string]*Rule
+}
+
+var rules = newRegistry(builtinRules)
+
+func newRegistry(rules []*Rule) *registry {
+	r := &registry{rules: make(map[string]*Rule)}
+	for _, rule := range rules {
+		r.rules[rule.Name] = rule
+	}
+	return r
+}
+
+// Register adds a rule. It panics if a rule of the same name is already
+// registered.
+func Register(r *Rule) {
+	rules.mu.Lock()
+	defer rules.mu.Unlock()
+
+	if _, ok := rules.rules[r.Name]; ok {
+		panic(fmt.Errorf("rule %q already registered", r.Name))
+	}
+	rules.rules[r.Name] = r
+}
+
+// Rules returns all the registered rules, sorted by name.
+func Rules() []*Rule {
+	rules.mu.Lock()
+	defer rules.mu.Unlock()
+
+	var ret []*Rule
+	for _, r := range rules.rules {
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// LookupRule returns the rule of the given name, or nil if the rule is not
+// registered.
+func LookupRule(name string) *Rule {
+	rules.mu.Lock()
+	defer rules.mu.Unlock()
+	return rules.rules[name]
+}
+
+func checkConfig(c *Config) error {
+	if c == nil {
+		return nil
+	}
+	for _, lst := range [][]string{c.Disable, c.Enable} {
+		for _, name := range lst {
+			if LookupRule(name) == nil {
+				return errcode.InvalidArgf("unknown rule %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// enabledRules returns the rules to run under the config.
+func enabledRules(c *Config) []*Rule {
+	disabled := make(map[string]bool)
+	enabled := make(map[string]bool)
+	if c != nil {
+		for _, name := range c.Disable {
+			disabled[name] = true
+		}
+		for _, name := range c.Enable {
+			enabled[name] = true
+		}
+	}
+
+	var ret []*Rule
+	for _, r := range Rules() {
+		if disabled[r.Name] || (r.Off && !enabled[r.Name]) {
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func checkFileDep(p *Pass) []*lexing.Error {
+	c := &checker{
+		fset:  p.Fset,
+		files: p.Files,
+		info:  p.Info,
+		pkg:   p.Pkg,
+	}
+	g, err := c.depGraph()
+	if err != nil {
+		return lexing.SingleErr(err)
+	}
+	return lexing.SingleErr(dags.CheckDAG(g))
+}
+
+func checkRect(p *Pass) []*lexing.Error {
+	h, w := p.Config.rect()
+	return CheckRect(listFileNames(p.Fset, p.Files), h, w)
+}
+
+func checkLineComment(p *Pass) []*lexing.Error {
+	return CheckLineComment(p.Fset, p.Files)
+}
@@ -0,0 +1,180 @@
+package gocheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"shanhu.io/std/lexing"
+)
+
+func testChecker(t *testing.T, dir string) *checker {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"a.go", "b.go"} {
+		f, err := parser.ParseFile(
+			fset, filepath.Join(dir, name), nil, parser.ParseComments,
+		)
+		if err != nil {
+			t.Fatal("parse: ", err)
+		}
+		files = append(files, f)
+	}
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	pkg, err := new(types.Config).Check("rules", fset, files, info)
+	if err != nil {
+		t.Fatal("type check: ", err)
+	}
+	return &checker{fset: fset, files: files, info: info, pkg: pkg}
+}
+
+type ruleResult struct {
+	rule string
+	file string
+	line int
+}
+
+func ruleResults(errs []*lexing.Error) []*ruleResult {
+	var ret []*ruleResult
+	for _, err := range errs {
+		r := &ruleResult{rule: err.Code}
+		if err.Pos != nil {
+			r.file = filepath.Base(err.Pos.File)
+			r.line = err.Pos.Line
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func TestCheckRules(t *testing.T) {
+	c := testChecker(t, "testdata/rules")
+
+	for _, test := range []struct {
+		config *Config
+		want   []*ruleResult
+	}{{
+		config: &Config{Width: 60},
+		want: []*ruleResult{
+			{"linecomment", "a.go", 3},
+			{"linecomment", "b.go", 3},
+			{"rect", "b.go", 5},
+		},
+	}, {
+		config: &Config{Width: 60, Disable: []string{"linecomment"}},
+		want:   []*ruleResult{{"rect", "b.go", 5}},
+	}, {
+		// The height error of a.go is on line 9, and is ignored by the
+		// comment on line 8.
+		config: &Config{Height: 4},
+		want: []*ruleResult{
+			{"linecomment", "a.go", 3},
+			{"linecomment", "b.go", 3},
+			{"rect", "b.go", 6},
+		},
+	}} {
+		got := ruleResults(c.check(test.config))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("config %+v:", test.config)
+			for _, r := range got {
+				t.Errorf("  got %+v", r)
+			}
+		}
+	}
+
+	errs := c.check(&Config{Disable: []string{"nothing"}})
+	if len(errs) != 1 || errs[0].Pos != nil {
+		t.Errorf("want an error on unknown rule, got %v", errs)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := &Rule{
+		Name: "test-nofunc",
+		Off:  true,
+		Check: func(p *Pass) []*lexing.Error {
+			errs := lexing.NewErrorList()
+			for _, f := range p.Files {
+				for _, d := range f.Decls {
+					errs.Errorf(tokenPos(p.Fset, d.Pos()), "no decls")
+				}
+			}
+			return errs.Errs()
+		},
+	}
+	Register(r)
+	defer func() {
+		rules.mu.Lock()
+		delete(rules.rules, r.Name)
+		rules.mu.Unlock()
+	}()
+
+	c := testChecker(t, "testdata/rules")
+	config := &Config{Disable: []string{"linecomment"}}
+	if errs := c.check(config); len(errs) != 0 {
+		t.Errorf("rule off by default got run: %v", errs)
+	}
+	config.Enable = []string{r.Name}
+	got := ruleResults(c.check(config))
+	want := []*ruleResult{
+		{r.Name, "a.go", 8},
+		{r.Name, "b.go", 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	dir := t.TempDir()
+	errs := []*lexing.Error{{
+		Pos:  &lexing.Pos{File: filepath.Join(dir, "a/b.go"), Line: 3, Col: 2},
+		Err:  errFake("bad thing"),
+		Code: "rect",
+	}, {
+		Err:  errFake("no position"),
+		Code: "filedep",
+	}}
+
+	buf := new(bytes.Buffer)
+	if err := WriteSARIF(buf, errs, dir); err != nil {
+		t.Fatal("write SARIF: ", err)
+	}
+	log := new(sarifLog)
+	if err := json.Unmarshal(buf.Bytes(), log); err != nil {
+		t.Fatal("parse SARIF: ", err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 2 {
+		t.Fatalf("got %s", buf.String())
+	}
+	res := log.Runs[0].Results[0]
+	loc := res.Locations[0].PhysicalLocation
+	if res.RuleID != "rect" || loc.ArtifactLocation.URI != "a/b.go" ||
+		loc.Region.StartLine != 3 {
+		t.Errorf("got result %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteJSON(buf, errs); err != nil {
+		t.Fatal("write JSON: ", err)
+	}
+	var findings []*Finding
+	if err := json.Unmarshal(buf.Bytes(), &findings); err != nil {
+		t.Fatal("parse JSON: ", err)
+	}
+	if !reflect.DeepEqual(findings, Findings(errs)) {
+		t.Errorf("got findings %s", buf.String())
+	}
+}
+
+type errFake string
+
+func (e errFake) Error() string { return string(e) }
@@ -0,0 +1,8 @@
+package rules
+
+//bad comment
+
+//gocheck:ignore linecomment
+//ignored comment
+
+func a() int { return b() } //gocheck:ignore rect // a very long line, over the limit
@@ -0,0 +1,5 @@
+package rules
+
+//another bad comment
+
+func b() int { return 1 } // this line is a bit too wide for the test
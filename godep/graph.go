@@ -0,0 +1,144 @@
+package godep
+
+import (
+	"sort"
+	"strings"
+
+	"shanhu.io/g/dags"
+)
+
+// Import is an import edge of a package.
+type Import struct {
+	Path  string   // import path
+	Files []string // names of the files that have the import
+}
+
+// Package is a package in a dependency graph.
+type Package struct {
+	Path    string
+	Module  string // module path; empty for std packages
+	Imports map[string]*Import
+}
+
+func (p *Package) addImport(path, file string) {
+	imp, ok := p.Imports[path]
+	if !ok {
+		imp = &Import{Path: path}
+		p.Imports[path] = imp
+	}
+	for _, f := range imp.Files {
+		if f == file {
+			return
+		}
+	}
+	imp.Files = append(imp.Files, file)
+	sort.Strings(imp.Files)
+}
+
+// Graph is a package dependency graph, where packages know the modules
+// that they belong to.
+type Graph struct {
+	Main string // path of the main module
+	Pkgs map[string]*Package
+}
+
+// edges returns the imports of each package that are in the graph.
+func (g *Graph) edges() map[string][]string {
+	ret := make(map[string][]string)
+	for name, pkg := range g.Pkgs {
+		var imps []string
+		for imp := range pkg.Imports {
+			if _, ok := g.Pkgs[imp]; ok {
+				imps = append(imps, imp)
+			}
+		}
+		sort.Strings(imps)
+		ret[name] = imps
+	}
+	return ret
+}
+
+// PkgGraph returns the package level graph. Like PkgDep, the edges point
+// from the imported packages to the importing ones.
+func (g *Graph) PkgGraph() *dags.Graph {
+	return dags.NewGraph(g.edges()).Reverse()
+}
+
+// EdgeFiles returns the names of the files in package from that import
+// package to.
+func (g *Graph) EdgeFiles(from, to string) []string {
+	pkg, ok := g.Pkgs[from]
+	if !ok {
+		return nil
+	}
+	imp, ok := pkg.Imports[to]
+	if !ok {
+		return nil
+	}
+	return imp.Files
+}
+
+// Modules returns the packages grouped by module path. Std packages are
+// grouped under "std".
+func (g *Graph) Modules() map[string][]string {
+	ret := make(map[string][]string)
+	for name, pkg := range g.Pkgs {
+		mod := moduleName(pkg)
+		ret[mod] = append(ret[mod], name)
+	}
+	for _, pkgs := range ret {
+		sort.Strings(pkgs)
+	}
+	return ret
+}
+
+func moduleName(pkg *Package) string {
+	if pkg.Module == "" {
+		return "std"
+	}
+	return pkg.Module
+}
+
+// ModGraph returns the module level graph, where a module depends on
+// another module if any of its packages imports a package of the other
+// module. Like PkgGraph, the edges point from the imported modules to the
+// importing ones.
+func (g *Graph) ModGraph() *dags.Graph {
+	sets := make(map[string]map[string]bool)
+	for name, imps := range g.edges() {
+		from := moduleName(g.Pkgs[name])
+		set, ok := sets[from]
+		if !ok {
+			set = make(map[string]bool)
+			sets[from] = set
+		}
+		for _, imp := range imps {
+			if to := moduleName(g.Pkgs[imp]); to != from {
+				set[to] = true
+			}
+		}
+	}
+
+	nodes := make(map[string][]string)
+	for mod, set := range sets {
+		var lst []string
+		for to := range set {
+			lst = append(lst, to)
+		}
+		sort.Strings(lst)
+		nodes[mod] = lst
+	}
+	return dags.NewGraph(nodes).Reverse()
+}
+
+// trimRepo renames the nodes in the graph to be relative to repo, where
+// the repo itself is named "~".
+func trimRepo(g *dags.Graph, repo string) (*dags.Graph, error) {
+	repoSlash := repo + "/"
+	return g.Rename(func(name string) (string, error) {
+		if name == repo {
+			return "~", nil
+		}
+		return strings.TrimPrefix(name, repoSlash), nil
+	})
+}
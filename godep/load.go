@@ -0,0 +1,164 @@
+package godep
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"shanhu.io/g/dags"
+	"shanhu.io/std/errcode"
+)
+
+// Config configures how a dependency graph is loaded.
+type Config struct {
+	Dir   string   // directory to load the packages in
+	Env   []string // environment of the go command; nil for the current
+	Tests bool     // include the imports of the tests
+	Deps  bool     // include the non-std dependencies of the packages
+}
+
+// pkgName returns the name of the loaded package in the graph. The test
+// variants of a package are merged into the package. It returns an empty
+// string for test binaries.
+func pkgName(pkg *packages.Package) string {
+	if strings.HasSuffix(pkg.PkgPath, ".test") && pkg.Name == "main" {
+		return ""
+	}
+	return pkg.PkgPath
+}
+
+// testName returns the name of the node for the test files of a package.
+// The test files of a package and its external test package share the
+// node, which has the "_test" suffix, so that the imports of the tests do
+// not make cycles with the imports of the packages.
+func testName(path string) string { return path + "_test" }
+
+type graphLoader struct {
+	fset  *token.FileSet
+	graph *Graph
+}
+
+func (l *graphLoader) node(name string, pkg *packages.Package) *Package {
+	if p, ok := l.graph.Pkgs[name]; ok {
+		return p
+	}
+	p := &Package{
+		Path:    name,
+		Imports: make(map[string]*Import),
+	}
+	if mod := pkg.Module; mod != nil {
+		p.Module = mod.Path
+		if mod.Main {
+			l.graph.Main = mod.Path
+		}
+	}
+	l.graph.Pkgs[name] = p
+	return p
+}
+
+func (l *graphLoader) add(pkg *packages.Package) error {
+	name := pkgName(pkg)
+	if name == "" {
+		return nil
+	}
+	p := l.node(name, pkg)
+	// The package itself, with its test files, when compiled for its tests.
+	forTest := pkg.ForTest != "" && pkg.ForTest == pkg.PkgPath
+
+	for _, f := range pkg.GoFiles {
+		parsed, err := parser.ParseFile(l.fset, f, nil, parser.ImportsOnly)
+		if err != nil {
+			return errcode.Annotatef(err, "parse imports of %q", f)
+		}
+		base := filepath.Base(f)
+		from := p
+		if forTest && strings.HasSuffix(base, "_test.go") {
+			from = nil // created when there is an import
+		}
+		for _, imp := range parsed.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return errcode.Annotatef(err, "parse import in %q", f)
+			}
+			if path == "C" || path == name {
+				continue
+			}
+			if from == nil {
+				from = l.node(testName(name), pkg)
+			}
+			from.addImport(path, base)
+		}
+	}
+	return nil
+}
+
+// Load loads the dependency graph of the packages that match the patterns,
+// using the go command in module mode.
+func Load(config *Config, patterns ...string) (*Graph, error) {
+	mode := packages.NeedName | packages.NeedFiles |
+		packages.NeedImports | packages.NeedModule | packages.NeedForTest
+	if config.Deps {
+		mode |= packages.NeedDeps
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  mode,
+		Dir:   config.Dir,
+		Env:   config.Env,
+		Tests: config.Tests,
+	}, patterns...)
+	if err != nil {
+		return nil, errcode.Annotate(err, "load packages")
+	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, errcode.Annotatef(
+				pkg.Errors[0], "load package %q", pkg.PkgPath,
+			)
+		}
+	}
+
+	l := &graphLoader{
+		fset:  token.NewFileSet(),
+		graph: &Graph{Pkgs: make(map[string]*Package)},
+	}
+	roots := make(map[*packages.Package]bool)
+	for _, pkg := range pkgs {
+		roots[pkg] = true
+	}
+	var visitErr error
+	visit := func(pkg *packages.Package) bool {
+		if visitErr != nil {
+			return false
+		}
+		if pkg.Module == nil && !roots[pkg] {
+			return false // std dependency
+		}
+		visitErr = l.add(pkg)
+		return config.Deps
+	}
+	packages.Visit(pkgs, visit, nil)
+	if visitErr != nil {
+		return nil, visitErr
+	}
+	return l.graph, nil
+}
+
+// DirDep returns the dependency graph of all the packages in the module of
+// config.Dir. Package names are trimmed like RepoDep. When modules is true,
+// it returns the module level graph instead, which also covers the
+// required modules.
+func DirDep(config *Config, modules bool) (*dags.Graph, error) {
+	c := *config
+	c.Deps = modules
+	g, err := Load(&c, "./...")
+	if err != nil {
+		return nil, err
+	}
+	if modules {
+		return g.ModGraph(), nil
+	}
+	return trimRepo(g.PkgGraph(), g.Main)
+}
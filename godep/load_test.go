@@ -0,0 +1,89 @@
+package godep
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":      "module example.com/x\n\ngo 1.21\n",
+		"a/a.go":      "package a\n\nimport \"fmt\"\n\nvar A = fmt.Sprint()\n",
+		"a/a_test.go": "package a\n\nimport _ \"example.com/x/c\"\n",
+		"b/b.go":      "package b\n\nimport \"example.com/x/a\"\n\nvar B = a.A",
+		"b/b2.go":     "package b\n\nimport _ \"example.com/x/a\"\n",
+		"b/b3.go":     "package b\n\nimport _ \"example.com/x/c\"\n",
+		"c/c.go":      "package c\n",
+		"c/c_test.go": "package c_test\n\nimport _ \"example.com/x/b\"\n",
+		"c/x_test.go": "package c_test\n\nimport _ \"example.com/x/c\"\n",
+		"c/y_test.go": "package c\n\nimport _ \"example.com/x/a\"\n",
+	} {
+		f := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	for _, test := range []struct {
+		tests bool
+		want  map[string][]string
+	}{{
+		tests: false,
+		want: map[string][]string{
+			"a": {"b"},
+			"b": nil,
+			"c": {"b"},
+		},
+	}, {
+		tests: true,
+		// The tests of a and c import each other, and the external test
+		// of c imports b, which imports c. These do not make cycles, as
+		// the tests are separate nodes.
+		want: map[string][]string{
+			"a":      {"b", "c_test"},
+			"a_test": nil,
+			"b":      {"c_test"},
+			"c":      {"a_test", "b", "c_test"},
+			"c_test": nil,
+		},
+	}} {
+		config := &Config{Dir: dir, Env: env, Tests: test.tests}
+		g, err := DirDep(config, false)
+		if err != nil {
+			t.Fatalf("load with tests=%t: %s", test.tests, err)
+		}
+		if !reflect.DeepEqual(g.Nodes, test.want) {
+			t.Errorf(
+				"load with tests=%t: got %v, want %v",
+				test.tests, g.Nodes, test.want,
+			)
+		}
+	}
+
+	g, err := Load(&Config{Dir: dir, Env: env}, "./...")
+	if err != nil {
+		t.Fatal("load: ", err)
+	}
+	if g.Main != "example.com/x" {
+		t.Errorf("got main module %q", g.Main)
+	}
+	files := g.EdgeFiles("example.com/x/b", "example.com/x/a")
+	if want := []string{"b.go", "b2.go"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("got edge files %q, want %q", files, want)
+	}
+
+	mods := g.ModGraph()
+	want := map[string][]string{
+		"example.com/x": nil,
+	}
+	if !reflect.DeepEqual(mods.Nodes, want) {
+		t.Errorf("got module graph %v, want %v", mods.Nodes, want)
+	}
+}
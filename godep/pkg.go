@@ -8,6 +8,7 @@ import (
 
 	"golang.org/x/tools/go/buildutil"
 	"shanhu.io/g/dags"
+	"shanhu.io/g/goload"
 )
 
 func skipPkg(p string) bool {
@@ -130,3 +131,24 @@ func StdDep() (*dags.Graph, error) {
 	pkgs := ListStdPkgs()
 	return PkgDep(pkgs)
 }
+
+// RepoDep returns the dependency graph for all the packages under a
+// repository. Package names are trimmed to be relative to the repository,
+// and the repository package itself is named "~". It returns the graph for
+// Go std library when repo is empty.
+func RepoDep(repo string) (*dags.Graph, error) {
+	if repo == "" {
+		return StdDep()
+	}
+
+	pkgs, err := goload.ListPkgs(repo)
+	if err != nil {
+		return nil, err
+	}
+	g, err := PkgDep(pkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	return trimRepo(g, repo)
+}
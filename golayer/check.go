@@ -0,0 +1,158 @@
+package golayer
+
+import (
+	"fmt"
+	"go/build"
+	"go/token"
+	"sort"
+	"strings"
+
+	"shanhu.io/std/lexing"
+)
+
+type checker struct {
+	mod   string
+	rules *Rules
+	errs  *lexing.ErrorList
+}
+
+// name returns the name of a package used in the rules.
+func (c *checker) name(p string) string {
+	if p == c.mod {
+		return "."
+	}
+	if rel, ok := strings.CutPrefix(p, c.mod+"/"); ok {
+		return rel
+	}
+	return p
+}
+
+func (c *checker) allowed(from, to string) bool {
+	for _, e := range c.rules.Allow {
+		if e.match(from, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// internalParent returns the parent of the last "internal" element in an
+// import path, and false if the path has no such element.
+func internalParent(p string) (string, bool) {
+	if p == "internal" || strings.HasPrefix(p, "internal/") {
+		return "", true
+	}
+	i := strings.LastIndex(p+"/", "/internal/")
+	if i < 0 {
+		return "", false
+	}
+	return p[:i], true
+}
+
+// crossInternal checks if an import crosses an internal/ boundary, where
+// the importing package is outside the tree rooted at the parent of the
+// "internal" directory. Boundaries at the root, like the ones in the
+// standard library, are left for the go tool.
+func crossInternal(from, to string) (string, bool) {
+	parent, ok := internalParent(to)
+	if !ok || parent == "" {
+		return "", false
+	}
+	if from == parent || strings.HasPrefix(from, parent+"/") {
+		return "", false
+	}
+	return parent, true
+}
+
+// violation returns the broken rule and a description for an import, or
+// an empty rule if the import is fine. p and imp are import paths.
+func (c *checker) violation(p, imp string, test bool) (string, string) {
+	from := c.name(p)
+	to := c.name(imp)
+	if parent, ok := crossInternal(p, imp); ok {
+		return "internal", fmt.Sprintf(
+			"%s imports %s, which is internal to %s",
+			from, to, c.name(parent),
+		)
+	}
+	if c.allowed(from, to) {
+		return "", ""
+	}
+	for _, e := range c.rules.Deny {
+		if e.match(from, to) {
+			msg := fmt.Sprintf("%s must not import %s", from, to)
+			if e.Reason != "" {
+				msg += ": " + e.Reason
+			}
+			return "deny", msg
+		}
+	}
+	if test {
+		return "", ""
+	}
+	if matchAny(c.rules.TestOnly, to) {
+		return "testonly", fmt.Sprintf(
+			"%s imports %s, which is only for tests", from, to,
+		)
+	}
+	fromLayer := c.rules.layerOf(from)
+	if fromLayer < 0 {
+		return "", ""
+	}
+	if toLayer := c.rules.layerOf(to); toLayer > fromLayer {
+		return "layer", fmt.Sprintf(
+			"%s in layer %q imports %s in higher layer %q",
+			from, c.rules.Layers[fromLayer].Name,
+			to, c.rules.Layers[toLayer].Name,
+		)
+	}
+	return "", ""
+}
+
+func lexingPos(p token.Position) *lexing.Pos {
+	return &lexing.Pos{File: p.Filename, Line: p.Line, Col: p.Column}
+}
+
+func (c *checker) checkImports(
+	p string, imports []string, pos map[string][]token.Position, test bool,
+) {
+	for _, imp := range imports {
+		if imp == p {
+			continue // external test package importing the package
+		}
+		code, msg := c.violation(p, imp, test)
+		if code == "" {
+			continue
+		}
+		for _, at := range pos[imp] {
+			c.errs.CodeErrorf(lexingPos(at), code, "%s", msg)
+		}
+	}
+}
+
+// Check checks the imports of packages against the rules. Packages are
+// keyed by their import paths, and mod is the path of the module. It
+// reports every violating import with its position.
+func Check(
+	mod string, pkgs map[string]*build.Package, rules *Rules,
+) []*lexing.Error {
+	c := &checker{
+		mod:   mod,
+		rules: rules,
+		errs:  lexing.NewErrorList(),
+	}
+
+	var paths []string
+	for p := range pkgs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		pkg := pkgs[p]
+		c.checkImports(p, pkg.Imports, pkg.ImportPos, false)
+		c.checkImports(p, pkg.TestImports, pkg.TestImportPos, true)
+		c.checkImports(p, pkg.XTestImports, pkg.XTestImportPos, true)
+	}
+	return c.errs.Errs()
+}
@@ -0,0 +1,102 @@
+package golayer
+
+import (
+	"go/build"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	rules := &Rules{
+		Layers: []*Layer{
+			{Name: "base", Pkgs: []string{"errcode", "strutil"}},
+			{Name: "web", Pkgs: []string{"aries/..."}},
+			{Name: "apps", Pkgs: []string{"oauth2/...", "."}},
+		},
+		Deny: []*Edge{
+			{From: "aries/...", To: "oauth2/...", Reason: "no cycles"},
+		},
+		Allow: []*Edge{
+			{From: "strutil", To: "aries/static"},
+		},
+		TestOnly: []string{"testutil"},
+	}
+
+	pos := func(file string, line int) []token.Position {
+		return []token.Position{{Filename: file, Line: line, Column: 2}}
+	}
+	newPkg := func(imports []string, test bool) *build.Package {
+		m := make(map[string][]token.Position)
+		for i, imp := range imports {
+			m[imp] = pos("x.go", i+3)
+		}
+		if test {
+			return &build.Package{TestImports: imports, TestImportPos: m}
+		}
+		return &build.Package{Imports: imports, ImportPos: m}
+	}
+
+	const mod = "x.io/m"
+	pkgs := map[string]*build.Package{
+		"x.io/m":                newPkg([]string{"x.io/m/aries"}, false),
+		"x.io/m/aries":          newPkg([]string{"x.io/m/oauth2/a"}, false),
+		"x.io/m/aries/static":   newPkg([]string{"x.io/m/errcode"}, false),
+		"x.io/m/errcode":        newPkg([]string{"x.io/m/aries"}, false),
+		"x.io/m/strutil":        newPkg([]string{"x.io/m/aries/static"}, false),
+		"x.io/m/oauth2/a":       newPkg([]string{"x.io/m/testutil"}, false),
+		"x.io/m/oauth2/b":       newPkg([]string{"x.io/m/testutil"}, true),
+		"x.io/m/aries/testdeps": newPkg([]string{"x.io/m/oauth2/b"}, true),
+		"x.io/m/testutil":       newPkg(nil, false),
+	}
+
+	var got []string
+	for _, err := range Check(mod, pkgs, rules) {
+		got = append(got, err.Code+" "+err.Pos.String())
+	}
+	want := []string{
+		"deny x.go:3:2",  // aries imports oauth2/a
+		"deny x.go:3:2",  // aries/testdeps test imports oauth2/b
+		"layer x.go:3:2", // errcode imports aries
+		"testonly x.go:3:2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCheckInternal(t *testing.T) {
+	pkg := func(imp string) *build.Package {
+		return &build.Package{
+			Imports: []string{imp},
+			ImportPos: map[string][]token.Position{
+				imp: {{Filename: "x.go", Line: 3, Column: 2}},
+			},
+		}
+	}
+
+	const mod = "x.io/m"
+	pkgs := map[string]*build.Package{
+		"x.io/m":             pkg("x.io/m/internal/a"),
+		"x.io/m/aries":       pkg("x.io/m/aries/internal/b"),
+		"x.io/m/aries/c":     pkg("x.io/m/aries/internal/b"),
+		"x.io/m/oauth2":      pkg("x.io/m/aries/internal/b"),
+		"x.io/m/internal/a":  pkg("internal/cpu"),
+		"x.io/m/aries/inner": pkg("x.io/m/aries/c/internal/d/internal"),
+	}
+
+	var got []string
+	for _, err := range Check(mod, pkgs, &Rules{}) {
+		got = append(got, err.Code+" "+err.Error())
+	}
+	want := []string{
+		"internal x.go:3:2: aries/inner imports " +
+			"aries/c/internal/d/internal, which is internal to " +
+			"aries/c/internal/d",
+		"internal x.go:3:2: oauth2 imports aries/internal/b, " +
+			"which is internal to aries",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
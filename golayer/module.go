@@ -0,0 +1,28 @@
+package golayer
+
+import (
+	"go/build"
+	"path/filepath"
+
+	"shanhu.io/g/goload"
+	"shanhu.io/g/gomod"
+	"shanhu.io/std/errcode"
+	"shanhu.io/std/lexing"
+)
+
+// CheckModule checks all the packages in the module at directory dir.
+func CheckModule(dir string, rules *Rules) ([]*lexing.Error, error) {
+	mod, err := gomod.ReadModulePath(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, errcode.Annotate(err, "read module path")
+	}
+	res, err := goload.ScanModPkgs(mod, dir, nil)
+	if err != nil {
+		return nil, errcode.Annotate(err, "scan packages")
+	}
+	pkgs := make(map[string]*build.Package)
+	for p, pkg := range res.Pkgs {
+		pkgs[p] = pkg.Build
+	}
+	return Check(mod, pkgs, rules), nil
+}
@@ -0,0 +1,124 @@
+// Package golayer checks the imports between packages against layering
+// rules, like which layer of packages may import which.
+package golayer
+
+import (
+	"strings"
+
+	"shanhu.io/g/jsonutil"
+	"shanhu.io/std/errcode"
+)
+
+// RulesFile is the default name of the rules file, which is at the root
+// of a module.
+const RulesFile = "golayer.json"
+
+// Layer is a named group of packages.
+type Layer struct {
+	Name string
+	Pkgs []string // package patterns
+}
+
+// Edge is a rule on the imports from packages to packages.
+type Edge struct {
+	From   string // pattern of the importing packages
+	To     string // pattern of the imported packages
+	Reason string `json:",omitempty"`
+}
+
+func (e *Edge) match(from, to string) bool {
+	return matchPattern(e.From, from) && matchPattern(e.To, to)
+}
+
+// Rules are the layering rules of a module.
+//
+// Package patterns are import paths, where packages in the module are
+// relative to the module path, and the module root package is ".". A
+// pattern that ends with "/..." also matches all the packages under it,
+// and "..." matches all packages.
+//
+// Besides the rules, imports of packages under an "internal" directory
+// from outside the tree rooted at the parent of the directory are always
+// reported, in both non-test and test code.
+type Rules struct {
+	// Layers are ordered from the bottom to the top. The non-test code in
+	// a layer may only import packages in the same or lower layers. Packages
+	// that are not in any layer are not checked.
+	Layers []*Layer `json:",omitempty"`
+
+	// Deny lists the imports that are forbidden, in both non-test and test
+	// code.
+	Deny []*Edge `json:",omitempty"`
+
+	// Allow lists the imports that are allowed regardless of the layers and
+	// the denied imports.
+	Allow []*Edge `json:",omitempty"`
+
+	// TestOnly lists the packages that may only be imported by tests.
+	TestOnly []string `json:",omitempty"`
+}
+
+func matchPattern(pattern, p string) bool {
+	if pattern == "..." {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+	return pattern == p
+}
+
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// layerOf returns the index of the layer that a package is in, or -1 if it
+// is not in any layer. When multiple layers match, the top one wins.
+func (r *Rules) layerOf(p string) int {
+	for i := len(r.Layers) - 1; i >= 0; i-- {
+		if matchAny(r.Layers[i].Pkgs, p) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *Rules) check() error {
+	names := make(map[string]bool)
+	for _, layer := range r.Layers {
+		if layer.Name == "" {
+			return errcode.InvalidArgf("layer has no name")
+		}
+		if names[layer.Name] {
+			return errcode.InvalidArgf("duplicate layer %q", layer.Name)
+		}
+		names[layer.Name] = true
+	}
+	for _, lst := range [][]*Edge{r.Deny, r.Allow} {
+		for _, e := range lst {
+			if e.From == "" || e.To == "" {
+				return errcode.InvalidArgf(
+					"edge %q -> %q has an empty pattern", e.From, e.To,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// ReadRules reads the rules from a JSON file.
+func ReadRules(f string) (*Rules, error) {
+	r := new(Rules)
+	if err := jsonutil.ReadFile(f, r); err != nil {
+		return nil, err
+	}
+	if err := r.check(); err != nil {
+		return nil, errcode.Annotatef(err, "invalid rules in %q", f)
+	}
+	return r, nil
+}
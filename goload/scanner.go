@@ -214,11 +214,11 @@ func (s *scanner) walk(dir *scanDir) error {
 	if !s.vendorScanning && !s.gomod {
 		if s.modRoot == "" && findInSorted(names, "go.mod") {
 			p := filepath.Join(dir.dir, "go.mod")
-			modFile, err := gomod.Parse(p)
+			modPath, err := gomod.ReadModulePath(p)
 			if err != nil {
 				s.warning(dir.path, fmt.Errorf("parse go.mod: %s", err))
-			} else if isValidModPath(dir.path, modFile.Name) {
-				s.enterMod(dir.path, modFile.Name)
+			} else if isValidModPath(dir.path, modPath) {
+				s.enterMod(dir.path, modPath)
 				defer s.exitMod()
 			}
 		}
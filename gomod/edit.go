@@ -0,0 +1,105 @@
+package gomod
+
+import (
+	"os"
+
+	"golang.org/x/mod/modfile"
+	"shanhu.io/std/errcode"
+)
+
+// edit applies an edit on the syntax, and updates the exported fields.
+func (f *File) edit(op func(s *modfile.File) error) error {
+	if err := op(f.syntax); err != nil {
+		return err
+	}
+	f.syntax.Cleanup()
+	f.load()
+	return nil
+}
+
+// SetGo sets the go version.
+func (f *File) SetGo(version string) error {
+	return f.edit(func(s *modfile.File) error {
+		return s.AddGoStmt(version)
+	})
+}
+
+// SetToolchain sets the toolchain. An empty name removes the toolchain
+// directive.
+func (f *File) SetToolchain(name string) error {
+	return f.edit(func(s *modfile.File) error {
+		if name == "" {
+			s.DropToolchainStmt()
+			return nil
+		}
+		return s.AddToolchainStmt(name)
+	})
+}
+
+// SetRequire requires a version of a module. The version is updated in
+// place if the module is already required.
+func (f *File) SetRequire(path, version string) error {
+	return f.edit(func(s *modfile.File) error {
+		return s.AddRequire(path, version)
+	})
+}
+
+// DropRequire removes the require directive of a module.
+func (f *File) DropRequire(path string) error {
+	return f.edit(func(s *modfile.File) error {
+		return s.DropRequire(path)
+	})
+}
+
+// AddReplace adds or updates a replace directive.
+func (f *File) AddReplace(old, new Version) error {
+	return f.edit(func(s *modfile.File) error {
+		return s.AddReplace(old.Path, old.Version, new.Path, new.Version)
+	})
+}
+
+// DropReplace removes a replace directive.
+func (f *File) DropReplace(old Version) error {
+	return f.edit(func(s *modfile.File) error {
+		return s.DropReplace(old.Path, old.Version)
+	})
+}
+
+// AddExclude adds an exclude directive.
+func (f *File) AddExclude(v Version) error {
+	return f.edit(func(s *modfile.File) error {
+		return s.AddExclude(v.Path, v.Version)
+	})
+}
+
+// DropExclude removes an exclude directive.
+func (f *File) DropExclude(v Version) error {
+	return f.edit(func(s *modfile.File) error {
+		return s.DropExclude(v.Path, v.Version)
+	})
+}
+
+// AddRetract adds a retract directive.
+func (f *File) AddRetract(r *Retract) error {
+	return f.edit(func(s *modfile.File) error {
+		vi := modfile.VersionInterval{Low: r.Low, High: r.High}
+		return s.AddRetract(vi, r.Rationale)
+	})
+}
+
+// Format formats the file. Comments of the original file are kept.
+func (f *File) Format() ([]byte, error) {
+	return f.syntax.Format()
+}
+
+// Save writes the file back to where it is parsed from.
+func (f *File) Save() error {
+	if f.file == "" {
+		return errcode.Internalf("file not parsed from a file")
+	}
+	bs, err := f.Format()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.file, bs, 0644)
+}
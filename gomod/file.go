@@ -1,20 +1,44 @@
-// Package gomod provides simple go.mod file parsing.
-//
-// TODO(h8liu): simply call golang.org/x/mod/modfile
+// Package gomod provides go.mod and go.work file parsing and editing. It
+// wraps golang.org/x/mod/modfile, so edits keep the comments and the layout
+// of the file.
 package gomod
 
 import (
-	"bufio"
-	"bytes"
-	"errors"
 	"os"
-	"strconv"
-	"strings"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+	"shanhu.io/std/errcode"
 )
 
+// Require is a require directive.
+type Require struct {
+	Path     string
+	Version  string
+	Indirect bool `json:",omitempty"`
+}
+
+// Retract is a retract directive. Low and High are the same when a single
+// version is retracted.
+type Retract struct {
+	Low       string
+	High      string
+	Rationale string `json:",omitempty"`
+}
+
 // File is a parsed go.mod file.
 type File struct {
-	Name string
+	Name      string // Module path.
+	Go        string `json:",omitempty"`
+	Toolchain string `json:",omitempty"`
+
+	Require []*Require `json:",omitempty"`
+	Replace []*Replace `json:",omitempty"`
+	Exclude []*Version `json:",omitempty"`
+	Retract []*Retract `json:",omitempty"`
+
+	file   string // Path of the file; empty if not parsed from a file.
+	syntax *modfile.File
 }
 
 // Parse parses a go.mod file.
@@ -23,53 +47,113 @@ func Parse(f string) (*File, error) {
 	if err != nil {
 		return nil, err
 	}
-	name, err := modulePath(bs)
+	mod, err := ParseBytes(f, bs)
 	if err != nil {
 		return nil, err
 	}
-	return &File{Name: name}, nil
+	mod.file = f
+	return mod, nil
 }
 
-var errInvalidModFile = errors.New("invalid go.mod file")
+// ParseBytes parses the content of a go.mod file. name is the file name
+// used in error messages.
+func ParseBytes(name string, bs []byte) (*File, error) {
+	syntax, err := modfile.Parse(name, bs, nil)
+	if err != nil {
+		return nil, err
+	}
+	if syntax.Module == nil {
+		return nil, errInvalidModFile
+	}
+	f := &File{syntax: syntax}
+	f.load()
+	return f, nil
+}
 
-// modulePath returns the module path from the gomod file text.
-// If it cannot find a module path, it returns an empty string.
-// It is tolerant of unrelated problems in the go.mod file.
-func modulePath(bs []byte) (string, error) {
-	s := bufio.NewScanner(bytes.NewReader(bs))
+// load updates the exported fields from the syntax.
+func (f *File) load() {
+	s := f.syntax
+	f.Name = s.Module.Mod.Path
+	f.Go = ""
+	if s.Go != nil {
+		f.Go = s.Go.Version
+	}
+	f.Toolchain = ""
+	if s.Toolchain != nil {
+		f.Toolchain = s.Toolchain.Name
+	}
 
-	for s.Scan() {
-		line := strings.TrimSpace(s.Text())
-		if line == "" || strings.HasPrefix(line, "//") {
-			continue
-		}
-		if !strings.HasPrefix(line, "module") {
-			continue
-		}
+	f.Require = nil
+	for _, r := range s.Require {
+		f.Require = append(f.Require, &Require{
+			Path:     r.Mod.Path,
+			Version:  r.Mod.Version,
+			Indirect: r.Indirect,
+		})
+	}
+	f.Replace = replacesOf(s.Replace)
+	f.Exclude = nil
+	for _, e := range s.Exclude {
+		f.Exclude = append(f.Exclude, &Version{
+			Path:    e.Mod.Path,
+			Version: e.Mod.Version,
+		})
+	}
+	f.Retract = nil
+	for _, r := range s.Retract {
+		f.Retract = append(f.Retract, &Retract{
+			Low:       r.Low,
+			High:      r.High,
+			Rationale: r.Rationale,
+		})
+	}
+}
 
-		line = strings.TrimSpace(strings.TrimPrefix(line, "module"))
+// Dir returns the directory of the file. It returns an empty string when
+// the file is not parsed from a file.
+func (f *File) Dir() string {
+	if f.file == "" {
+		return ""
+	}
+	return filepath.Dir(f.file)
+}
 
-		// TODO: this is incorrect for quoted module path
-		if before, _, found := strings.Cut(line, "//"); found {
-			line = strings.TrimSpace(before)
-			if line == "" {
-				return "", errInvalidModFile
-			}
+// FindRequire returns the require directive of a module path, or nil if
+// the module is not required.
+func (f *File) FindRequire(path string) *Require {
+	for _, r := range f.Require {
+		if r.Path == path {
+			return r
 		}
+	}
+	return nil
+}
 
-		if line == "" {
-			return "", errInvalidModFile
-		}
-		if line[0] == '"' || line[0] == '`' {
-			p, err := strconv.Unquote(line)
-			if err != nil || p == "" {
-				return "", errInvalidModFile
-			}
-			return p, nil
-		}
+// ExternalReplaces returns the replace directives that point to local
+// directories outside of root. Relative directories are relative to the
+// directory of the go.mod file, or the current directory when the file is
+// not parsed from a file.
+func (f *File) ExternalReplaces(root string) ([]*Replace, error) {
+	return externalReplaces(f.Replace, f.Dir(), root)
+}
 
-		return line, nil
+// ReadModulePath reads the module path of a go.mod file. Unlike Parse, it
+// is tolerant of unrelated problems in the file.
+func ReadModulePath(f string) (string, error) {
+	bs, err := os.ReadFile(f)
+	if err != nil {
+		return "", err
 	}
+	return modulePath(bs)
+}
 
-	return "", errInvalidModFile
+// modulePath returns the module path from the gomod file text.
+func modulePath(bs []byte) (string, error) {
+	p := modfile.ModulePath(bs)
+	if p == "" {
+		return "", errInvalidModFile
+	}
+	return p, nil
 }
+
+var errInvalidModFile = errcode.InvalidArgf("invalid go.mod file")
@@ -1,6 +1,10 @@
 package gomod
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -26,3 +30,144 @@ func TestModulePath(t *testing.T) {
 		}
 	}
 }
+
+const testModFile = `// The module.
+module shanhu.io/x
+
+go 1.26
+
+toolchain go1.27.1
+
+require (
+	shanhu.io/a v1.0.0 // pinned
+	shanhu.io/b v1.2.0 // indirect
+)
+
+replace shanhu.io/a => ../a
+
+replace shanhu.io/c v1.0.0 => shanhu.io/d v1.1.0
+
+exclude shanhu.io/b v1.1.0
+
+retract v0.1.0 // bad release
+`
+
+func TestParseBytes(t *testing.T) {
+	f, err := ParseBytes("go.mod", []byte(testModFile))
+	if err != nil {
+		t.Fatal("parse: ", err)
+	}
+
+	if f.Name != "shanhu.io/x" {
+		t.Errorf("got name %q, want shanhu.io/x", f.Name)
+	}
+	if f.Go != "1.26" || f.Toolchain != "go1.27.1" {
+		t.Errorf("got go %q, toolchain %q", f.Go, f.Toolchain)
+	}
+	if len(f.Require) != 2 {
+		t.Fatalf("got %d requires, want 2", len(f.Require))
+	}
+	if r := f.FindRequire("shanhu.io/b"); r == nil || !r.Indirect {
+		t.Errorf("shanhu.io/b should be an indirect require")
+	}
+	if len(f.Replace) != 2 {
+		t.Fatalf("got %d replaces, want 2", len(f.Replace))
+	}
+	if !f.Replace[0].IsLocal() || f.Replace[1].IsLocal() {
+		t.Errorf("wrong local replaces: %+v", f.Replace)
+	}
+	if len(f.Exclude) != 1 || f.Exclude[0].Version != "v1.1.0" {
+		t.Errorf("wrong excludes: %+v", f.Exclude)
+	}
+	if len(f.Retract) != 1 || f.Retract[0].Rationale != "bad release" {
+		t.Errorf("wrong retracts: %+v", f.Retract)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	f, err := ParseBytes("go.mod", []byte(testModFile))
+	if err != nil {
+		t.Fatal("parse: ", err)
+	}
+
+	if err := f.SetRequire("shanhu.io/a", "v1.1.0"); err != nil {
+		t.Fatal("set require: ", err)
+	}
+	if err := f.DropReplace(Version{Path: "shanhu.io/a"}); err != nil {
+		t.Fatal("drop replace: ", err)
+	}
+	if err := f.SetToolchain(""); err != nil {
+		t.Fatal("drop toolchain: ", err)
+	}
+
+	if r := f.FindRequire("shanhu.io/a"); r == nil || r.Version != "v1.1.0" {
+		t.Errorf("got require %+v, want v1.1.0", r)
+	}
+	if len(f.Replace) != 1 {
+		t.Errorf("got %d replaces, want 1", len(f.Replace))
+	}
+
+	bs, err := f.Format()
+	if err != nil {
+		t.Fatal("format: ", err)
+	}
+	got := string(bs)
+	for _, s := range []string{
+		"// The module.",
+		"shanhu.io/a v1.1.0 // pinned",
+		"retract v0.1.0 // bad release",
+	} {
+		if !strings.Contains(got, s) {
+			t.Errorf("formatted file missing %q:\n%s", s, got)
+		}
+	}
+	if strings.Contains(got, "toolchain") {
+		t.Errorf("toolchain not dropped:\n%s", got)
+	}
+}
+
+func TestExternalReplaces(t *testing.T) {
+	dir := t.TempDir()
+	modDir := filepath.Join(dir, "x")
+	if err := os.Mkdir(modDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	modFile := filepath.Join(modDir, "go.mod")
+	content := strings.Join([]string{
+		"module shanhu.io/x",
+		"replace shanhu.io/a => ../a",
+		"replace shanhu.io/b => ./b",
+		"replace shanhu.io/c => shanhu.io/d v1.0.0",
+	}, "\n")
+	if err := os.WriteFile(modFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Parse(modFile)
+	if err != nil {
+		t.Fatal("parse: ", err)
+	}
+
+	for _, test := range []struct {
+		root string
+		want []string
+	}{
+		{root: dir, want: nil},
+		{root: modDir, want: []string{"shanhu.io/a"}},
+	} {
+		rs, err := f.ExternalReplaces(test.root)
+		if err != nil {
+			t.Fatalf("external replaces in %q: %s", test.root, err)
+		}
+		var got []string
+		for _, r := range rs {
+			got = append(got, r.Old.Path)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf(
+				"external replaces in %q: got %q, want %q",
+				test.root, got, test.want,
+			)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package gomod
+
+import (
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Version is a module path with a version.
+type Version struct {
+	Path    string
+	Version string `json:",omitempty"`
+}
+
+// Replace is a replace directive. Old.Version is empty when all versions
+// are replaced. New.Version is empty when the module is replaced by a
+// local directory.
+type Replace struct {
+	Old Version
+	New Version
+}
+
+// IsLocal checks if the module is replaced by a local directory.
+func (r *Replace) IsLocal() bool { return r.New.Version == "" }
+
+func replacesOf(rs []*modfile.Replace) []*Replace {
+	var ret []*Replace
+	for _, r := range rs {
+		ret = append(ret, &Replace{
+			Old: Version{Path: r.Old.Path, Version: r.Old.Version},
+			New: Version{Path: r.New.Path, Version: r.New.Version},
+		})
+	}
+	return ret
+}
+
+// localDir returns the absolute directory of a local replacement. dir is
+// the directory that relative paths are relative to.
+func (r *Replace) localDir(dir string) (string, error) {
+	p := filepath.FromSlash(r.New.Path)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	return filepath.Abs(p)
+}
+
+// externalReplaces returns the local replaces that point to directories
+// outside of root.
+func externalReplaces(rs []*Replace, dir, root string) ([]*Replace, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*Replace
+	for _, r := range rs {
+		if !r.IsLocal() {
+			continue
+		}
+		d, err := r.localDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(root, d)
+		if err != nil || !filepath.IsLocal(rel) && rel != "." {
+			ret = append(ret, r)
+		}
+	}
+	return ret, nil
+}
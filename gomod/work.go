@@ -0,0 +1,186 @@
+package gomod
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+	"shanhu.io/g/osutil"
+	"shanhu.io/std/errcode"
+)
+
+// Work is a parsed go.work file.
+type Work struct {
+	Go        string `json:",omitempty"`
+	Toolchain string `json:",omitempty"`
+
+	// Use lists the module directories, as written in the file.
+	Use     []string   `json:",omitempty"`
+	Replace []*Replace `json:",omitempty"`
+
+	file   string
+	syntax *modfile.WorkFile
+}
+
+// ParseWork parses a go.work file.
+func ParseWork(f string) (*Work, error) {
+	bs, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	w, err := ParseWorkBytes(f, bs)
+	if err != nil {
+		return nil, err
+	}
+	w.file = f
+	return w, nil
+}
+
+// ParseWorkBytes parses the content of a go.work file. name is the file
+// name used in error messages.
+func ParseWorkBytes(name string, bs []byte) (*Work, error) {
+	syntax, err := modfile.ParseWork(name, bs, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &Work{syntax: syntax}
+	w.load()
+	return w, nil
+}
+
+func (w *Work) load() {
+	s := w.syntax
+	w.Go = ""
+	if s.Go != nil {
+		w.Go = s.Go.Version
+	}
+	w.Toolchain = ""
+	if s.Toolchain != nil {
+		w.Toolchain = s.Toolchain.Name
+	}
+	w.Use = nil
+	for _, u := range s.Use {
+		w.Use = append(w.Use, u.Path)
+	}
+	w.Replace = replacesOf(s.Replace)
+}
+
+// Dir returns the directory of the file. It returns an empty string when
+// the file is not parsed from a file.
+func (w *Work) Dir() string {
+	if w.file == "" {
+		return ""
+	}
+	return filepath.Dir(w.file)
+}
+
+// UseDirs returns the module directories in absolute paths.
+func (w *Work) UseDirs() ([]string, error) {
+	var ret []string
+	for _, u := range w.Use {
+		p := filepath.FromSlash(u)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(w.Dir(), p)
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, abs)
+	}
+	return ret, nil
+}
+
+// Modules parses the go.mod files of all the used modules.
+func (w *Work) Modules() ([]*File, error) {
+	dirs, err := w.UseDirs()
+	if err != nil {
+		return nil, err
+	}
+	var ret []*File
+	for _, d := range dirs {
+		f, err := Parse(filepath.Join(d, "go.mod"))
+		if err != nil {
+			return nil, errcode.Annotatef(err, "parse module %q", d)
+		}
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+func (w *Work) edit(op func(s *modfile.WorkFile) error) error {
+	if err := op(w.syntax); err != nil {
+		return err
+	}
+	w.syntax.Cleanup()
+	w.load()
+	return nil
+}
+
+// SetGo sets the go version.
+func (w *Work) SetGo(version string) error {
+	return w.edit(func(s *modfile.WorkFile) error {
+		return s.AddGoStmt(version)
+	})
+}
+
+// AddUse adds a module directory.
+func (w *Work) AddUse(dir string) error {
+	return w.edit(func(s *modfile.WorkFile) error {
+		return s.AddUse(dir, "")
+	})
+}
+
+// DropUse removes a module directory.
+func (w *Work) DropUse(dir string) error {
+	return w.edit(func(s *modfile.WorkFile) error {
+		return s.DropUse(dir)
+	})
+}
+
+// ExternalReplaces returns the replace directives that point to local
+// directories outside of root.
+func (w *Work) ExternalReplaces(root string) ([]*Replace, error) {
+	return externalReplaces(w.Replace, w.Dir(), root)
+}
+
+// Format formats the file. Comments of the original file are kept.
+func (w *Work) Format() ([]byte, error) {
+	return modfile.Format(w.syntax.Syntax), nil
+}
+
+// Save writes the file back to where it is parsed from.
+func (w *Work) Save() error {
+	if w.file == "" {
+		return errcode.Internalf("file not parsed from a file")
+	}
+	bs, err := w.Format()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(w.file, bs, 0644)
+}
+
+// FindWork finds the go.work file that is in dir or its parent
+// directories. It returns an empty string if there is none.
+func FindWork(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		f := filepath.Join(dir, "go.work")
+		ok, err := osutil.IsRegular(f)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return f, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,74 @@
+package gomod
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWork(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		modDir := filepath.Join(dir, name)
+		if err := os.Mkdir(modDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := "module shanhu.io/" + name + "\n"
+		f := filepath.Join(modDir, "go.mod")
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	workFile := filepath.Join(dir, "go.work")
+	content := "go 1.26\n\n// Modules.\nuse ./a\n"
+	if err := os.WriteFile(workFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := FindWork(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal("find work: ", err)
+	}
+	if found != workFile {
+		t.Errorf("found work file %q, want %q", found, workFile)
+	}
+
+	w, err := ParseWork(found)
+	if err != nil {
+		t.Fatal("parse work: ", err)
+	}
+	if err := w.AddUse("./b"); err != nil {
+		t.Fatal("add use: ", err)
+	}
+	if err := w.Save(); err != nil {
+		t.Fatal("save: ", err)
+	}
+
+	w, err = ParseWork(workFile)
+	if err != nil {
+		t.Fatal("parse work again: ", err)
+	}
+	mods, err := w.Modules()
+	if err != nil {
+		t.Fatal("parse modules: ", err)
+	}
+	var names []string
+	for _, mod := range mods {
+		names = append(names, mod.Name)
+	}
+	want := []string{"shanhu.io/a", "shanhu.io/b"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got modules %q, want %q", names, want)
+	}
+
+	bs, err := os.ReadFile(workFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "// Modules.") {
+		t.Errorf("comment is lost:\n%s", bs)
+	}
+}
@@ -0,0 +1,157 @@
+package gotags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"shanhu.io/g/jsonutil"
+)
+
+// IndexFile is the default file name of a symbol index.
+const IndexFile = "gotags.json"
+
+// Symbol kinds in an index.
+const (
+	KindConst     = "const"
+	KindVar       = "var"
+	KindFunc      = "func"
+	KindType      = "type"
+	KindInterface = "interface"
+	KindMethod    = "method"
+	KindField     = "field"
+)
+
+// Pos is a position in a file. The file is relative to the root directory
+// of the index.
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (p *Pos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+// Symbol is the definition of a package level object, a method or a
+// struct field. Symbols are identified by their package paths and names,
+// like "shanhu.io/g/aries.Service" or "shanhu.io/g/aries.Router.Get".
+type Symbol struct {
+	ID   string
+	Name string
+	Kind string
+	Pkg  string
+	Recv string `json:",omitempty"` // type of a method or a field
+	Pos  *Pos
+
+	// Methods is the method set of a type or an interface, in the form of
+	// method signatures. It is used for finding the implementations.
+	Methods []string `json:",omitempty"`
+
+	// Implements lists the interfaces that a type implements, and for an
+	// interface, the types that implement it.
+	Implements []string `json:",omitempty"`
+}
+
+// Ref is a reference to a symbol in a file.
+type Ref struct {
+	ID   string
+	Line int
+	Col  int
+}
+
+// FileIndex saves the references in a file.
+type FileIndex struct {
+	Pkg  string
+	Hash string
+	Refs []*Ref `json:",omitempty"`
+}
+
+// Index is a symbol index of Go packages, with the definitions, the
+// references and the implementations of the symbols.
+type Index struct {
+	Symbols map[string]*Symbol
+	Files   map[string]*FileIndex
+}
+
+func newIndex() *Index {
+	return &Index{
+		Symbols: make(map[string]*Symbol),
+		Files:   make(map[string]*FileIndex),
+	}
+}
+
+// ReadIndex reads an index from a JSON file.
+func ReadIndex(f string) (*Index, error) {
+	idx := newIndex()
+	if err := jsonutil.ReadFile(f, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Save saves the index into a JSON file.
+func (idx *Index) Save(f string) error {
+	return jsonutil.WriteFile(f, idx)
+}
+
+// Find finds the symbols by ID, by a suffix of the ID after a dot, like
+// "aries.Service", or by name.
+func (idx *Index) Find(name string) []*Symbol {
+	if s, ok := idx.Symbols[name]; ok {
+		return []*Symbol{s}
+	}
+	var ret []*Symbol
+	for id, s := range idx.Symbols {
+		if s.Name == name || strings.HasSuffix(id, "/"+name) ||
+			strings.HasSuffix(id, "."+name) {
+			ret = append(ret, s)
+		}
+	}
+	sortSymbols(ret)
+	return ret
+}
+
+func sortSymbols(syms []*Symbol) {
+	sort.Slice(syms, func(i, j int) bool { return syms[i].ID < syms[j].ID })
+}
+
+// Refs returns the positions of all the references to a symbol.
+func (idx *Index) Refs(id string) []*Pos {
+	var ret []*Pos
+	for file, f := range idx.Files {
+		for _, r := range f.Refs {
+			if r.ID == id {
+				ret = append(ret, &Pos{File: file, Line: r.Line, Col: r.Col})
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+	return ret
+}
+
+// Implementations returns the types that implement an interface, or the
+// interfaces that a type implements.
+func (idx *Index) Implementations(id string) []*Symbol {
+	s, ok := idx.Symbols[id]
+	if !ok {
+		return nil
+	}
+	var ret []*Symbol
+	for _, other := range s.Implements {
+		if sym, ok := idx.Symbols[other]; ok {
+			ret = append(ret, sym)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,193 @@
+package gotags
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+	"shanhu.io/g/hashutil"
+	"shanhu.io/std/errcode"
+)
+
+// IndexConfig configures how a symbol index is built.
+type IndexConfig struct {
+	Dir string   // root directory; files in the index are relative to it
+	Env []string // environment of the go command; nil for the current
+}
+
+func loadIndexPkgs(
+	config *IndexConfig, patterns []string,
+) ([]*packages.Package, error) {
+	mode := packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+		packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: mode,
+		Dir:  config.Dir,
+		Env:  config.Env,
+	}, patterns...)
+	if err != nil {
+		return nil, errcode.Annotate(err, "load packages")
+	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, errcode.Annotatef(
+				pkg.Errors[0], "load package %q", pkg.PkgPath,
+			)
+		}
+	}
+	return pkgs, nil
+}
+
+func (x *indexer) add(pkg *packages.Package) error {
+	for _, f := range pkg.Syntax {
+		file := pkg.Fset.Position(f.Pos()).Filename
+		hash, err := hashutil.HashFile(file)
+		if err != nil {
+			return errcode.Annotatef(err, "hash %q", file)
+		}
+		x.idx.Files[x.rel(file)] = &FileIndex{Pkg: pkg.PkgPath, Hash: hash}
+	}
+
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		x.addObj(pkg, scope.Lookup(name))
+	}
+	x.addRefs(pkg)
+	return nil
+}
+
+func (x *indexer) addSym(
+	pkg *packages.Package, s *Symbol, obj types.Object,
+) {
+	s.Pkg = pkg.PkgPath
+	s.Name = obj.Name()
+	s.Pos = x.pos(pkg.Fset, obj.Pos())
+	x.idx.Symbols[s.ID] = s
+}
+
+func (x *indexer) addObj(pkg *packages.Package, obj types.Object) {
+	id := pkg.PkgPath + "." + obj.Name()
+	switch obj := obj.(type) {
+	case *types.Const:
+		x.addSym(pkg, &Symbol{ID: id, Kind: KindConst}, obj)
+	case *types.Var:
+		x.addSym(pkg, &Symbol{ID: id, Kind: KindVar}, obj)
+	case *types.Func:
+		x.addSym(pkg, &Symbol{ID: id, Kind: KindFunc}, obj)
+	case *types.TypeName:
+		x.addType(pkg, id, obj)
+	}
+}
+
+func (x *indexer) addMember(
+	pkg *packages.Package, typ, kind string, obj types.Object,
+) {
+	s := &Symbol{
+		ID:   pkg.PkgPath + "." + typ + "." + obj.Name(),
+		Kind: kind,
+		Recv: typ,
+	}
+	x.addSym(pkg, s, obj)
+}
+
+func (x *indexer) addType(
+	pkg *packages.Package, id string, obj *types.TypeName,
+) {
+	s := &Symbol{ID: id, Kind: KindType}
+	x.addSym(pkg, s, obj)
+	if obj.IsAlias() {
+		return
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return
+	}
+	name := obj.Name()
+
+	switch t := named.Underlying().(type) {
+	case *types.Interface:
+		s.Kind = KindInterface
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			x.addMember(pkg, name, KindMethod, t.ExplicitMethod(i))
+		}
+		for i := 0; i < t.NumMethods(); i++ {
+			s.Methods = append(s.Methods, methodSig(t.Method(i)))
+		}
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			x.addMember(pkg, name, KindField, t.Field(i))
+		}
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		x.addMember(pkg, name, KindMethod, named.Method(i))
+	}
+
+	if s.Kind == KindType {
+		mset := types.NewMethodSet(types.NewPointer(named))
+		for i := 0; i < mset.Len(); i++ {
+			f := mset.At(i).Obj().(*types.Func)
+			s.Methods = append(s.Methods, methodSig(f))
+		}
+	}
+	sort.Strings(s.Methods)
+}
+
+// methodSig returns the signature of a method that identifies it in a
+// method set. Unexported methods are qualified by their packages.
+func methodSig(f *types.Func) string {
+	name := f.Name()
+	if !f.Exported() && f.Pkg() != nil {
+		name = f.Pkg().Path() + "." + name
+	}
+	sig := f.Type().(*types.Signature)
+	unnamed := types.NewSignatureType(
+		nil, nil, nil,
+		unnamedTuple(sig.Params()), unnamedTuple(sig.Results()),
+		sig.Variadic(),
+	)
+	return name + types.TypeString(unnamed, nil)[len("func"):]
+}
+
+// unnamedTuple drops the names of the variables in a tuple, so that
+// signatures with different parameter names are the same.
+func unnamedTuple(t *types.Tuple) *types.Tuple {
+	vars := make([]*types.Var, t.Len())
+	for i := range vars {
+		vars[i] = types.NewParam(token.NoPos, nil, "", t.At(i).Type())
+	}
+	return types.NewTuple(vars...)
+}
+
+func (idx *Index) addPkgs(
+	config *IndexConfig, pkgs []*packages.Package,
+) error {
+	x, err := newIndexer(config.Dir, idx)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		x.pkgs[pkg.PkgPath] = true
+	}
+	for _, pkg := range pkgs {
+		if err := x.add(pkg); err != nil {
+			return err
+		}
+	}
+	idx.link()
+	return nil
+}
+
+// BuildIndex builds the symbol index of the packages that match the
+// patterns. Only the non-test files are indexed.
+func BuildIndex(config *IndexConfig, patterns ...string) (*Index, error) {
+	pkgs, err := loadIndexPkgs(config, patterns)
+	if err != nil {
+		return nil, err
+	}
+	idx := newIndex()
+	if err := idx.addPkgs(config, pkgs); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
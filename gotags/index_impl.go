@@ -0,0 +1,45 @@
+package gotags
+
+// implements checks if a method set has all the methods of an interface.
+func implements(mset map[string]bool, iface *Symbol) bool {
+	for _, m := range iface.Methods {
+		if !mset[m] {
+			return false
+		}
+	}
+	return true
+}
+
+// link fills the implementations of the symbols by matching the method
+// sets of the types against the interfaces. Empty interfaces are skipped,
+// as every type implements them.
+func (idx *Index) link() {
+	var ifaces, types []*Symbol
+	for _, s := range idx.Symbols {
+		s.Implements = nil
+		if len(s.Methods) == 0 {
+			continue
+		}
+		switch s.Kind {
+		case KindInterface:
+			ifaces = append(ifaces, s)
+		case KindType:
+			types = append(types, s)
+		}
+	}
+	sortSymbols(ifaces)
+	sortSymbols(types)
+
+	for _, t := range types {
+		mset := make(map[string]bool)
+		for _, m := range t.Methods {
+			mset[m] = true
+		}
+		for _, iface := range ifaces {
+			if implements(mset, iface) {
+				t.Implements = append(t.Implements, iface.ID)
+				iface.Implements = append(iface.Implements, t.ID)
+			}
+		}
+	}
+}
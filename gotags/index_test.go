@@ -0,0 +1,124 @@
+package gotags
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		f := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func symbolIDs(syms []*Symbol) []string {
+	var ids []string
+	for _, s := range syms {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func TestIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/x\n\ngo 1.21\n",
+		"a/a.go": `package a
+
+type Service interface{ Serve(name string) error }
+
+type Config struct{ Name string }
+`,
+		"b/b.go": `package b
+
+import "example.com/x/a"
+
+type Server struct{ c *a.Config }
+
+func (s *Server) Serve(n string) error { return nil }
+
+func New() a.Service {
+	return &Server{c: &a.Config{Name: "b"}}
+}
+`,
+	})
+
+	env := append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	config := &IndexConfig{Dir: dir, Env: env}
+	idx, err := BuildIndex(config, "./...")
+	if err != nil {
+		t.Fatal("build index: ", err)
+	}
+
+	const service = "example.com/x/a.Service"
+	const server = "example.com/x/b.Server"
+	if got := symbolIDs(idx.Implementations(service)); !reflect.DeepEqual(
+		got, []string{server},
+	) {
+		t.Errorf("implementations of Service: got %v", got)
+	}
+	if got := symbolIDs(idx.Find("Server.Serve")); !reflect.DeepEqual(
+		got, []string{server + ".Serve"},
+	) {
+		t.Errorf("find Server.Serve: got %v", got)
+	}
+
+	refs := idx.Refs("example.com/x/a.Config.Name")
+	want := []*Pos{{File: "b/b.go", Line: 10, Col: 30}}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("refs of Config.Name: got %v, want %v", refs, want)
+	}
+
+	writeTestFiles(t, dir, map[string]string{
+		"b/b.go": "package b\n\nfunc New() {}\n",
+	})
+	changed, err := idx.Changed(dir)
+	if err != nil {
+		t.Fatal("find changed files: ", err)
+	}
+	if want := []string{"b/b.go"}; !reflect.DeepEqual(changed, want) {
+		t.Fatalf("changed files: got %v, want %v", changed, want)
+	}
+	if err := idx.Update(config, changed); err != nil {
+		t.Fatal("update index: ", err)
+	}
+	if _, ok := idx.Symbols[server]; ok {
+		t.Error("Server still in the index after update")
+	}
+	if got := idx.Implementations(service); len(got) != 0 {
+		t.Errorf("Service still has implementations: %v", symbolIDs(got))
+	}
+	if refs := idx.Refs("example.com/x/a.Config.Name"); len(refs) != 0 {
+		t.Errorf("Config.Name still has references: %v", refs)
+	}
+
+	// References to removed symbols are dropped when the package of the
+	// symbols is indexed again.
+	writeTestFiles(t, dir, map[string]string{
+		"c/c.go": "package c\n\nimport \"example.com/x/a\"\n\nvar S a.Service\n",
+	})
+	if err := idx.Update(config, []string{"c/c.go"}); err != nil {
+		t.Fatal("update index for c: ", err)
+	}
+	if refs := idx.Refs(service); len(refs) != 1 {
+		t.Errorf("got %d refs of Service, want 1", len(refs))
+	}
+	writeTestFiles(t, dir, map[string]string{
+		"a/a.go": "package a\n\ntype Config struct{ Name string }\n",
+	})
+	if err := idx.Update(config, []string{"a/a.go"}); err != nil {
+		t.Fatal("update index for a: ", err)
+	}
+	if refs := idx.Refs(service); len(refs) != 0 {
+		t.Errorf("removed Service still has references: %v", refs)
+	}
+}
@@ -0,0 +1,156 @@
+package gotags
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"shanhu.io/g/hashutil"
+	"shanhu.io/std/errcode"
+)
+
+func isIndexedFile(name string) bool {
+	if strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	return strings.HasSuffix(name, ".go")
+}
+
+// hasGoFiles checks if a directory still has any non-test Go file.
+func hasGoFiles(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() && isIndexedFile(e.Name()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// drop removes the symbols and the files in the directories. It returns
+// the IDs of the removed symbols.
+func (idx *Index) drop(dirs map[string]bool) map[string]bool {
+	removed := make(map[string]bool)
+	for id, s := range idx.Symbols {
+		if dirs[path.Dir(s.Pos.File)] {
+			delete(idx.Symbols, id)
+			removed[id] = true
+		}
+	}
+	for f := range idx.Files {
+		if dirs[path.Dir(f)] {
+			delete(idx.Files, f)
+		}
+	}
+	return removed
+}
+
+// dropRefs removes the references to the removed symbols that are not
+// indexed again.
+func (idx *Index) dropRefs(removed map[string]bool) {
+	for _, f := range idx.Files {
+		var refs []*Ref
+		for _, r := range f.Refs {
+			if removed[r.ID] && idx.Symbols[r.ID] == nil {
+				continue
+			}
+			refs = append(refs, r)
+		}
+		f.Refs = refs
+	}
+}
+
+// Update updates the index for changed, added or removed files, which are
+// relative to the root directory. The whole packages of the files are
+// indexed again. The references from other packages are kept, except the
+// ones to the symbols that no longer exist.
+func (idx *Index) Update(config *IndexConfig, files []string) error {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		dirs[path.Dir(filepath.ToSlash(f))] = true
+	}
+
+	var patterns []string
+	for dir := range dirs {
+		ok, err := hasGoFiles(filepath.Join(config.Dir, dir))
+		if err != nil {
+			return errcode.Annotatef(err, "read dir %q", dir)
+		}
+		if !ok {
+			continue
+		}
+		if dir == "." {
+			patterns = append(patterns, ".")
+		} else {
+			patterns = append(patterns, "./"+dir)
+		}
+	}
+	sort.Strings(patterns)
+
+	removed := idx.drop(dirs)
+	if len(patterns) == 0 {
+		idx.dropRefs(removed)
+		idx.link()
+		return nil
+	}
+	pkgs, err := loadIndexPkgs(config, patterns)
+	if err != nil {
+		return err
+	}
+	if err := idx.addPkgs(config, pkgs); err != nil {
+		return err
+	}
+	idx.dropRefs(removed)
+	return nil
+}
+
+// Changed returns the files that are changed, added or removed since they
+// were indexed, where added files are only looked for in the directories
+// of the indexed files.
+func (idx *Index) Changed(root string) ([]string, error) {
+	var changed []string
+	dirs := make(map[string]bool)
+	for f, entry := range idx.Files {
+		dirs[path.Dir(f)] = true
+		hash, err := hashutil.HashFile(filepath.Join(root, f))
+		if err != nil {
+			if os.IsNotExist(err) {
+				changed = append(changed, f)
+				continue
+			}
+			return nil, errcode.Annotatef(err, "hash %q", f)
+		}
+		if hash != entry.Hash {
+			changed = append(changed, f)
+		}
+	}
+
+	for dir := range dirs {
+		entries, err := os.ReadDir(filepath.Join(root, dir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errcode.Annotatef(err, "read dir %q", dir)
+		}
+		for _, e := range entries {
+			f := path.Join(dir, e.Name())
+			if e.IsDir() || !isIndexedFile(e.Name()) {
+				continue
+			}
+			if _, ok := idx.Files[f]; !ok {
+				changed = append(changed, f)
+			}
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
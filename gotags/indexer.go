@@ -0,0 +1,178 @@
+package gotags
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+	"shanhu.io/std/errcode"
+)
+
+type indexer struct {
+	root string
+	idx  *Index
+	pkgs map[string]bool // packages in the index
+}
+
+func newIndexer(root string, idx *Index) (*indexer, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, errcode.Annotate(err, "get absolute root dir")
+	}
+	x := &indexer{root: abs, idx: idx, pkgs: make(map[string]bool)}
+	for _, f := range idx.Files {
+		x.pkgs[f.Pkg] = true
+	}
+	return x, nil
+}
+
+func (x *indexer) rel(file string) string {
+	rel, err := filepath.Rel(x.root, file)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (x *indexer) pos(fset *token.FileSet, p token.Pos) *Pos {
+	at := fset.Position(p)
+	return &Pos{File: x.rel(at.Filename), Line: at.Line, Col: at.Column}
+}
+
+// namedOf returns the named type of t or what t points to.
+func namedOf(t types.Type) *types.Named {
+	t = types.Unalias(t)
+	if p, ok := t.(*types.Pointer); ok {
+		t = types.Unalias(p.Elem())
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Origin()
+	}
+	return nil
+}
+
+// fieldOwner returns the named struct type that a selected field is
+// declared in.
+func fieldOwner(sel *types.Selection) *types.Named {
+	t := sel.Recv()
+	index := sel.Index()
+	for _, i := range index[:len(index)-1] {
+		st, ok := derefType(t).Underlying().(*types.Struct)
+		if !ok {
+			return nil
+		}
+		t = st.Field(i).Type()
+	}
+	return namedOf(t)
+}
+
+func derefType(t types.Type) types.Type {
+	if p, ok := types.Unalias(t).(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// objID returns the symbol ID of a used object, or an empty string if the
+// object is not a symbol in the index. owner is the type that the object
+// is a field of.
+func (x *indexer) objID(obj types.Object, owner *types.Named) string {
+	pkg := obj.Pkg()
+	if pkg == nil || !x.pkgs[pkg.Path()] {
+		return ""
+	}
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return ""
+	case *types.Func:
+		recv := obj.Type().(*types.Signature).Recv()
+		if recv == nil {
+			break
+		}
+		named := namedOf(recv.Type())
+		if named == nil {
+			return ""
+		}
+		return pkg.Path() + "." + named.Obj().Name() + "." + obj.Name()
+	case *types.Var:
+		if !obj.IsField() {
+			break
+		}
+		if owner == nil || owner.Obj().Pkg().Path() != pkg.Path() {
+			return ""
+		}
+		return pkg.Path() + "." + owner.Obj().Name() + "." + obj.Name()
+	}
+	if obj.Parent() != pkg.Scope() {
+		return "" // local object
+	}
+	return pkg.Path() + "." + obj.Name()
+}
+
+// fieldOwners maps the identifiers of selected fields and the keys in
+// struct literals to the types that the fields are declared in.
+func fieldOwners(pkg *packages.Package) map[*ast.Ident]*types.Named {
+	info := pkg.TypesInfo
+	owners := make(map[*ast.Ident]*types.Named)
+	for expr, sel := range info.Selections {
+		if sel.Kind() == types.FieldVal {
+			owners[expr.Sel] = fieldOwner(sel)
+		}
+	}
+	for _, f := range pkg.Syntax {
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.CompositeLit)
+			if !ok {
+				return true
+			}
+			named := namedOf(info.TypeOf(lit))
+			if named == nil {
+				return true
+			}
+			for _, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := kv.Key.(*ast.Ident); ok {
+					owners[key] = named
+				}
+			}
+			return true
+		})
+	}
+	return owners
+}
+
+func (x *indexer) addRefs(pkg *packages.Package) {
+	owners := fieldOwners(pkg)
+	for ident, obj := range pkg.TypesInfo.Uses {
+		id := x.objID(obj, owners[ident])
+		if id == "" {
+			continue
+		}
+		p := x.pos(pkg.Fset, ident.Pos())
+		f, ok := x.idx.Files[p.File]
+		if !ok {
+			continue // generated or cgo file
+		}
+		f.Refs = append(f.Refs, &Ref{ID: id, Line: p.Line, Col: p.Col})
+	}
+	for _, f := range pkg.Syntax {
+		file := x.idx.Files[x.rel(pkg.Fset.Position(f.Pos()).Filename)]
+		sortRefs(file.Refs)
+	}
+}
+
+func sortRefs(refs []*Ref) {
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+}
@@ -0,0 +1,92 @@
+// Package gounused detects the exported identifiers of a module that are
+// not used outside their own packages.
+//
+// Only package level identifiers are covered. Exported methods and struct
+// fields are not reported, as they are often used without being named,
+// like methods that implement interfaces and fields that are encoded by
+// reflection.
+package gounused
+
+import (
+	"path"
+	"strings"
+
+	"shanhu.io/g/jsonutil"
+	"shanhu.io/std/errcode"
+)
+
+// ConfigFile is the default name of the config file, which is at the root
+// of a module.
+const ConfigFile = "gounused.json"
+
+// Config configures the detection.
+//
+// Identifiers are named by their packages relative to the module and their
+// names, like "creds.NewLogin". Identifiers in the module root package are
+// named by their names only.
+type Config struct {
+	// Deps are the directories of the modules that use the module, relative
+	// to the module directory. The references from all of their packages
+	// are counted.
+	Deps []string `json:",omitempty"`
+
+	// Allow lists the identifiers that are not reported even if they are
+	// not used. An entry is either a package pattern like "httputil" or
+	// "httputil/...", which allows all the identifiers in the packages, or
+	// an identifier pattern like "httputil.Must*", which is matched with
+	// path.Match.
+	Allow []string `json:",omitempty"`
+
+	// Tests counts the references from the tests of other packages.
+	// References from the tests of the package itself are never counted.
+	Tests bool `json:",omitempty"`
+}
+
+func matchPkg(pattern, p string) bool {
+	if pattern == "..." {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+		return p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+	return pattern == p
+}
+
+// allowed checks if an identifier in package p is allowed.
+func (c *Config) allowed(p, id string) bool {
+	for _, pattern := range c.Allow {
+		if matchPkg(pattern, p) {
+			return true
+		}
+		if ok, _ := path.Match(pattern, id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) check() error {
+	for _, pattern := range c.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errcode.InvalidArgf("invalid allow pattern %q", pattern)
+		}
+	}
+	for _, dep := range c.Deps {
+		if dep == "" {
+			return errcode.InvalidArgf("empty dependent module dir")
+		}
+	}
+	return nil
+}
+
+// ReadConfig reads the config from a JSON file.
+func ReadConfig(f string) (*Config, error) {
+	c := new(Config)
+	if err := jsonutil.ReadFile(f, c); err != nil {
+		return nil, err
+	}
+	if err := c.check(); err != nil {
+		return nil, errcode.Annotatef(err, "invalid config in %q", f)
+	}
+	return c, nil
+}
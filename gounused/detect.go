@@ -0,0 +1,265 @@
+package gounused
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"shanhu.io/g/gocheck"
+	"shanhu.io/g/gomod"
+	"shanhu.io/std/errcode"
+	"shanhu.io/std/lexing"
+)
+
+// def is an exported package level identifier.
+type def struct {
+	pkg  string // package relative to the module
+	name string
+	kind string
+	pos  token.Position
+}
+
+// id returns the name of the identifier relative to the module.
+func (d *def) id() string {
+	if d.pkg == "." {
+		return d.name
+	}
+	return d.pkg + "." + d.name
+}
+
+type detector struct {
+	mod   string
+	defs  map[string]*def // keyed by package path and name
+	used  map[string]bool
+	files []*ast.File
+}
+
+// relPkg returns the path of a package relative to the module, or an
+// empty string if the package is not in the module.
+func (d *detector) relPkg(p string) string {
+	if p == d.mod {
+		return "."
+	}
+	if rel, ok := strings.CutPrefix(p, d.mod+"/"); ok {
+		return rel
+	}
+	return ""
+}
+
+// ownerPkg returns the path of the package that the code of a loaded
+// package belongs to, where tests belong to the package under test. It
+// returns an empty string for test binaries.
+func ownerPkg(pkg *packages.Package) string {
+	if pkg.ForTest != "" && pkg.PkgPath == pkg.ForTest+"_test" {
+		return pkg.ForTest
+	}
+	if strings.HasSuffix(pkg.PkgPath, ".test") && pkg.Name == "main" {
+		return ""
+	}
+	return pkg.PkgPath
+}
+
+func objKind(obj types.Object) string {
+	switch obj.(type) {
+	case *types.Const:
+		return "const"
+	case *types.Var:
+		return "var"
+	case *types.Func:
+		return "func"
+	case *types.TypeName:
+		return "type"
+	}
+	return ""
+}
+
+func (d *detector) addDefs(pkg *packages.Package) {
+	if pkg.ID != pkg.PkgPath || pkg.Name == "main" {
+		return // test variant or command
+	}
+	rel := d.relPkg(pkg.PkgPath)
+	if rel == "" {
+		return
+	}
+	d.files = append(d.files, pkg.Syntax...)
+
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		d.defs[pkg.PkgPath+"."+name] = &def{
+			pkg:  rel,
+			name: name,
+			kind: objKind(obj),
+			pos:  pkg.Fset.Position(obj.Pos()),
+		}
+	}
+}
+
+// namedOf returns the named type of t or what t points to.
+func namedOf(t types.Type) *types.Named {
+	t = types.Unalias(t)
+	if p, ok := t.(*types.Pointer); ok {
+		t = types.Unalias(p.Elem())
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Origin()
+	}
+	return nil
+}
+
+// use marks a package level object as used by the code in package from.
+func (d *detector) use(from string, obj types.Object) {
+	pkg := obj.Pkg()
+	if pkg == nil || pkg.Path() == from {
+		return
+	}
+	if obj.Parent() != pkg.Scope() {
+		return
+	}
+	d.used[pkg.Path()+"."+obj.Name()] = true
+}
+
+func (d *detector) useType(from string, t types.Type) {
+	if named := namedOf(t); named != nil {
+		d.use(from, named.Obj())
+	}
+}
+
+// useSelection marks the types that a selected field or method is reached
+// through as used, so that a type is used when its methods or fields are
+// used, even if the type is not named.
+func (d *detector) useSelection(from string, sel *types.Selection) {
+	t := sel.Recv()
+	d.useType(from, t)
+	index := sel.Index()
+	for _, i := range index[:len(index)-1] {
+		if p, ok := types.Unalias(t).(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		t = st.Field(i).Type()
+		d.useType(from, t)
+	}
+	if f, ok := sel.Obj().(*types.Func); ok {
+		if recv := f.Type().(*types.Signature).Recv(); recv != nil {
+			d.useType(from, recv.Type())
+		}
+	}
+}
+
+func (d *detector) addRefs(pkg *packages.Package) {
+	from := ownerPkg(pkg)
+	if from == "" || pkg.TypesInfo == nil {
+		return
+	}
+	for _, obj := range pkg.TypesInfo.Uses {
+		d.use(from, obj)
+	}
+	for _, sel := range pkg.TypesInfo.Selections {
+		d.useSelection(from, sel)
+	}
+}
+
+func (d *detector) unused(config *Config) []*lexing.Error {
+	var defs []*def
+	for key, def := range d.defs {
+		if !d.used[key] && !config.allowed(def.pkg, def.id()) {
+			defs = append(defs, def)
+		}
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		a, b := defs[i].pos, defs[j].pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+
+	errs := lexing.NewErrorList()
+	for _, def := range defs {
+		pos := &lexing.Pos{
+			File: def.pos.Filename,
+			Line: def.pos.Line,
+			Col:  def.pos.Column,
+		}
+		errs.CodeErrorf(
+			pos, "unused", "exported %s %s is not used outside its package",
+			def.kind, def.id(),
+		)
+	}
+	return errs.Errs()
+}
+
+func loadAll(dir string, env []string, tests bool) (
+	[]*packages.Package, error,
+) {
+	config := &gocheck.LoadConfig{Dir: dir, Env: env, Tests: tests}
+	pkgs, err := gocheck.Load(config, "./...")
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, errcode.Annotatef(
+				pkg.Errors[0], "load package %q", pkg.PkgPath,
+			)
+		}
+	}
+	return pkgs, nil
+}
+
+// Detect finds the exported package level identifiers in the module at dir
+// that are not used outside their own packages, by the packages in the
+// module and in the dependent modules. Methods and fields are not covered.
+// env is the environment of the go command, nil for the current one. The
+// findings can be suppressed by "//gocheck:ignore unused" comments.
+func Detect(dir string, env []string, config *Config) (
+	[]*lexing.Error, error,
+) {
+	mod, err := gomod.ReadModulePath(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, errcode.Annotate(err, "read module path")
+	}
+	d := &detector{
+		mod:  mod,
+		defs: make(map[string]*def),
+		used: make(map[string]bool),
+	}
+
+	pkgs, err := loadAll(dir, env, config.Tests)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		d.addDefs(pkg)
+		d.addRefs(pkg)
+	}
+	for _, dep := range config.Deps {
+		if !filepath.IsAbs(dep) {
+			dep = filepath.Join(dir, dep)
+		}
+		depPkgs, err := loadAll(dep, env, config.Tests)
+		if err != nil {
+			return nil, errcode.Annotatef(err, "load module in %q", dep)
+		}
+		for _, pkg := range depPkgs {
+			d.addRefs(pkg)
+		}
+	}
+
+	errs := d.unused(config)
+	if len(pkgs) == 0 {
+		return errs, nil
+	}
+	return gocheck.FilterIgnored(pkgs[0].Fset, d.files, errs), nil
+}
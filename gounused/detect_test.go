@@ -0,0 +1,102 @@
+package gounused
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"x/go.mod": "module example.com/x\n\ngo 1.21\n",
+		"x/a/a.go": `package a
+
+type Config struct{ Name string }
+
+type Server struct{ c *Config }
+
+func (s *Server) Serve() {}
+
+func NewServer() *Server { return &Server{} }
+
+func Legacy() {}
+
+func Ignored() {} //gocheck:ignore unused
+
+func Helper() {}
+
+const Version = "1"
+`,
+		"x/a/a_test.go": "package a_test\n\nimport \"example.com/x/a\"\n" +
+			"\nvar _ = a.Helper\n",
+		"x/b/b.go": `package b
+
+import "example.com/x/a"
+
+func Run() { a.NewServer().Serve() }
+`,
+		"x/b/b_test.go": "package b\n\nimport \"example.com/x/a\"\n" +
+			"\nvar _ = a.Legacy\n",
+		"y/go.mod": "module example.com/y\n\ngo 1.21\n\n" +
+			"require example.com/x v0.0.0\n\n" +
+			"replace example.com/x => ../x\n",
+		"y/y.go": "package y\n\nimport \"example.com/x/b\"\n" +
+			"\nfunc Y() { b.Run() }\n",
+	} {
+		f := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	for _, test := range []struct {
+		config *Config
+		want   []string
+	}{{
+		config: &Config{},
+		want: []string{
+			"exported type a.Config is not used outside its package",
+			"exported func a.Legacy is not used outside its package",
+			"exported func a.Helper is not used outside its package",
+			"exported const a.Version is not used outside its package",
+			"exported func b.Run is not used outside its package",
+		},
+	}, {
+		config: &Config{
+			Deps:  []string{"../y"},
+			Allow: []string{"a.Con*", "a.Version"},
+		},
+		want: []string{
+			"exported func a.Legacy is not used outside its package",
+			"exported func a.Helper is not used outside its package",
+		},
+	}, {
+		config: &Config{Deps: []string{"../y"}, Tests: true},
+		want: []string{
+			"exported type a.Config is not used outside its package",
+			"exported func a.Helper is not used outside its package",
+			"exported const a.Version is not used outside its package",
+		},
+	}, {
+		config: &Config{Deps: []string{"../y"}, Allow: []string{"a"}},
+		want:   nil,
+	}} {
+		errs, err := Detect(filepath.Join(dir, "x"), env, test.config)
+		if err != nil {
+			t.Fatal("detect: ", err)
+		}
+		var got []string
+		for _, err := range errs {
+			got = append(got, err.Err.Error())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("config %+v: got %q, want %q", test.config, got, test.want)
+		}
+	}
+}
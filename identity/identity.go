@@ -2,6 +2,7 @@ package identity
 
 import (
 	"context"
+	"time"
 )
 
 // Identity is the identity of a service or a robot.
@@ -20,7 +21,7 @@ type PublicKey struct {
 	Type           string
 	Alg            string // Signing alghorithm,must use JWT alg codes.
 	Key            string // Key content.
-	NotValidAfter  int64
+	NotValidAfter  int64  // Zero never expires only for keys of JWK sets.
 	NotValidBefore int64  `json:",omitempty"`
 	Comment        string `json:",omitempty"`
 }
@@ -43,3 +44,21 @@ type Card interface {
 	// Identity fetches the identity of the service.
 	Identity(ctx context.Context) (*Identity, error)
 }
+
+// KeyFinder is a Card that can find a particular public key directly, for
+// example by fetching the key on demand when the key ID is unknown.
+type KeyFinder interface {
+	Card
+
+	// PublicKey finds the public key of the given ID.
+	PublicKey(ctx context.Context, keyID string) (*PublicKey, error)
+}
+
+// KeyValidator is a Card that has its own rules for checking if its keys
+// are valid, for example when its keys carry no expire time.
+type KeyValidator interface {
+	Card
+
+	// KeyValid checks if the public key is valid at time t.
+	KeyValid(k *PublicKey, t time.Time) error
+}
@@ -0,0 +1,149 @@
+package identity
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+
+	"golang.org/x/crypto/ssh"
+	"shanhu.io/g/jwt"
+	"shanhu.io/g/rsautil"
+	"shanhu.io/std/errcode"
+)
+
+// JWK is a JSON web key as defined in RFC 7517. Only RSA public keys are
+// supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON web key set.
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+const (
+	jwkTypeRSA = "RSA"
+	jwkUseSig  = "sig"
+)
+
+func encodeBigInt(x *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(x.Bytes())
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bs), nil
+}
+
+// PublicKeyJWK converts an identity public key into a JWK.
+func PublicKeyJWK(k *PublicKey) (*JWK, error) {
+	if k.Type != rsaKeyType {
+		return nil, errcode.InvalidArgf("key type %q not supported", k.Type)
+	}
+	pub, err := rsautil.ParsePublicKey([]byte(k.Key))
+	if err != nil {
+		return nil, errcode.Annotate(err, "parse key")
+	}
+	return &JWK{
+		Kty: jwkTypeRSA,
+		Use: jwkUseSig,
+		Kid: k.ID,
+		Alg: k.Alg,
+		N:   encodeBigInt(pub.N),
+		E:   encodeBigInt(big.NewInt(int64(pub.E))),
+	}, nil
+}
+
+// JWKPublicKey converts a JWK into an identity public key. JWKs do not carry
+// validity periods, so the returned key has no expiry.
+func JWKPublicKey(k *JWK) (*PublicKey, error) {
+	if k.Kty != jwkTypeRSA {
+		return nil, errcode.InvalidArgf("key type %q not supported", k.Kty)
+	}
+	if k.Use != "" && k.Use != jwkUseSig {
+		return nil, errcode.InvalidArgf("key use %q not supported", k.Use)
+	}
+
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, errcode.InvalidArgf("decode modulus: %s", err)
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, errcode.InvalidArgf("decode exponent: %s", err)
+	}
+	if n.Sign() <= 0 || !e.IsInt64() || e.Int64() <= 1 || e.BitLen() > 31 {
+		return nil, errcode.InvalidArgf("invalid rsa key")
+	}
+
+	sshPub, err := ssh.NewPublicKey(&rsa.PublicKey{N: n, E: int(e.Int64())})
+	if err != nil {
+		return nil, errcode.Annotate(err, "convert key")
+	}
+
+	alg := k.Alg
+	if alg == "" {
+		alg = jwt.AlgRS256
+	}
+	return &PublicKey{
+		ID:   k.Kid,
+		Type: rsaKeyType,
+		Alg:  alg,
+		Key:  string(ssh.MarshalAuthorizedKey(sshPub)),
+	}, nil
+}
+
+// ToJWKS converts the public keys of an identity into a JWK set.
+func ToJWKS(id *Identity) (*JWKS, error) {
+	set := &JWKS{Keys: []*JWK{}}
+	for _, k := range id.PublicKeys {
+		jwk, err := PublicKeyJWK(k)
+		if err != nil {
+			return nil, errcode.Annotatef(err, "convert key %q", k.ID)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// FromJWKS converts a JWK set into an identity. Keys that are not
+// supported, such as non-RSA keys or encryption keys, are skipped.
+func FromJWKS(set *JWKS) *Identity {
+	id := new(Identity)
+	for _, k := range set.Keys {
+		pub, err := JWKPublicKey(k)
+		if err != nil {
+			continue
+		}
+		id.PublicKeys = append(id.PublicKeys, pub)
+	}
+	return id
+}
+
+// OpenIDConfig is an OpenID-style discovery document, which is often served
+// at "/.well-known/openid-configuration".
+type OpenIDConfig struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+
+	AuthorizationEndpoint string `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint         string `json:"token_endpoint,omitempty"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint,omitempty"`
+
+	ResponseTypes []string `json:"response_types_supported,omitempty"`
+	SubjectTypes  []string `json:"subject_types_supported,omitempty"`
+	IDTokenAlgs   []string `json:"id_token_signing_alg_values_supported"`
+	Scopes        []string `json:"scopes_supported,omitempty"`
+	Claims        []string `json:"claims_supported,omitempty"`
+
+	PKCEMethods []string `json:"code_challenge_methods_supported,omitempty"`
+}
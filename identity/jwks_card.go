@@ -0,0 +1,214 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"shanhu.io/g/httputil"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// JWKSCardConfig is the configuration for creating a JWKS card.
+type JWKSCardConfig struct {
+	// URL of the JWK set document.
+	URL string
+
+	// Optional HTTP client to fetch the document.
+	Client *http.Client
+
+	// Optional; how long a fetched key set is cached. Default one hour.
+	CacheTTL time.Duration
+
+	// Optional; how long an expired key set can still be used when it
+	// cannot be refreshed. Default one day.
+	MaxStale time.Duration
+
+	// Optional; minimal interval between two refreshes that are triggered
+	// by unknown key IDs. Default one minute.
+	MinRefreshInterval time.Duration
+
+	Now func() time.Time
+}
+
+// JWKSCard is an identity card that fetches a remote JSON web key set, such
+// as the one published by a standard OpenID Connect identity provider. The
+// key set is cached, and refreshed when it expires or when a token presents
+// an unknown key ID.
+type JWKSCard struct {
+	url      string
+	client   *http.Client
+	ttl      time.Duration
+	maxStale time.Duration
+	minGap   time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	cache       *Identity
+	cacheExpire time.Time
+	lastFetch   time.Time
+}
+
+// NewJWKSCard creates a new card that fetches keys from a JWKS URL.
+func NewJWKSCard(config *JWKSCardConfig) *JWKSCard {
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ttl := config.CacheTTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	maxStale := config.MaxStale
+	if maxStale <= 0 {
+		maxStale = timeutil.Day
+	}
+	minGap := config.MinRefreshInterval
+	if minGap <= 0 {
+		minGap = time.Minute
+	}
+	return &JWKSCard{
+		url:      config.URL,
+		client:   client,
+		ttl:      ttl,
+		maxStale: maxStale,
+		minGap:   minGap,
+		now:      timeutil.NowFunc(config.Now),
+	}
+}
+
+// FetchJWKS fetches a JWK set from the given URL.
+func FetchJWKS(ctx context.Context, c *http.Client, u string) (*JWKS, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errcode.Annotate(err, "make request")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, httputil.RespError(resp)
+	}
+
+	set := new(JWKS)
+	if err := json.NewDecoder(resp.Body).Decode(set); err != nil {
+		return nil, errcode.Annotate(err, "decode key set")
+	}
+	return set, nil
+}
+
+// fetch fetches the key set without holding the mutex, and caches it.
+func (c *JWKSCard) fetch(ctx context.Context) (*Identity, error) {
+	set, err := FetchJWKS(ctx, c.client, c.url)
+	if err != nil {
+		return nil, errcode.Annotate(err, "fetch key set")
+	}
+	id := FromJWKS(set)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = id
+	c.cacheExpire = c.now().Add(c.ttl)
+	return id, nil
+}
+
+func (c *JWKSCard) refresh(ctx context.Context) (*Identity, error) {
+	c.mu.Lock()
+	c.lastFetch = c.now()
+	c.mu.Unlock()
+	return c.fetch(ctx)
+}
+
+// ensure returns the cached key set, and refreshes it when it expires.
+// When the refresh fails, it falls back to the stale key set, for at most
+// MaxStale after the key set expires.
+func (c *JWKSCard) ensure(ctx context.Context) (*Identity, error) {
+	c.mu.Lock()
+	cache, expire := c.cache, c.cacheExpire
+	c.mu.Unlock()
+
+	now := c.now()
+	if cache != nil && !now.After(expire) {
+		return cache, nil
+	}
+	id, err := c.refresh(ctx)
+	if err != nil {
+		if cache != nil && !now.After(expire.Add(c.maxStale)) {
+			log.Printf("refresh %q, use stale keys: %s", c.url, err)
+			return cache, nil
+		}
+		return nil, err
+	}
+	return id, nil
+}
+
+// Refresh forces a refresh of the cached key set.
+func (c *JWKSCard) Refresh(ctx context.Context) error {
+	_, err := c.refresh(ctx)
+	return err
+}
+
+// KeyValid checks if a key from the key set is valid at time t. Keys of
+// JWK sets carry no expire time, and never expire unless NotValidAfter is
+// set.
+func (c *JWKSCard) KeyValid(k *PublicKey, t time.Time) error {
+	return jwksKeyValid(k, t)
+}
+
+// Identity returns the identity converted from the remote key set.
+func (c *JWKSCard) Identity(ctx context.Context) (*Identity, error) {
+	return c.ensure(ctx)
+}
+
+// refreshMissing refreshes the key set for an unknown key ID, at most once
+// every minimal refresh interval. It returns false if it is too soon.
+func (c *JWKSCard) refreshMissing(ctx context.Context) (
+	*Identity, bool, error,
+) {
+	c.mu.Lock()
+	now := c.now()
+	if now.Sub(c.lastFetch) < c.minGap {
+		c.mu.Unlock()
+		return nil, false, nil
+	}
+	c.lastFetch = now
+	c.mu.Unlock()
+
+	id, err := c.fetch(ctx)
+	return id, true, err
+}
+
+// PublicKey returns the public key of the given key ID. If the key is not
+// in the cached key set, the key set is refreshed, at most once every
+// minimal refresh interval. The key set is fetched without holding the
+// lock, so lookups of known keys are not blocked by fetching.
+func (c *JWKSCard) PublicKey(ctx context.Context, keyID string) (
+	*PublicKey, error,
+) {
+	id, err := c.ensure(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if k := FindPublicKey(id, keyID); k != nil {
+		return k, nil
+	}
+
+	id, ok, err := c.refreshMissing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errcode.NotFoundf("key not found")
+	}
+	if k := FindPublicKey(id, keyID); k != nil {
+		return k, nil
+	}
+	return nil, errcode.NotFoundf("key not found")
+}
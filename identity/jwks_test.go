@@ -0,0 +1,164 @@
+package identity
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/jwt"
+	"shanhu.io/g/timeutil"
+)
+
+func TestJWKSRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	core := NewMemCore(func() time.Time { return now })
+	config := SingleKeyCoreConfig(now.Add(time.Hour))
+	if _, err := core.Init(config); err != nil {
+		t.Fatal("init core: ", err)
+	}
+	id, err := core.Identity(ctx)
+	if err != nil {
+		t.Fatal("get identity: ", err)
+	}
+
+	set, err := ToJWKS(id)
+	if err != nil {
+		t.Fatal("convert to jwks: ", err)
+	}
+	if len(set.Keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(set.Keys))
+	}
+
+	back := FromJWKS(set)
+	if len(back.PublicKeys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(back.PublicKeys))
+	}
+	got, want := back.PublicKeys[0], id.PublicKeys[0]
+	if got.ID != want.ID {
+		t.Errorf("got key id %q, want %q", got.ID, want.ID)
+	}
+	if got.Key != want.Key {
+		t.Errorf("got key %q, want %q", got.Key, want.Key)
+	}
+}
+
+type swapCard struct {
+	card Card
+}
+
+func (c *swapCard) Identity(ctx context.Context) (*Identity, error) {
+	return c.card.Identity(ctx)
+}
+
+func TestJWKSCard(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	nowFunc := func() time.Time { return now }
+
+	core1 := NewMemCore(nowFunc)
+	core2 := NewMemCore(nowFunc)
+	for _, core := range []Core{core1, core2} {
+		config := SingleKeyCoreConfig(now.Add(time.Hour))
+		if _, err := core.Init(config); err != nil {
+			t.Fatal("init core: ", err)
+		}
+	}
+
+	published := &swapCard{card: core1}
+	r := aries.NewRouter()
+	r.DirService(".well-known", NewDiscoveryService(published, &DiscoveryConfig{
+		Issuer: "https://id.example.com",
+	}))
+	s := httptest.NewServer(aries.Serve(r))
+	defer s.Close()
+
+	card := NewJWKSCard(&JWKSCardConfig{
+		URL: s.URL + "/.well-known/jwks.json",
+		Now: nowFunc,
+	})
+	v := NewJWTVerifier(card)
+
+	sign := func(core Core) string {
+		claims := &jwt.ClaimSet{
+			Iss: "https://id.example.com",
+			Aud: "app",
+			Sub: "h8liu",
+			Iat: now.Unix(),
+			Exp: now.Add(time.Hour).Unix(),
+		}
+		tok, err := jwt.EncodeAndSign(ctx, claims, NewJWTSigner(core))
+		if err != nil {
+			t.Fatal("sign token: ", err)
+		}
+		return tok
+	}
+
+	if _, err := jwt.DecodeAndVerify(ctx, sign(core1), v, now); err != nil {
+		t.Fatal("verify token: ", err)
+	}
+
+	// Rotate the published keys. The new key is not found right away
+	// because the last fetch was just now.
+	published.card = core2
+	tok2 := sign(core2)
+	if _, err := jwt.DecodeAndVerify(ctx, tok2, v, now); err == nil {
+		t.Fatal("token with unknown key verified before refresh")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := jwt.DecodeAndVerify(ctx, tok2, v, now); err != nil {
+		t.Fatal("verify token after key rotation: ", err)
+	}
+}
+
+func TestJWKSCardStale(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	nowFunc := func() time.Time { return now }
+
+	core := NewMemCore(nowFunc)
+	if _, err := core.Init(SingleKeyCoreConfig(now.Add(time.Hour))); err != nil {
+		t.Fatal("init core: ", err)
+	}
+	s := httptest.NewServer(aries.Serve(NewService(core)))
+	card := NewJWKSCard(&JWKSCardConfig{
+		URL: s.URL + "/jwks.json",
+		Now: nowFunc,
+	})
+	id, err := card.Identity(ctx)
+	if err != nil {
+		t.Fatal("fetch key set: ", err)
+	}
+
+	// The cache expires while the server is down.
+	s.Close()
+	now = now.Add(2 * time.Hour)
+	stale, err := card.Identity(ctx)
+	if err != nil {
+		t.Fatal("want stale keys, got error: ", err)
+	}
+	if stale != id {
+		t.Error("want the stale key set")
+	}
+	if err := card.Refresh(ctx); err == nil {
+		t.Error("forced refresh succeeded with the server down")
+	}
+
+	// Keys without expire time only never expire in JWK sets.
+	k := *id.PublicKeys[0]
+	if err := card.KeyValid(&k, now); err != nil {
+		t.Error("JWKS key without expire time: ", err)
+	}
+	if err := publicKeyValid(&k, now); err == nil {
+		t.Error("identity key without expire time is valid")
+	}
+
+	// Stale keys are not used for too long.
+	now = now.Add(timeutil.Day)
+	if _, err := card.Identity(ctx); err == nil {
+		t.Error("used stale keys for too long")
+	}
+}
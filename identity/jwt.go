@@ -11,11 +11,13 @@ import (
 
 	"shanhu.io/g/jwt"
 	"shanhu.io/g/rsautil"
+	"shanhu.io/g/timeutil"
 	"shanhu.io/std/errcode"
 )
 
 type jwtSigner struct {
 	signer Signer
+	now    func() time.Time
 }
 
 func (s *jwtSigner) Header(ctx context.Context) (*jwt.Header, error) {
@@ -23,10 +25,10 @@ func (s *jwtSigner) Header(ctx context.Context) (*jwt.Header, error) {
 	if err != nil {
 		return nil, errcode.Annotate(err, "fetch identity")
 	}
-	if len(id.PublicKeys) == 0 {
-		return nil, errcode.Annotate(err, "no signing keys")
+	k := SigningKey(id, s.now())
+	if k == nil {
+		return nil, errcode.NotFoundf("no valid signing key")
 	}
-	k := id.PublicKeys[len(id.PublicKeys)-1]
 
 	return &jwt.Header{
 		Alg:   k.Alg,
@@ -48,6 +50,10 @@ func (s *jwtSigner) Sign(
 func publicKeyFromCard(ctx context.Context, card Card, keyID string) (
 	*PublicKey, error,
 ) {
+	if finder, ok := card.(KeyFinder); ok {
+		return finder.PublicKey(ctx, keyID)
+	}
+
 	id, err := card.Identity(ctx)
 	if err != nil {
 		return nil, errcode.Annotate(err, "fetch identity")
@@ -86,7 +92,11 @@ func (v *jwtVerifier) Verify(
 	if k.Type != rsaKeyType {
 		return errcode.NotFoundf("key type not supported")
 	}
-	if err := publicKeyValid(k, t); err != nil {
+	valid := publicKeyValid
+	if kv, ok := v.card.(KeyValidator); ok {
+		valid = kv.KeyValid
+	}
+	if err := valid(k, t); err != nil {
 		return errcode.Annotate(err, "invalid key")
 	}
 
@@ -119,11 +129,18 @@ func (s *jwtSigner) rsaPublicKeyPEM(ctx context.Context, keyID string) (
 	return pem.EncodeToMemory(block), nil
 }
 
-func newJWTSigner(signer Signer) *jwtSigner {
-	return &jwtSigner{signer: signer}
+func newJWTSigner(signer Signer, now func() time.Time) *jwtSigner {
+	return &jwtSigner{signer: signer, now: timeutil.NowFunc(now)}
 }
 
-// NewJWTSigner returns a JWT signer with given signer.
+// NewJWTSigner returns a JWT signer with given signer. It signs with the
+// key that is valid at the current time.
 func NewJWTSigner(signer Signer) jwt.Signer {
-	return newJWTSigner(signer)
+	return newJWTSigner(signer, nil)
+}
+
+// NewJWTSignerWithClock is like NewJWTSigner, but signs with the key that is
+// valid at the time returned by now.
+func NewJWTSignerWithClock(signer Signer, now func() time.Time) jwt.Signer {
+	return newJWTSigner(signer, now)
 }
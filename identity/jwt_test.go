@@ -20,7 +20,7 @@ func TestJWT(t *testing.T) {
 		t.Fatal("init core: ", err)
 	}
 
-	signer := newJWTSigner(core)
+	signer := newJWTSigner(core, nil)
 
 	claim := &jwt.ClaimSet{
 		Iss: "shanhu.io",
@@ -52,3 +52,24 @@ func TestJWT(t *testing.T) {
 
 	t.Logf("public key:\n%s", pub)
 }
+
+func TestJWTSignerClock(t *testing.T) {
+	ctx := context.Background()
+
+	// The key is already expired by the wall clock.
+	now := time.Now().Add(-48 * time.Hour)
+	clock := func() time.Time { return now }
+	core := NewMemCore(clock)
+	id, err := core.Init(SingleKeyCoreConfig(now.Add(time.Hour)))
+	if err != nil {
+		t.Fatal("init core: ", err)
+	}
+
+	h, err := newJWTSigner(core, clock).Header(ctx)
+	if err != nil {
+		t.Fatal("make header: ", err)
+	}
+	if want := id.PublicKeys[0].ID; h.KeyID != want {
+		t.Errorf("got key %q, want %q", h.KeyID, want)
+	}
+}
@@ -0,0 +1,191 @@
+package identity
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// RotationPolicy is the policy for rotating identity keys.
+type RotationPolicy struct {
+	// KeyLifetime is how long a key can be used for signing, starting from
+	// when it becomes valid. Default is 30 days.
+	KeyLifetime time.Duration
+
+	// Overlap is the time window for verifiers to fetch a new key before it
+	// is used for signing, and also the time window for tokens signed by the
+	// old key to stay valid after signing has switched to the new key.
+	// Default is one day.
+	Overlap time.Duration
+}
+
+// RotatorConfig is the configuration for creating a key rotator.
+type RotatorConfig struct {
+	Core   Core
+	Policy *RotationPolicy
+	Now    func() time.Time
+}
+
+// Rotator rotates the keys of an identity core. A new key is added
+// Overlap ahead of when it starts signing, so that verifiers have time to
+// fetch it, unless no current key lasts that long. Expired keys are
+// retired. Keys that are still valid are never retired, as they still
+// verify the tokens that they signed, and a new key is not added while
+// another one is waiting to become valid, so the core keeps the valid keys
+// and at most one pending key.
+type Rotator struct {
+	core     Core
+	lifetime time.Duration
+	overlap  time.Duration
+	now      func() time.Time
+}
+
+// NewRotator creates a new key rotator.
+func NewRotator(config *RotatorConfig) *Rotator {
+	r := &Rotator{
+		core:     config.Core,
+		lifetime: 30 * timeutil.Day,
+		overlap:  timeutil.Day,
+		now:      timeutil.NowFunc(config.Now),
+	}
+	if p := config.Policy; p != nil {
+		if p.KeyLifetime > 0 {
+			r.lifetime = p.KeyLifetime
+		}
+		if p.Overlap > 0 {
+			r.overlap = p.Overlap
+		}
+	}
+	return r
+}
+
+// CoreConfig returns the config for initializing a core with a single
+// key that follows the rotation policy.
+func (r *Rotator) CoreConfig() *CoreConfig {
+	now := r.now()
+	return &CoreConfig{
+		Keys: []*KeyConfig{{
+			NotValidAfter: now.Add(r.lifetime).Unix(),
+		}},
+	}
+}
+
+// RotateResult is the result of a rotation.
+type RotateResult struct {
+	Added   *PublicKey // The new key, if any.
+	Removed []string   // IDs of retired keys.
+}
+
+func (r *Rotator) needNewKey(id *Identity, now time.Time) bool {
+	if len(id.PublicKeys) == 0 {
+		return true
+	}
+	newest := id.PublicKeys[len(id.PublicKeys)-1]
+	if newest.NotValidAfter == 0 {
+		return false
+	}
+	if now.Before(time.Unix(newest.NotValidBefore, 0)) {
+		return false // The newest key is pending.
+	}
+	expire := time.Unix(newest.NotValidAfter, 0)
+	return !expire.After(now.Add(2 * r.overlap))
+}
+
+// keyOutlives checks if any key that is valid now is still valid at t.
+func keyOutlives(id *Identity, now, t time.Time) bool {
+	for _, k := range id.PublicKeys {
+		if publicKeyValid(k, now) == nil && publicKeyValid(k, t) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// addKey adds a new key that starts signing after Overlap. When no current
+// key lasts until then, the new key is valid immediately, so that there is
+// always a key to sign.
+func (r *Rotator) addKey(id *Identity, now time.Time) (*PublicKey, error) {
+	start := now.Add(r.overlap)
+	if !keyOutlives(id, now, start) {
+		start = now
+	}
+	return r.core.AddKey(&KeyConfig{
+		NotValidBefore: start.Unix(),
+		NotValidAfter:  start.Add(r.lifetime).Unix(),
+		Comment:        "rotated",
+	})
+}
+
+func (r *Rotator) retiredKeys(id *Identity, now time.Time) []string {
+	signing := SigningKey(id, now)
+	keys := id.PublicKeys
+
+	var ret []string
+	for i, k := range keys {
+		if k == signing || i == len(keys)-1 {
+			continue // Never retire the signing key or the newest key.
+		}
+		if k.NotValidAfter > 0 && !now.Before(time.Unix(k.NotValidAfter, 0)) {
+			ret = append(ret, k.ID)
+		}
+	}
+	return ret
+}
+
+// Rotate checks the keys of the core, adds a new key if the newest key is
+// expiring, and retires the keys that are expired.
+func (r *Rotator) Rotate(ctx context.Context) (*RotateResult, error) {
+	now := r.now()
+	id, err := r.core.Identity(ctx)
+	if err != nil {
+		return nil, errcode.Annotate(err, "fetch identity")
+	}
+
+	res := new(RotateResult)
+	if r.needNewKey(id, now) {
+		k, err := r.addKey(id, now)
+		if err != nil {
+			return nil, errcode.Annotate(err, "add key")
+		}
+		res.Added = k
+
+		// Reload to see the new key.
+		id, err = r.core.Identity(ctx)
+		if err != nil {
+			return nil, errcode.Annotate(err, "fetch identity")
+		}
+	}
+
+	for _, keyID := range r.retiredKeys(id, now) {
+		if err := r.core.RemoveKey(keyID); err != nil {
+			return res, errcode.Annotatef(err, "remove key %q", keyID)
+		}
+		res.Removed = append(res.Removed, keyID)
+	}
+	return res, nil
+}
+
+// Run rotates the keys every interval until the context is cancelled.
+// When interval is not positive, it checks every Overlap/4. Errors are
+// logged and do not stop the loop.
+func (r *Rotator) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = r.overlap / 4
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Rotate(ctx); err != nil {
+			log.Printf("rotate identity keys: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package identity
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRotator(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now()
+	now := start
+	nowFunc := func() time.Time { return now }
+
+	core := NewMemCore(nowFunc)
+	r := NewRotator(&RotatorConfig{
+		Core: core,
+		Policy: &RotationPolicy{
+			KeyLifetime: 10 * time.Hour,
+			Overlap:     time.Hour,
+		},
+		Now: nowFunc,
+	})
+
+	initID, err := core.Init(r.CoreConfig())
+	if err != nil {
+		t.Fatal("init core: ", err)
+	}
+	key1 := initID.PublicKeys[0].ID
+
+	signKey := func() string {
+		t.Helper()
+		sig, err := core.Sign(ctx, "", []byte("hello"))
+		if err != nil {
+			t.Fatal("sign: ", err)
+		}
+		return sig.KeyID
+	}
+	rotate := func() *RotateResult {
+		t.Helper()
+		res, err := r.Rotate(ctx)
+		if err != nil {
+			t.Fatal("rotate: ", err)
+		}
+		return res
+	}
+
+	if res := rotate(); res.Added != nil || len(res.Removed) != 0 {
+		t.Errorf("unexpected rotation on a fresh key: %+v", res)
+	}
+
+	now = start.Add(8 * time.Hour)
+	res := rotate()
+	if res.Added == nil {
+		t.Fatal("want a new key added")
+	}
+	key2 := res.Added.ID
+	if got := signKey(); got != key1 {
+		t.Errorf("signing with %q before overlap passes, want %q", got, key1)
+	}
+
+	now = start.Add(9*time.Hour + time.Second)
+	if res := rotate(); res.Added != nil || len(res.Removed) != 0 {
+		t.Errorf("unexpected rotation within overlap: %+v", res)
+	}
+	if got := signKey(); got != key2 {
+		t.Errorf("signing with %q after overlap, want %q", got, key2)
+	}
+
+	now = start.Add(10*time.Hour + time.Second)
+	res = rotate()
+	if len(res.Removed) != 1 || res.Removed[0] != key1 {
+		t.Errorf("got removed keys %q, want %q", res.Removed, key1)
+	}
+
+	id, err := core.Identity(ctx)
+	if err != nil {
+		t.Fatal("get identity: ", err)
+	}
+	if len(id.PublicKeys) != 1 || id.PublicKeys[0].ID != key2 {
+		t.Errorf("got %d keys left, want only the new key", len(id.PublicKeys))
+	}
+}
+
+func TestRotatorPendingKey(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	nowFunc := func() time.Time { return now }
+
+	core := NewSimpleCore(new(memStore), nowFunc)
+	r := NewRotator(&RotatorConfig{
+		Core: core,
+		Policy: &RotationPolicy{
+			KeyLifetime: time.Hour,
+			Overlap:     time.Hour,
+		},
+		Now: nowFunc,
+	})
+	if _, err := core.Init(r.CoreConfig()); err != nil {
+		t.Fatal("init core: ", err)
+	}
+
+	// With a lifetime shorter than two overlaps, the newest key is always
+	// expiring, but no key is added while a new key is pending.
+	for i := 0; i < 3; i++ {
+		if _, err := r.Rotate(ctx); err != nil {
+			t.Fatal("rotate: ", err)
+		}
+		id, err := core.Identity(ctx)
+		if err != nil {
+			t.Fatal("get identity: ", err)
+		}
+		if n := len(id.PublicKeys); n > 2 {
+			t.Fatalf("got %d keys, want at most 2", n)
+		}
+	}
+}
+
+func TestRotatorExpiredKey(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now()
+	now := start
+	nowFunc := func() time.Time { return now }
+
+	core := NewMemCore(nowFunc)
+	r := NewRotator(&RotatorConfig{
+		Core: core,
+		Policy: &RotationPolicy{
+			KeyLifetime: 10 * time.Hour,
+			Overlap:     time.Hour,
+		},
+		Now: nowFunc,
+	})
+	if _, err := core.Init(r.CoreConfig()); err != nil {
+		t.Fatal("init core: ", err)
+	}
+
+	// The rotator was not running when the key expired, so the new key is
+	// valid right away.
+	now = start.Add(10*time.Hour + time.Minute)
+	res, err := r.Rotate(ctx)
+	if err != nil {
+		t.Fatal("rotate: ", err)
+	}
+	if res.Added == nil {
+		t.Fatal("want a new key added")
+	}
+	sig, err := core.Sign(ctx, "", []byte("hello"))
+	if err != nil {
+		t.Fatal("sign with the new key: ", err)
+	}
+	if sig.KeyID != res.Added.ID {
+		t.Errorf("signed with %q, want %q", sig.KeyID, res.Added.ID)
+	}
+}
+
+func TestRotatorKeepsValidKeys(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	nowFunc := func() time.Time { return now }
+
+	core := NewMemCore(nowFunc)
+	r := NewRotator(&RotatorConfig{
+		Core: core,
+		Policy: &RotationPolicy{
+			KeyLifetime: 10 * time.Hour,
+			Overlap:     time.Hour,
+		},
+		Now: nowFunc,
+	})
+	if _, err := core.Init(r.CoreConfig()); err != nil {
+		t.Fatal("init core: ", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := core.AddKey(&KeyConfig{
+			NotValidAfter: now.Add(10 * time.Hour).Unix(),
+		}); err != nil {
+			t.Fatal("add key: ", err)
+		}
+	}
+
+	res, err := r.Rotate(ctx)
+	if err != nil {
+		t.Fatal("rotate: ", err)
+	}
+	if len(res.Removed) != 0 {
+		t.Errorf("retired valid keys %q", res.Removed)
+	}
+}
@@ -1,7 +1,11 @@
 package identity
 
 import (
+	"strings"
+
 	"shanhu.io/g/aries"
+	"shanhu.io/g/jwt"
+	"shanhu.io/std/errcode"
 )
 
 type service struct {
@@ -19,10 +23,76 @@ func (s *service) apiGet(c *aries.C, req *GetIDRequest) (*Identity, error) {
 	return s.card.Identity(c.Context)
 }
 
-// NewService creates a new identity service stub
+func (s *service) serveJWKS(c *aries.C) error {
+	id, err := s.card.Identity(c.Context)
+	if err != nil {
+		return errcode.Annotate(err, "fetch identity")
+	}
+	set, err := ToJWKS(id)
+	if err != nil {
+		return errcode.Internalf("convert identity: %s", err)
+	}
+	return aries.ReplyJSON(c, set)
+}
+
+// NewService creates a new identity service stub. Other than the "get" API
+// that replies the Identity, it also serves the public keys as a standard
+// JWK set at "jwks.json".
 func NewService(card Card) aries.Service {
 	s := newService(card)
 	r := aries.NewRouter()
 	r.Call("get", s.apiGet)
+	r.Get("jwks.json", s.serveJWKS)
+	return r
+}
+
+// DiscoveryConfig is the configuration of an OpenID-style discovery
+// service.
+type DiscoveryConfig struct {
+	// Issuer is the URL of the issuer, which is the "iss" claim in the
+	// tokens that are signed by the identity.
+	Issuer string
+
+	// Optional; default is Issuer + "/.well-known/jwks.json".
+	JWKSURI string
+
+	// Optional; endpoints for the authorization code flow. When set, the
+	// document advertises the "code" response type with PKCE.
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	UserInfoEndpoint      string
+}
+
+// NewDiscoveryService creates a service that serves an OpenID-style
+// discovery document at "openid-configuration" and the JWK set at
+// "jwks.json". The service is expected to be mounted at "/.well-known".
+func NewDiscoveryService(card Card, config *DiscoveryConfig) aries.Service {
+	jwksURI := config.JWKSURI
+	if jwksURI == "" {
+		jwksURI = strings.TrimSuffix(config.Issuer, "/") +
+			"/.well-known/jwks.json"
+	}
+	doc := &OpenIDConfig{
+		Issuer:        config.Issuer,
+		JWKSURI:       jwksURI,
+		ResponseTypes: []string{"id_token"},
+		SubjectTypes:  []string{"public"},
+		IDTokenAlgs:   []string{jwt.AlgRS256},
+
+		AuthorizationEndpoint: config.AuthorizationEndpoint,
+		TokenEndpoint:         config.TokenEndpoint,
+		UserInfoEndpoint:      config.UserInfoEndpoint,
+	}
+	if config.AuthorizationEndpoint != "" {
+		doc.ResponseTypes = append(doc.ResponseTypes, "code")
+		doc.PKCEMethods = []string{"S256"}
+	}
+
+	s := newService(card)
+	r := aries.NewRouter()
+	r.Get("openid-configuration", func(c *aries.C) error {
+		return aries.ReplyJSON(c, doc)
+	})
+	r.Get("jwks.json", s.serveJWKS)
 	return r
 }
@@ -2,6 +2,7 @@ package identity
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"shanhu.io/g/jwt"
@@ -24,6 +25,9 @@ type SignConfig struct {
 
 	Time   time.Time
 	Expiry time.Duration // Optional; default 5 minute.
+
+	// Optional; the scopes that the token asks for.
+	Scopes []string
 }
 
 // SignToken signs a self token or an access token.
@@ -58,8 +62,12 @@ func SignToken(ctx context.Context, signer Signer, config *SignConfig) (
 		Iat: config.Time.Unix(),
 		Exp: config.Time.Add(expiry).Unix(),
 	}
+	if len(config.Scopes) > 0 {
+		claims.Scope = strings.Join(config.Scopes, " ")
+	}
 
-	return jwt.EncodeAndSign(ctx, claims, NewJWTSigner(signer))
+	now := func() time.Time { return config.Time }
+	return jwt.EncodeAndSign(ctx, claims, NewJWTSignerWithClock(signer, now))
 }
 
 // SignSelf creates a self token.
@@ -6,10 +6,9 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
+	"sync"
 	"time"
 
-	"shanhu.io/g/hashutil"
-	"shanhu.io/g/jwt"
 	"shanhu.io/g/rsautil"
 	"shanhu.io/g/timeutil"
 	"shanhu.io/std/errcode"
@@ -20,11 +19,6 @@ type simpleData struct {
 	PrivateKeys []*privateKey `json:",omitempty"`
 }
 
-type privateKey struct {
-	ID  string // Related key id.
-	Key string // The private part.
-}
-
 // SimpleStore is a simple store for saving / loading data.
 // This can be used to implement a simple identity core.
 type SimpleStore interface {
@@ -41,6 +35,8 @@ type SimpleStore interface {
 type simpleCore struct {
 	store SimpleStore
 	now   func() time.Time
+
+	mu sync.Mutex // Serializes key changes.
 }
 
 // NewSimpleCore creates a new simple core using the given store.
@@ -52,9 +48,10 @@ func NewSimpleCore(store SimpleStore, t func() time.Time) Core {
 	}
 }
 
-const rsaKeyType = "ssh-rsa"
-
 func (c *simpleCore) Init(config *CoreConfig) (*Identity, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	check, err := c.store.Check()
 	if err != nil {
 		return nil, errcode.Annotate(err, "check key")
@@ -69,44 +66,20 @@ func (c *simpleCore) Init(config *CoreConfig) (*Identity, error) {
 
 	now := c.now()
 	for i, k := range config.Keys {
-		if k.Type != "" {
-			return nil, errcode.InvalidArgf("key #%d: type not supported", i)
-		}
-		if k.NotValidAfter == 0 {
-			return nil, errcode.InvalidArgf("key #%d: missing expire time", i)
-		}
-		expire := time.Unix(k.NotValidAfter, 0)
-		if expire.Before(now) {
-			return nil, errcode.InvalidArgf("key #%d: already expired", i)
-		}
-		if k.NotValidBefore != 0 && k.NotValidBefore >= k.NotValidAfter {
-			return nil, errcode.InvalidArgf("key #%d: never valid", i)
+		if err := checkKeyConfig(k, now); err != nil {
+			return nil, errcode.Annotatef(err, "key #%d", i)
 		}
 	}
 
 	id := new(Identity)
 	var privateKeys []*privateKey
-	const keySize = 2048
 	for i, k := range config.Keys {
-		pri, pub, err := rsautil.GenerateKey(nil, keySize)
+		pub, pri, err := generateKey(k)
 		if err != nil {
-			return nil, errcode.Internalf("generate key #%d", i)
+			return nil, errcode.Annotatef(err, "generate key #%d", i)
 		}
-
-		keyID := hashutil.Hash(pub)
-		id.PublicKeys = append(id.PublicKeys, &PublicKey{
-			ID:             keyID,
-			Type:           rsaKeyType,
-			Alg:            jwt.AlgRS256,
-			Key:            string(pub),
-			NotValidAfter:  k.NotValidAfter,
-			NotValidBefore: k.NotValidBefore,
-			Comment:        k.Comment,
-		})
-		privateKeys = append(privateKeys, &privateKey{
-			ID:  keyID,
-			Key: string(pri),
-		})
+		id.PublicKeys = append(id.PublicKeys, pub)
+		privateKeys = append(privateKeys, pri)
 	}
 
 	data := &simpleData{
@@ -131,10 +104,38 @@ func (c *simpleCore) Identity(ctx context.Context) (*Identity, error) {
 }
 
 func (c *simpleCore) AddKey(config *KeyConfig) (*PublicKey, error) {
-	return nil, errcode.Internalf("not implemented")
+	if err := checkKeyConfig(config, c.now()); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dat := new(simpleData)
+	if err := c.store.Load(dat); err != nil {
+		return nil, errcode.Annotate(err, "load identity")
+	}
+	if dat.Identity == nil {
+		return nil, errcode.Internalf("identity missing")
+	}
+
+	pub, pri, err := generateKey(config)
+	if err != nil {
+		return nil, errcode.Annotate(err, "generate key")
+	}
+	dat.Identity.PublicKeys = append(dat.Identity.PublicKeys, pub)
+	dat.PrivateKeys = append(dat.PrivateKeys, pri)
+
+	if err := c.store.Save(dat); err != nil {
+		return nil, errcode.Annotate(err, "save identity")
+	}
+	return pub, nil
 }
 
 func (c *simpleCore) RemoveKey(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	dat := new(simpleData)
 	if err := c.store.Load(dat); err != nil {
 		return errcode.Annotate(err, "load identity")
@@ -193,24 +194,27 @@ func (c *simpleCore) Sign(ctx context.Context, key string, blob []byte) (
 		return nil, errcode.Internalf("no key to sign")
 	}
 
-	// Pick the private key.
-	var pri *privateKey
+	now := c.now()
 	if key == "" {
-		// When key id not specified, always use the last key.
-		pri = id.PrivateKeys[len(id.PrivateKeys)-1]
-		key = pri.ID
-	} else {
-		// Pick private key based on ID.
-		for _, k := range id.PrivateKeys {
-			if k.ID == key {
-				pri = k
-				break
-			}
+		// When key id not specified, use the newest valid key.
+		k := SigningKey(id.Identity, now)
+		if k == nil {
+			return nil, errcode.NotFoundf("no valid key to sign")
 		}
-		if pri == nil {
-			return nil, errcode.NotFoundf("key not found")
+		key = k.ID
+	}
+
+	// Pick private key based on ID.
+	var pri *privateKey
+	for _, k := range id.PrivateKeys {
+		if k.ID == key {
+			pri = k
+			break
 		}
 	}
+	if pri == nil {
+		return nil, errcode.NotFoundf("key not found")
+	}
 
 	// Find the corresponding public key.
 	var pub *PublicKey
@@ -229,7 +233,6 @@ func (c *simpleCore) Sign(ctx context.Context, key string, blob []byte) (
 		return nil, errcode.Internalf("unknown key type: %s", pub.Type)
 	}
 
-	now := c.now()
 	if err := publicKeyValid(pub, now); err != nil {
 		return nil, errcode.Annotate(err, "invalid key")
 	}
@@ -0,0 +1,58 @@
+package identity
+
+import (
+	"time"
+
+	"shanhu.io/g/hashutil"
+	"shanhu.io/g/jwt"
+	"shanhu.io/g/rsautil"
+	"shanhu.io/std/errcode"
+)
+
+const rsaKeyType = "ssh-rsa"
+
+type privateKey struct {
+	ID  string // Related key id.
+	Key string // The private part.
+}
+
+func checkKeyConfig(k *KeyConfig, now time.Time) error {
+	if k.Type != "" {
+		return errcode.InvalidArgf("type not supported")
+	}
+	if k.NotValidAfter == 0 {
+		return errcode.InvalidArgf("missing expire time")
+	}
+	expire := time.Unix(k.NotValidAfter, 0)
+	if expire.Before(now) {
+		return errcode.InvalidArgf("already expired")
+	}
+	if k.NotValidBefore != 0 && k.NotValidBefore >= k.NotValidAfter {
+		return errcode.InvalidArgf("never valid")
+	}
+	return nil
+}
+
+func generateKey(k *KeyConfig) (*PublicKey, *privateKey, error) {
+	const keySize = 2048
+	pri, pub, err := rsautil.GenerateKey(nil, keySize)
+	if err != nil {
+		return nil, nil, errcode.Internalf("generate rsa key: %s", err)
+	}
+
+	keyID := hashutil.Hash(pub)
+	pubKey := &PublicKey{
+		ID:             keyID,
+		Type:           rsaKeyType,
+		Alg:            jwt.AlgRS256,
+		Key:            string(pub),
+		NotValidAfter:  k.NotValidAfter,
+		NotValidBefore: k.NotValidBefore,
+		Comment:        k.Comment,
+	}
+	priKey := &privateKey{
+		ID:  keyID,
+		Key: string(pri),
+	}
+	return pubKey, priKey, nil
+}
@@ -6,14 +6,44 @@ import (
 	"shanhu.io/std/errcode"
 )
 
-func publicKeyValid(k *PublicKey, now time.Time) error {
+func publicKeyStarted(k *PublicKey, now time.Time) error {
 	if k.NotValidBefore > 0 {
 		if now.Before(time.Unix(k.NotValidBefore, 0)) {
 			return errcode.InvalidArgf("key not valid yet")
 		}
 	}
+	return nil
+}
+
+func publicKeyValid(k *PublicKey, now time.Time) error {
+	if err := publicKeyStarted(k, now); err != nil {
+		return err
+	}
 	if now.After(time.Unix(k.NotValidAfter, 0)) {
 		return errcode.InvalidArgf("key expired")
 	}
 	return nil
 }
+
+// jwksKeyValid is like publicKeyValid, but for the keys from JWK sets,
+// which carry no expire time. Such keys never expire when NotValidAfter is
+// zero.
+func jwksKeyValid(k *PublicKey, now time.Time) error {
+	if k.NotValidAfter == 0 {
+		return publicKeyStarted(k, now)
+	}
+	return publicKeyValid(k, now)
+}
+
+// SigningKey returns the newest key in the identity that is valid at time
+// t. Keys that are added later are considered newer. Returns nil if no key
+// is valid.
+func SigningKey(id *Identity, t time.Time) *PublicKey {
+	for i := len(id.PublicKeys) - 1; i >= 0; i-- {
+		k := id.PublicKeys[i]
+		if publicKeyValid(k, t) == nil {
+			return k
+		}
+	}
+	return nil
+}
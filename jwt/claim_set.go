@@ -20,9 +20,31 @@ type ClaimSet struct {
 
 	Sub string `json:"sub"`
 
+	// Auds is the list of audiences, when "aud" is a list, which is allowed
+	// in OpenID Connect ID tokens. Aud is then the first audience.
+	Auds []string `json:"-"`
+
 	Extra map[string]any `json:"-"`
 }
 
+// HasAudience checks if aud is one of the audiences of the claim set.
+func (c *ClaimSet) HasAudience(aud string) bool {
+	if len(c.Auds) == 0 {
+		return c.Aud == aud
+	}
+	for _, a := range c.Auds {
+		if a == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes returns the list of scopes in the space-delimited Scope field.
+func (c *ClaimSet) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
 // ExtraString reads an extra string field from the claim set.
 func (c *ClaimSet) ExtraString(k string) (string, bool) {
 	if len(c.Extra) == 0 {
@@ -71,10 +93,28 @@ func decodeClaimSet(s string) (*ClaimSet, error) {
 		return nil, err
 	}
 
+	// Decodes "aud" separately, as it can be either a string or a list.
+	var raw struct {
+		*ClaimSet
+		Aud json.RawMessage `json:"aud"`
+	}
 	c := new(ClaimSet)
-	if err := json.Unmarshal(bs, c); err != nil {
+	raw.ClaimSet = c
+	if err := json.Unmarshal(bs, &raw); err != nil {
 		return nil, err
 	}
+	if len(raw.Aud) > 0 && raw.Aud[0] == '[' {
+		if err := json.Unmarshal(raw.Aud, &c.Auds); err != nil {
+			return nil, errcode.Annotate(err, "decode audiences")
+		}
+		if len(c.Auds) > 0 {
+			c.Aud = c.Auds[0]
+		}
+	} else if len(raw.Aud) > 0 {
+		if err := json.Unmarshal(raw.Aud, &c.Aud); err != nil {
+			return nil, errcode.Annotate(err, "decode audience")
+		}
+	}
 	m := make(map[string]any)
 	if err := json.Unmarshal(bs, &m); err != nil {
 		return nil, err
@@ -106,7 +146,7 @@ func CheckClaimSet(claims, tmpl *ClaimSet) error {
 		}
 	}
 	if tmpl.Aud != "" {
-		if claims.Aud != tmpl.Aud {
+		if !claims.HasAudience(tmpl.Aud) {
 			return errcode.Unauthorizedf("wrong audiance")
 		}
 	}
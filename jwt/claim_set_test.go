@@ -0,0 +1,46 @@
+package jwt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeClaimSetAudiences(t *testing.T) {
+	for _, test := range []struct {
+		json string
+		aud  string
+		auds []string
+	}{
+		{json: `{"aud":"app"}`, aud: "app"},
+		{json: `{"aud":["app","other"]}`, aud: "app", auds: []string{
+			"app", "other",
+		}},
+		{json: `{}`},
+	} {
+		c, err := decodeClaimSet(encodeSegmentBytes([]byte(test.json)))
+		if err != nil {
+			t.Errorf("decode %s: %s", test.json, err)
+			continue
+		}
+		if c.Aud != test.aud {
+			t.Errorf(
+				"decode %s, got aud %q, want %q",
+				test.json, c.Aud, test.aud,
+			)
+		}
+		if !reflect.DeepEqual(c.Auds, test.auds) {
+			t.Errorf(
+				"decode %s, got auds %q, want %q",
+				test.json, c.Auds, test.auds,
+			)
+		}
+		if c.Extra != nil {
+			t.Errorf("decode %s, got extra %v", test.json, c.Extra)
+		}
+	}
+
+	c := &ClaimSet{Aud: "app", Auds: []string{"app", "other"}}
+	if !c.HasAudience("other") || c.HasAudience("x") {
+		t.Error("HasAudience on a list is wrong")
+	}
+}
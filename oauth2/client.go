@@ -2,13 +2,20 @@ package oauth2
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
+	"time"
 
 	"golang.org/x/oauth2"
 	"shanhu.io/g/aries"
 	"shanhu.io/g/httputil"
+	"shanhu.io/g/rand"
 	"shanhu.io/g/signer"
 	"shanhu.io/std/errcode"
 )
@@ -32,6 +39,9 @@ type State struct {
 
 	// Whether set cookie after signing in.
 	NoCookie bool `json:",omitempty"`
+
+	// Nonce for OpenID Connect ID tokens.
+	Nonce string `json:",omitempty"`
 }
 
 // Client is an oauth client for oauth2 exchanges.
@@ -39,6 +49,16 @@ type Client struct {
 	config *oauth2.Config
 	states *signer.Sessions
 	method string
+
+	// Optional; discovers the endpoint on demand.
+	endpoint func(ctx context.Context) (*oauth2.Endpoint, error)
+
+	// Key for deriving PKCE code verifiers from states. When nil, PKCE is
+	// not used.
+	pkceKey []byte
+
+	// Whether to send a nonce, which is checked in the ID token.
+	withNonce bool
 }
 
 // NewClient creates a new oauth client for oauth2 exchnages.
@@ -55,22 +75,127 @@ func NewClient(
 // Method returns the method class of this oauth2 client.
 func (c *Client) Method() string { return c.method }
 
-// SignInURL returns the online signin URL for redirection.
-func (c *Client) SignInURL(s *State) string {
+func (c *Client) oauthConfig(ctx context.Context) (*oauth2.Config, error) {
+	if c.endpoint == nil {
+		return c.config, nil
+	}
+	ep, err := c.endpoint(ctx)
+	if err != nil {
+		return nil, errcode.Annotate(err, "discover endpoint")
+	}
+	config := *c.config
+	config.Endpoint = *ep
+	return &config, nil
+}
+
+// pkceVerifier derives the PKCE code verifier from the state, so that the
+// verifier does not need to be saved anywhere, and is never seen outside of
+// the server.
+func (c *Client) pkceVerifier(state string) string {
+	mac := hmac.New(sha256.New, c.pkceKey)
+	mac.Write([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (c *Client) signInURL(
+	ctx context.Context, s *State, opts ...oauth2.AuthCodeOption,
+) (string, error) {
+	config, err := c.oauthConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	if s.Nonce != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", s.Nonce))
+	}
 	state, _, err := c.states.NewJSON(s)
 	if err != nil {
-		panic(err)
+		return "", errcode.Annotate(err, "sign state")
+	}
+	if c.pkceKey != nil {
+		verifier := c.pkceVerifier(state)
+		opts = append(opts, oauth2.S256ChallengeOption(verifier))
+	}
+	return config.AuthCodeURL(state, opts...), nil
+}
+
+// stateTTL is how long a sign in can take, from redirecting to the
+// provider to the callback.
+const stateTTL = time.Hour
+
+// nonceCookie binds the nonce of an OpenID Connect sign in to the browser
+// that starts the sign in.
+const nonceCookie = "oauth2_nonce"
+
+// redirectSignIn redirects the request to the sign in URL. When the client
+// sends a nonce, the nonce is also set in a cookie, which is checked on
+// the callback.
+func (c *Client) redirectSignIn(ac *aries.C, s *State) error {
+	if c.withNonce {
+		cp := *s
+		cp.Nonce = rand.HexBytes(16)
+		s = &cp
+	}
+	u, err := c.signInURL(ac.Context, s)
+	if err != nil {
+		return err
+	}
+	if s.Nonce != "" {
+		ac.WriteCookie(nonceCookie, s.Nonce, time.Now().Add(stateTTL))
+	}
+	ac.Redirect(u)
+	return nil
+}
+
+// checkNonce checks that the nonce in the state is the one set in the
+// cookie of the request, and clears the cookie.
+func checkNonce(c *aries.C, s *State) error {
+	got := c.ReadCookie(nonceCookie)
+	c.ClearCookie(nonceCookie)
+	if s.Nonce == "" || subtle.ConstantTimeCompare(
+		[]byte(got), []byte(s.Nonce),
+	) != 1 {
+		return errcode.Unauthorizedf("nonce cookie mismatch")
 	}
-	return c.config.AuthCodeURL(state)
+	return nil
 }
 
-// OfflineSignInURL returns the offline signin URL for redirection.
+// SignInURL returns the online signin URL for redirection. It returns an
+// empty string if the endpoint of the client cannot be discovered. Use
+// SignInURLErr to get the error. For OpenID Connect clients, the sign in
+// must start from the sign in handler of the module, which binds the nonce
+// to the browser.
+func (c *Client) SignInURL(s *State) string {
+	u, err := c.SignInURLErr(s)
+	if err != nil {
+		log.Printf("sign in URL of %q: %s", c.method, err)
+		return ""
+	}
+	return u
+}
+
+// SignInURLErr is like SignInURL, but returns an error if the endpoint of
+// the client cannot be discovered.
+func (c *Client) SignInURLErr(s *State) (string, error) {
+	return c.signInURL(context.Background(), s)
+}
+
+// OfflineSignInURL returns the offline signin URL for redirection. It
+// returns an empty string if the endpoint of the client cannot be
+// discovered. Use OfflineSignInURLErr to get the error.
 func (c *Client) OfflineSignInURL(s *State) string {
-	state, _, err := c.states.NewJSON(s)
+	u, err := c.OfflineSignInURLErr(s)
 	if err != nil {
-		panic(err)
+		log.Printf("offline sign in URL of %q: %s", c.method, err)
+		return ""
 	}
-	return c.config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	return u
+}
+
+// OfflineSignInURLErr is like OfflineSignInURL, but returns an error if the
+// endpoint of the client cannot be discovered.
+func (c *Client) OfflineSignInURLErr(s *State) (string, error) {
+	ctx := context.Background()
+	return c.signInURL(ctx, s, oauth2.AccessTypeOffline)
 }
 
 // TokenState extracts the oauth2 access token and state from the request.
@@ -85,7 +210,15 @@ func (c *Client) TokenState(ctx *aries.C) (*oauth2.Token, *State, error) {
 		return nil, nil, fmt.Errorf("state invalid")
 	}
 
-	tok, err := c.config.Exchange(ctx.Context, code)
+	config, err := c.oauthConfig(ctx.Context)
+	if err != nil {
+		return nil, nil, err
+	}
+	var opts []oauth2.AuthCodeOption
+	if c.pkceKey != nil {
+		opts = append(opts, oauth2.VerifierOption(c.pkceVerifier(stateStr)))
+	}
+	tok, err := config.Exchange(ctx.Context, code, opts...)
 	if err != nil {
 		return nil, nil, fmt.Errorf("exchange failed: %v", err)
 	}
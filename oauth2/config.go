@@ -4,7 +4,13 @@ import (
 	"time"
 
 	"shanhu.io/g/aries"
+	"shanhu.io/g/audit"
 	"shanhu.io/g/keyreg"
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/signer"
+	"shanhu.io/g/signin/authgate"
+	"shanhu.io/g/signin/mfa"
+	"shanhu.io/g/timeutil"
 	"shanhu.io/std/errcode"
 )
 
@@ -14,10 +20,21 @@ type JSONConfig struct {
 	GitHub       *App
 	Google       *App
 	DigitalOcean *App
+	OIDC         []*OIDCApp `json:",omitempty"`
 	StateKey     string
 	SessionKey   string
 	SignInBypass string
-	PublicKeys   map[string]string
+
+	// Optional key ring for sessions. When set, it replaces SessionKey,
+	// and the keys can be rotated.
+	SessionKeys     *signer.KeyRing `json:",omitempty"`
+	EncryptSessions bool            `json:",omitempty"`
+
+	// Optional table for saving sessions on the server side. It is only
+	// used by TablesConfig, and replaces the other session options.
+	SessionsTable string `json:",omitempty"`
+
+	PublicKeys map[string]string
 }
 
 // Config converts a JSON marshallable config to Config.
@@ -26,10 +43,38 @@ func (c *JSONConfig) Config() *Config {
 		GitHub:       c.GitHub,
 		Google:       c.Google,
 		DigitalOcean: c.DigitalOcean,
+		OIDC:         c.OIDC,
 		StateKey:     []byte(c.StateKey),
 		SessionKey:   []byte(c.SessionKey),
 		Bypass:       c.SignInBypass,
 		KeyRegistry:  keyreg.NewFileKeyRegistry(c.PublicKeys),
+		Sessions:     c.sessionsConfig(),
+	}
+}
+
+// TablesConfig is like Config, but saves the sessions in a table of tables
+// when SessionsTable is set.
+func (c *JSONConfig) TablesConfig(tables *pisces.Tables) *Config {
+	ret := c.Config()
+	if c.SessionsTable != "" {
+		ret.Sessions = &signer.SessionsConfig{
+			Store: tables.NewKV(c.SessionsTable),
+		}
+	}
+	return ret
+}
+
+func (c *JSONConfig) sessionsConfig() *signer.SessionsConfig {
+	if c.SessionKeys == nil && !c.EncryptSessions {
+		return nil
+	}
+	keys := c.SessionKeys
+	if keys == nil {
+		keys = signer.NewKeyRing("0", []byte(c.SessionKey))
+	}
+	return &signer.SessionsConfig{
+		Keys:    keys,
+		Encrypt: c.EncryptSessions,
 	}
 }
 
@@ -54,16 +99,48 @@ type Config struct {
 	Google       *App
 	DigitalOcean *App
 
+	// Generic OpenID Connect providers.
+	OIDC []*OIDCApp
+
 	StateKey        []byte
 	SessionKey      []byte
 	SessionLifeTime time.Duration
 
+	// Optional session store configuration. When set, SessionKey is not
+	// used; sessions are signed with a key ring, optionally encrypted, or
+	// saved on the server side. Its TTL defaults to SessionLifeTime.
+	Sessions *signer.SessionsConfig
+
 	Bypass         string
 	Redirect       string
 	SignInRedirect string
 
 	KeyRegistry keyreg.KeyRegistry
 
+	// Optional revocation list for session tokens.
+	Revocations *authgate.Revocations
+
+	// Optional refresh token store. When set, the module serves a
+	// "refresh" API that exchanges refresh tokens for session tokens.
+	RefreshTokens *authgate.RefreshTokens
+
+	// Optional MFA store. When set, users that are required to use MFA
+	// need to present a second factor before the session cookie is set.
+	MFA *mfa.MFA
+
+	// Optional URL path of the page for the second sign in step. The page
+	// reads the "challenge" query parameter, asks for the code, and calls
+	// the "mfa/verify" API. When empty, a simple built-in page at "mfa" is
+	// used.
+	MFAPage string
+
+	// Optional function that returns the scopes granted to the session
+	// cookies of a user. See authgate.Config for details.
+	CookieScopes func(user string) ([]string, error)
+
+	// Optional audit log that records sign ins.
+	Audit *audit.Log
+
 	// SignInCheck exchanges OAuth2 ID's for user ID.
 	SignInCheck func(c *aries.C, u *UserMeta, purpose string) (string, error)
 
@@ -83,3 +160,18 @@ func MapGitHub(c *aries.C, u *UserMeta, _ string) (string, error) {
 	}
 	return u.ID, nil
 }
+
+// newSessions creates the session store when the config specifies one.
+func newSessions(config *Config) (*signer.Sessions, error) {
+	if config.Sessions == nil {
+		return nil, nil
+	}
+	c := *config.Sessions
+	if c.TTL <= 0 {
+		c.TTL = config.SessionLifeTime
+		if c.TTL <= 0 {
+			c.TTL = timeutil.Week
+		}
+	}
+	return signer.NewSessionsFromConfig(&c)
+}
@@ -0,0 +1,189 @@
+package oauth2
+
+import (
+	"crypto/sha256"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/audit"
+	"shanhu.io/g/signer"
+	"shanhu.io/g/signin/authgate"
+	"shanhu.io/g/signin/mfa"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/std/errcode"
+)
+
+// mfaChallenge is a pending sign in that waits for the second factor.
+type mfaChallenge struct {
+	User   string
+	Dest   string
+	Detail string // Detail of the sign in event.
+}
+
+// mfaStep is the second sign in step, which verifies the second factor
+// before setting up the session cookie.
+type mfaStep struct {
+	mfa        *mfa.MFA
+	challenges *signer.Sessions
+	page       string
+	gate       *authgate.Gate
+	audit      *audit.Log
+}
+
+func newMFAStep(config *Config, gate *authgate.Gate) *mfaStep {
+	key := sha256.Sum256(append([]byte("oauth2 mfa:"), config.StateKey...))
+	return &mfaStep{
+		mfa:        config.MFA,
+		challenges: signer.NewSessions(key[:], 5*time.Minute),
+		page:       config.MFAPage,
+		gate:       gate,
+		audit:      config.Audit,
+	}
+}
+
+var mfaPage = template.Must(template.New("mfa").Parse(`<!DOCTYPE html>
+<html><head><title>Verify sign in</title></head><body>
+<form method="post">
+<p>Enter the code from your authenticator app, or a recovery code.</p>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<input type="hidden" name="challenge" value="{{.Challenge}}">
+<input type="text" name="code" autocomplete="one-time-code" autofocus>
+<input type="submit" value="Verify">
+</form>
+</body></html>
+`))
+
+// checkNoMFA returns an error if the user is required to present a second
+// factor, for the sign in paths that cannot verify one.
+func checkNoMFA(gate *authgate.Gate, user string) error {
+	required, err := gate.MFARequired(user)
+	if err != nil {
+		return errcode.Annotate(err, "check MFA")
+	}
+	if required {
+		return errcode.Unauthorizedf("MFA required")
+	}
+	return nil
+}
+
+// mfaPageURL returns the URL of the second sign in step page. The
+// built-in page is served next to the callback routes.
+func (s *mfaStep) pageURL(c *aries.C) string {
+	if s.page != "" {
+		return s.page
+	}
+	return path.Join(path.Dir(path.Dir(c.Req.URL.Path)), "mfa")
+}
+
+// start checks if the user needs to present a second factor. If so, it
+// redirects to the second step page and returns true. detail is the detail
+// of the sign in event, which is recorded after the second factor is
+// verified.
+func (s *mfaStep) start(
+	c *aries.C, user, detail string, state *State,
+) (bool, error) {
+	status, err := s.mfa.Status(user)
+	if err != nil {
+		return false, errcode.Annotate(err, "check MFA")
+	}
+	if !status.Required {
+		return false, nil
+	}
+	if !status.Enrolled {
+		return false, errcode.Unauthorizedf("MFA required but not enrolled")
+	}
+
+	challenge, _, err := s.challenges.NewJSON(&mfaChallenge{
+		User:   user,
+		Dest:   state.Dest,
+		Detail: detail,
+	})
+	if err != nil {
+		return false, errcode.Annotate(err, "sign challenge")
+	}
+	q := url.Values{"challenge": {challenge}}
+	c.Redirect(s.pageURL(c) + "?" + q.Encode())
+	return true, nil
+}
+
+// finish verifies the code for a pending sign in, and sets up the session
+// cookie on success. It returns the redirect destination. Both the
+// verification and the completed sign in are audited.
+func (s *mfaStep) finish(c *aries.C, challenge, code string) (
+	string, error,
+) {
+	ch := new(mfaChallenge)
+	if !s.challenges.CheckJSON(challenge, ch) || ch.User == "" {
+		err := errcode.Unauthorizedf("invalid or expired challenge")
+		s.audit.Record(c, &audit.Event{Action: audit.MFAVerify}, err)
+		return "", err
+	}
+	err := s.mfa.Verify(ch.User, code)
+	s.audit.Record(c, &audit.Event{
+		Actor:  ch.User,
+		Action: audit.MFAVerify,
+		Target: ch.User,
+	}, err)
+	if err != nil {
+		return "", err
+	}
+	err = s.gate.SetupMFACookieErr(c, ch.User)
+	s.audit.Record(c, &audit.Event{
+		Actor:  ch.User,
+		Action: audit.SignIn,
+		Target: ch.User,
+		Detail: ch.Detail,
+	}, err)
+	if err != nil {
+		return "", err
+	}
+	return ch.Dest, nil
+}
+
+func (s *mfaStep) verify(c *aries.C, req *signinapi.MFAVerifyRequest) (
+	*signinapi.MFAVerifyResponse, error,
+) {
+	dest, err := s.finish(c, req.Challenge, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return &signinapi.MFAVerifyResponse{Redirect: dest}, nil
+}
+
+func (s *mfaStep) servePage(c *aries.C) error {
+	dat := struct {
+		Challenge string
+		Error     string
+	}{}
+	switch c.Req.Method {
+	case http.MethodGet:
+		dat.Challenge = c.Req.URL.Query().Get("challenge")
+	case http.MethodPost:
+		if err := c.Req.ParseForm(); err != nil {
+			return errcode.InvalidArgf("parse form: %s", err)
+		}
+		dat.Challenge = c.Req.PostForm.Get("challenge")
+		code := c.Req.PostForm.Get("code")
+		dest, err := s.finish(c, dat.Challenge, code)
+		if err == nil {
+			c.Redirect(dest)
+			return nil
+		}
+		if !errcode.IsUnauthorized(err) {
+			return err
+		}
+		dat.Error = err.Error()
+		c.Resp.WriteHeader(http.StatusUnauthorized)
+	default:
+		return aries.NotFound
+	}
+	if err := mfaPage.Execute(c.Resp, &dat); err != nil {
+		log.Printf("render MFA page: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package oauth2
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"shanhu.io/g/audit"
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/signin/authgate"
+	"shanhu.io/g/signin/mfa"
+)
+
+func TestMFASignIn(t *testing.T) {
+	p, idp := newFakeIDP(t)
+	defer idp.Close()
+
+	now := time.Now()
+	mfaStore := mfa.New(pisces.NewMemKV(), &mfa.Config{
+		Now: func() time.Time { return now },
+	})
+	site := newOIDCTestSite(p, mfaStore)
+	defer site.Close()
+
+	enroll, err := mfaStore.StartEnroll("alice", "")
+	if err != nil {
+		t.Fatal("start enroll: ", err)
+	}
+	secret, err := mfa.DecodeSecret(enroll.Secret)
+	if err != nil {
+		t.Fatal("decode secret: ", err)
+	}
+	if _, err := mfaStore.FinishEnroll(
+		"alice", mfa.TOTPCode(secret, now),
+	); err != nil {
+		t.Fatal("finish enroll: ", err)
+	}
+
+	resp := site.signIn(t)
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("callback got status %d", resp.StatusCode)
+	}
+	if sessionCookie(resp) != nil {
+		t.Fatal("session cookie set before the second factor")
+	}
+	page, err := resp.Location()
+	if err != nil {
+		t.Fatal("read redirect location: ", err)
+	}
+	if page.Path != "/mfa" {
+		t.Fatalf("redirected to %q, want the MFA page", page.Path)
+	}
+	challenge := page.Query().Get("challenge")
+
+	verify := func(code string) *http.Response {
+		t.Helper()
+		resp, err := site.client.PostForm(site.URL+"/mfa", url.Values{
+			"challenge": {challenge},
+			"code":      {code},
+		})
+		if err != nil {
+			t.Fatal("verify: ", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := verify("000000"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong code got status %d", resp.StatusCode)
+	}
+
+	// Use the code of the next time step, as the current one is used.
+	now = now.Add(30 * time.Second)
+	resp = verify(mfa.TOTPCode(secret, now))
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("verify got status %d", resp.StatusCode)
+	}
+	session := sessionCookie(resp)
+	if session == nil {
+		t.Fatal("session cookie not set after the second factor")
+	}
+	gate := site.module.gate
+	info, err := gate.CheckToken(session.Value, authgate.TokenCookie)
+	if err != nil {
+		t.Fatal("check session: ", err)
+	}
+	if !info.Valid {
+		t.Error("session is not valid after the second factor")
+	}
+
+	// Sessions and tokens without the second factor are not valid.
+	tok, err := site.module.TokenErr("alice", time.Hour)
+	if err != nil {
+		t.Fatal("issue token: ", err)
+	}
+	info, err = gate.CheckToken(tok.Token, authgate.TokenBearer)
+	if err != nil {
+		t.Fatal("check token: ", err)
+	}
+	if info.Valid {
+		t.Error("token without the second factor is valid")
+	}
+
+	events, err := site.audit.Query(&audit.Query{Target: "alice"})
+	if err != nil {
+		t.Fatal("query audit log: ", err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Action+":"+e.Result)
+	}
+	want := []string{"mfa.verify:error", "mfa.verify:ok", "signin:ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got audit events %q, want %q", got, want)
+	}
+}
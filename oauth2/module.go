@@ -7,9 +7,11 @@ import (
 	"time"
 
 	"shanhu.io/g/aries"
+	"shanhu.io/g/audit"
 	"shanhu.io/g/signer"
 	"shanhu.io/g/signin"
 	"shanhu.io/g/signin/authgate"
+	"shanhu.io/g/signin/signinapi"
 	"shanhu.io/std/errcode"
 )
 
@@ -19,6 +21,7 @@ type Module struct {
 	gate      *authgate.Gate
 	providers []provider
 	pubKey    *authgate.LegacyExchange
+	mfaStep   *mfaStep
 
 	redirect       string
 	signInRedirect string
@@ -28,8 +31,19 @@ type Module struct {
 	router *aries.Router
 }
 
-// NewModule creates a new oauth module with the given config.
+// NewModule creates a new oauth module with the given config. It panics
+// when the config is invalid. Use NewModuleErr to get the error.
 func NewModule(config *Config) *Module {
+	m, err := NewModuleErr(config)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewModuleErr creates a new oauth module with the given config. It returns
+// an error when the config is invalid.
+func NewModuleErr(config *Config) (*Module, error) {
 	redirect := config.Redirect
 	if redirect == "" {
 		redirect = "/"
@@ -39,11 +53,23 @@ func NewModule(config *Config) *Module {
 		signInRedirect = redirect
 	}
 
-	gate := authgate.New(&authgate.Config{
+	sessions, err := newSessions(config)
+	if err != nil {
+		return nil, errcode.Annotate(err, "create sessions")
+	}
+	gateConfig := &authgate.Config{
+		Sessions:        sessions,
 		SessionKey:      config.SessionKey,
 		SessionLifeTime: config.SessionLifeTime,
 		Check:           config.Check,
-	})
+		Revocations:     config.Revocations,
+		RefreshTokens:   config.RefreshTokens,
+		CookieScopes:    config.CookieScopes,
+	}
+	if config.MFA != nil {
+		gateConfig.MFARequired = config.MFA.Required
+	}
+	gate := authgate.New(gateConfig)
 
 	ret := &Module{
 		config:         config,
@@ -53,14 +79,17 @@ func NewModule(config *Config) *Module {
 		clients:        make(map[string]*Client),
 	}
 
+	if config.MFA != nil {
+		ret.mfaStep = newMFAStep(config, gate)
+	}
+
 	if config.KeyRegistry != nil {
 		ret.pubKey = authgate.NewLegacyExchange(
 			gate, config.KeyRegistry,
 		)
 	}
 
-	const ttl time.Duration = time.Hour
-	states := signer.NewSessions(config.StateKey, ttl)
+	states := signer.NewSessions(config.StateKey, stateTTL)
 
 	addProvider := func(p provider) {
 		ret.providers = append(ret.providers, p)
@@ -74,10 +103,13 @@ func NewModule(config *Config) *Module {
 	if config.DigitalOcean != nil {
 		addProvider(newDigitalOcean(config.DigitalOcean, states))
 	}
+	for _, app := range config.OIDC {
+		addProvider(newOIDC(app, states, config.StateKey))
+	}
 
 	ret.router = ret.makeRouter()
 
-	return ret
+	return ret, nil
 }
 
 // Serve serves the routes for signing in and callbacks.
@@ -108,7 +140,9 @@ func (m *Module) signOut(c *aries.C) error {
 			return err
 		}
 	}
-	authgate.ClearCookie(c)
+	if err := m.gate.ClearSession(c); err != nil {
+		return err
+	}
 	c.Redirect(m.redirect)
 	return nil
 }
@@ -118,13 +152,32 @@ func (m *Module) makeRouter() *aries.Router {
 	r.Get("signout", m.signOut)
 	if bypass := m.config.Bypass; bypass != "" {
 		r.Get("signin-bypass", func(c *aries.C) error {
-			m.gate.SetupCookie(c, bypass)
+			if err := checkNoMFA(m.gate, bypass); err != nil {
+				return err
+			}
+			if err := m.gate.SetupCookieErr(c, bypass); err != nil {
+				return err
+			}
 			c.Redirect(m.signInRedirect)
 			return nil
 		})
 	}
-	if m.pubKey != nil {
-		r.Call("pubkey/signin", m.pubKey.Exchange)
+	if x := m.pubKey; x != nil {
+		r.Call("pubkey/signin", func(
+			c *aries.C, req *signinapi.Request,
+		) (*signinapi.Creds, error) {
+			if err := checkNoMFA(m.gate, req.User); err != nil {
+				return nil, err
+			}
+			return x.Exchange(c, req)
+		})
+	}
+	if m.config.RefreshTokens != nil {
+		r.Call("refresh", m.gate.Refresh)
+	}
+	if m.mfaStep != nil {
+		r.File("mfa", m.mfaStep.servePage)
+		r.Call("mfa/verify", m.mfaStep.verify)
 	}
 	for _, p := range m.providers {
 		m.addProvider(r, p)
@@ -146,32 +199,87 @@ func (m *Module) signInCheck(
 
 func (m *Module) signIn(c *aries.C, user *UserMeta, state *State) error {
 	id, err := m.signInCheck(c, user, state.Purpose)
+	event := &audit.Event{
+		Actor:  id,
+		Action: audit.SignIn,
+		Target: id,
+		Detail: user.Method + ":" + user.ID,
+	}
 	if err != nil {
+		m.config.Audit.Record(c, event, err)
 		return err
 	}
 	if id == "" {
+		denied := errcode.Unauthorizedf("sign in denied")
+		m.config.Audit.Record(c, event, denied)
+		return nil
+	}
+	if state.NoCookie {
+		if err := checkNoMFA(m.gate, id); err != nil {
+			m.config.Audit.Record(c, event, err)
+			return err
+		}
+		m.config.Audit.Record(c, event, nil)
+		c.Redirect(state.Dest)
 		return nil
 	}
-	if !state.NoCookie {
-		m.gate.SetupCookie(c, id)
+	if m.mfaStep != nil {
+		pending, err := m.mfaStep.start(c, id, event.Detail, state)
+		if err != nil {
+			m.config.Audit.Record(c, event, err)
+			return err
+		}
+		if pending {
+			return nil // Recorded after the second factor is verified.
+		}
+	}
+	err = m.gate.SetupCookieErr(c, id)
+	m.config.Audit.Record(c, event, err)
+	if err != nil {
+		return err
 	}
 	c.Redirect(state.Dest)
 	return nil
 }
 
-// Token returns a new session token for user that expires in ttl.
+// Token returns a new session token for user that expires in ttl. It
+// returns nil when the token cannot be issued.
 func (m *Module) Token(user string, ttl time.Duration) *signin.Token {
 	return m.gate.Token(user, ttl)
 }
 
+// TokenErr is like Token, but returns an error when the token cannot be
+// issued.
+func (m *Module) TokenErr(user string, ttl time.Duration) (
+	*signin.Token, error,
+) {
+	return m.gate.TokenErr(user, ttl)
+}
+
+// RevokeUser revokes all sessions and refresh tokens of the user.
+func (m *Module) RevokeUser(user string) error {
+	return m.gate.RevokeUser(user)
+}
+
+// AdminAPI returns the API router for revoking sessions. It should only be
+// mounted where admins can access.
+func (m *Module) AdminAPI() *aries.Router { return m.gate.AdminAPI() }
+
 // Setup sets up the credentials for the request.
 func (m *Module) Setup(c *aries.C) error { return m.gate.Setup(c) }
 
-// SetupCookie sets up the session gate's cookie.
+// SetupCookie sets up the session gate's cookie. The session is not valid
+// if the user is required to present a second factor.
 func (m *Module) SetupCookie(c *aries.C, user string) {
 	m.gate.SetupCookie(c, user)
 }
 
+// SetupCookieErr is like SetupCookie, but returns an error when the session
+// cannot be created.
+func (m *Module) SetupCookieErr(c *aries.C, user string) error {
+	return m.gate.SetupCookieErr(c, user)
+}
+
 // SignIn redirects the incoming request to a particular client's sign-in
 // URL. If the client is not found, it redirects to default redirect page.
 func (m *Module) SignIn(c *aries.C, method string, s *State) error {
@@ -180,8 +288,7 @@ func (m *Module) SignIn(c *aries.C, method string, s *State) error {
 		c.Redirect(m.redirect)
 		return nil
 	}
-	c.Redirect(client.SignInURL(s))
-	return nil
+	return client.redirectSignIn(c, s)
 }
 
 func (m *Module) callbackHandler(method string, x metaExchange) aries.Func {
@@ -189,6 +296,10 @@ func (m *Module) callbackHandler(method string, x metaExchange) aries.Func {
 		user, state, err := x.callback(c)
 		if err != nil {
 			log.Printf("%s callback: %s", method, err)
+			m.config.Audit.Record(c, &audit.Event{
+				Action: audit.SignIn,
+				Detail: method,
+			}, err)
 			return errcode.Internalf("%s callback failed", method)
 		}
 		if user == nil {
@@ -0,0 +1,109 @@
+package oauth2
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/signer"
+	"shanhu.io/g/signin/authgate"
+)
+
+func TestModuleSessions(t *testing.T) {
+	kv := pisces.NewMemKV()
+	for _, test := range []struct {
+		name     string
+		sessions *signer.SessionsConfig
+		prefix   string
+	}{
+		{name: "key", sessions: nil},
+		{
+			name: "ring",
+			sessions: &signer.SessionsConfig{
+				Keys:    signer.NewKeyRing("k1", []byte("key")),
+				Encrypt: true,
+			},
+			prefix: "k1.",
+		},
+		{name: "store", sessions: &signer.SessionsConfig{Store: kv}},
+	} {
+		m, err := NewModuleErr(&Config{
+			StateKey:   []byte("state-key"),
+			SessionKey: []byte("session-key"),
+			Sessions:   test.sessions,
+		})
+		if err != nil {
+			t.Fatalf("%s: create module: %s", test.name, err)
+		}
+		tok, err := m.TokenErr("h8liu", time.Hour)
+		if err != nil {
+			t.Fatal("issue token: ", err)
+		}
+		if !strings.HasPrefix(tok.Token, test.prefix) {
+			t.Errorf("%s: got token %q", test.name, tok.Token)
+		}
+		info, err := m.gate.CheckToken(tok.Token, authgate.TokenBearer)
+		if err != nil {
+			t.Fatalf("%s: check token: %s", test.name, err)
+		}
+		if !info.Valid || info.User != "h8liu" {
+			t.Errorf("%s: got creds info %+v", test.name, info)
+		}
+	}
+
+	if n, err := kv.Count(); err != nil {
+		t.Fatal("count sessions: ", err)
+	} else if n != 1 {
+		t.Errorf("got %d sessions saved, want 1", n)
+	}
+}
+
+func TestModuleInvalidSessions(t *testing.T) {
+	_, err := NewModuleErr(&Config{
+		StateKey: []byte("state-key"),
+		Sessions: &signer.SessionsConfig{
+			Keys: signer.NewKeyRing("k.1", []byte("key")),
+		},
+	})
+	if err == nil {
+		t.Error("created module with an invalid key ring")
+	}
+}
+
+func TestModuleSignOut(t *testing.T) {
+	tables := pisces.NewMemTables()
+	config := &JSONConfig{
+		StateKey:      "state-key",
+		SessionKey:    "session-key",
+		SessionsTable: "sessions",
+	}
+	m := NewModule(config.TablesConfig(tables))
+
+	w := httptest.NewRecorder()
+	c := aries.NewContext(w, httptest.NewRequest("GET", "/", nil))
+	if err := m.SetupCookieErr(c, "h8liu"); err != nil {
+		t.Fatal("setup cookie: ", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	session := cookies[0].Value
+
+	req := httptest.NewRequest("GET", "/signout", nil)
+	req.AddCookie(cookies[0])
+	c = aries.NewContext(httptest.NewRecorder(), req)
+	if err := m.signOut(c); err != nil {
+		t.Fatal("sign out: ", err)
+	}
+	info, err := m.gate.CheckToken(session, authgate.TokenCookie)
+	if err != nil {
+		t.Fatal("check session: ", err)
+	}
+	if info.Valid {
+		t.Error("session is valid after signing out")
+	}
+}
@@ -0,0 +1,236 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"shanhu.io/g/aries"
+	"shanhu.io/g/httputil"
+	"shanhu.io/g/identity"
+	"shanhu.io/g/jwt"
+	"shanhu.io/g/signer"
+	"shanhu.io/g/strutil"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// OIDCApp stores the configuration of a generic OpenID Connect provider,
+// such as GitLab, or a company's identity provider.
+type OIDCApp struct {
+	// Method is the name of the sign in method. It is used in the URL
+	// routes and as UserMeta.Method, like "gitlab".
+	Method string
+
+	// Issuer is the issuer URL of the provider. The discovery document is
+	// fetched from Issuer + "/.well-known/openid-configuration".
+	Issuer string
+
+	ID          string
+	Secret      string
+	RedirectURL string `json:",omitempty"`
+
+	// Scopes to request besides "openid", "email" and "profile".
+	Scopes []string `json:",omitempty"`
+
+	// Optional HTTP client for discovery and key fetching.
+	Client *http.Client `json:"-"`
+
+	// Optional function for reading the current time.
+	Now func() time.Time `json:"-"`
+}
+
+// FetchOpenIDConfig fetches the discovery document of an OpenID Connect
+// issuer.
+func FetchOpenIDConfig(ctx context.Context, c *http.Client, issuer string) (
+	*identity.OpenIDConfig, error,
+) {
+	u := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errcode.Annotate(err, "make request")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, httputil.RespError(resp)
+	}
+
+	doc := new(identity.OpenIDConfig)
+	if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
+		return nil, errcode.Annotate(err, "decode discovery document")
+	}
+	if doc.Issuer != issuer {
+		return nil, errcode.InvalidArgf(
+			"issuer is %q in discovery document, want %q", doc.Issuer, issuer,
+		)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return nil, errcode.InvalidArgf("authorization code flow missing")
+	}
+	if doc.JWKSURI == "" {
+		return nil, errcode.InvalidArgf("JWKS URI missing")
+	}
+	return doc, nil
+}
+
+type oidc struct {
+	app        *OIDCApp
+	c          *Client
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu   sync.Mutex
+	doc  *identity.OpenIDConfig
+	card *identity.JWKSCard
+}
+
+func newOIDC(app *OIDCApp, s *signer.Sessions, stateKey []byte) *oidc {
+	scopeSet := map[string]bool{
+		"openid":  true,
+		"email":   true,
+		"profile": true,
+	}
+	for _, scope := range app.Scopes {
+		scopeSet[scope] = true
+	}
+	httpClient := app.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	ret := &oidc{
+		app:        app,
+		httpClient: httpClient,
+		now:        timeutil.NowFunc(app.Now),
+	}
+	c := NewClient(
+		&oauth2.Config{
+			ClientID:     app.ID,
+			ClientSecret: app.Secret,
+			Scopes:       strutil.SortedList(scopeSet),
+			RedirectURL:  app.RedirectURL,
+		}, s, app.Method,
+	)
+	c.endpoint = ret.endpoint
+	pkceKey := sha256.Sum256(append([]byte("oauth2 pkce:"), stateKey...))
+	c.pkceKey = pkceKey[:]
+	c.withNonce = true
+	ret.c = c
+	return ret
+}
+
+func (p *oidc) client() *Client { return p.c }
+
+// discover fetches the discovery document if it is not fetched yet. A
+// failed fetch is retried next time.
+func (p *oidc) discover(ctx context.Context) (
+	*identity.OpenIDConfig, *identity.JWKSCard, error,
+) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.doc != nil {
+		return p.doc, p.card, nil
+	}
+	doc, err := FetchOpenIDConfig(ctx, p.httpClient, p.app.Issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.doc = doc
+	p.card = identity.NewJWKSCard(&identity.JWKSCardConfig{
+		URL:    doc.JWKSURI,
+		Client: p.httpClient,
+		Now:    p.now,
+	})
+	return p.doc, p.card, nil
+}
+
+func (p *oidc) endpoint(ctx context.Context) (*oauth2.Endpoint, error) {
+	doc, _, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Endpoint{
+		AuthURL:  doc.AuthorizationEndpoint,
+		TokenURL: doc.TokenEndpoint,
+	}, nil
+}
+
+func (p *oidc) verifyIDToken(ctx context.Context, raw, nonce string) (
+	*jwt.ClaimSet, error,
+) {
+	doc, card, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v := identity.NewJWTVerifier(card)
+	tok, err := jwt.DecodeAndVerify(ctx, raw, v, p.now())
+	if err != nil {
+		return nil, err
+	}
+	claims := tok.ClaimSet
+	if err := jwt.CheckClaimSet(claims, &jwt.ClaimSet{
+		Iss: doc.Issuer,
+		Aud: p.app.ID,
+	}); err != nil {
+		return nil, err
+	}
+	if got, _ := claims.ExtraString("nonce"); got != nonce || nonce == "" {
+		return nil, errcode.Unauthorizedf("nonce mismatch")
+	}
+	if claims.Sub == "" {
+		return nil, errcode.Unauthorizedf("subject missing")
+	}
+	return claims, nil
+}
+
+// oidcUserMeta maps the standard claims of an ID token into user meta.
+func oidcUserMeta(method string, claims *jwt.ClaimSet) *UserMeta {
+	name, _ := claims.ExtraString("preferred_username")
+	if name == "" {
+		name, _ = claims.ExtraString("name")
+	}
+	if name == "" {
+		name = "no-name"
+	}
+	email, _ := claims.ExtraString("email")
+	if verified, ok := claims.Extra["email_verified"].(bool); ok && !verified {
+		email = ""
+	}
+	return &UserMeta{
+		Method: method,
+		ID:     claims.Sub,
+		Name:   name,
+		Email:  email,
+	}
+}
+
+func (p *oidc) callback(c *aries.C) (*UserMeta, *State, error) {
+	tok, state, err := p.c.TokenState(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkNonce(c, state); err != nil {
+		return nil, nil, err
+	}
+	raw, ok := tok.Extra("id_token").(string)
+	if !ok || raw == "" {
+		return nil, nil, fmt.Errorf("ID token missing")
+	}
+	claims, err := p.verifyIDToken(c.Context, raw, state.Nonce)
+	if err != nil {
+		return nil, nil, errcode.Annotate(err, "verify ID token")
+	}
+	return oidcUserMeta(p.app.Method, claims), state, nil
+}
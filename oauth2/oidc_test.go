@@ -0,0 +1,348 @@
+package oauth2
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/audit"
+	"shanhu.io/g/identity"
+	"shanhu.io/g/jwt"
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/rand"
+	"shanhu.io/g/signer"
+	"shanhu.io/g/signin/mfa"
+	"shanhu.io/std/errcode"
+)
+
+type fakeIDPAuth struct {
+	nonce     string
+	challenge string
+}
+
+// fakeIDP is a minimal OpenID Connect identity provider that signs in
+// everyone as alice.
+type fakeIDP struct {
+	url   string
+	core  identity.Core
+	codes map[string]*fakeIDPAuth
+
+	nonce string // Overwrites the nonce when not empty.
+}
+
+func (p *fakeIDP) router() *aries.Router {
+	r := aries.NewRouter()
+	r.DirService(".well-known", identity.NewDiscoveryService(
+		p.core, &identity.DiscoveryConfig{
+			Issuer:                p.url,
+			AuthorizationEndpoint: p.url + "/authorize",
+			TokenEndpoint:         p.url + "/token",
+		},
+	))
+	r.Get("authorize", p.authorize)
+	r.Post("token", p.token)
+	return r
+}
+
+func (p *fakeIDP) authorize(c *aries.C) error {
+	q := c.Req.URL.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		return errcode.InvalidArgf("PKCE missing")
+	}
+	code := rand.HexBytes(8)
+	p.codes[code] = &fakeIDPAuth{
+		nonce:     q.Get("nonce"),
+		challenge: q.Get("code_challenge"),
+	}
+
+	u, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		return errcode.InvalidArgf("invalid redirect: %s", err)
+	}
+	u.RawQuery = url.Values{
+		"code":  {code},
+		"state": {q.Get("state")},
+	}.Encode()
+	c.Redirect(u.String())
+	return nil
+}
+
+func (p *fakeIDP) token(c *aries.C) error {
+	if err := c.Req.ParseForm(); err != nil {
+		return errcode.InvalidArgf("parse form: %s", err)
+	}
+	auth, ok := p.codes[c.Req.Form.Get("code")]
+	if !ok {
+		return errcode.Unauthorizedf("invalid code")
+	}
+	delete(p.codes, c.Req.Form.Get("code"))
+
+	h := sha256.Sum256([]byte(c.Req.Form.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(h[:]) != auth.challenge {
+		return errcode.Unauthorizedf("PKCE verify failed")
+	}
+
+	nonce := auth.nonce
+	if p.nonce != "" {
+		nonce = p.nonce
+	}
+	now := time.Now()
+	claims := &jwt.ClaimSet{
+		Iss: p.url,
+		Sub: "u1234",
+		Iat: now.Unix(),
+		Exp: now.Add(time.Hour).Unix(),
+		Extra: map[string]any{
+			"aud":                []string{"app", "other"},
+			"nonce":              nonce,
+			"email":              "alice@example.com",
+			"email_verified":     true,
+			"preferred_username": "alice",
+		},
+	}
+	signer := identity.NewJWTSigner(p.core)
+	idToken, err := jwt.EncodeAndSign(c.Context, claims, signer)
+	if err != nil {
+		return errcode.Annotate(err, "sign ID token")
+	}
+	return aries.ReplyJSON(c, map[string]any{
+		"access_token": rand.HexBytes(8),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func newFakeIDP(t *testing.T) (*fakeIDP, *httptest.Server) {
+	t.Helper()
+	core := identity.NewMemCore(nil)
+	config := identity.SingleKeyCoreConfig(time.Now().Add(time.Hour))
+	if _, err := core.Init(config); err != nil {
+		t.Fatal("init identity: ", err)
+	}
+	p := &fakeIDP{
+		core:  core,
+		codes: make(map[string]*fakeIDPAuth),
+	}
+	var h http.Handler
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			h.ServeHTTP(w, req)
+		},
+	))
+	p.url = s.URL
+	h = aries.Serve(p.router())
+	return p, s
+}
+
+type oidcTestSite struct {
+	*httptest.Server
+	meta   *UserMeta
+	client *http.Client
+	audit  *audit.Log
+	module *Module
+}
+
+func newOIDCTestSite(p *fakeIDP, mfaStore *mfa.MFA) *oidcTestSite {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		panic(err)
+	}
+	site := &oidcTestSite{
+		client: &http.Client{
+			Jar: jar,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		audit: audit.New(pisces.NewOrderedMemKV(), nil),
+	}
+
+	var m *Module
+	site.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			aries.Serve(m).ServeHTTP(w, req)
+		},
+	))
+	m = NewModule(&Config{
+		OIDC: []*OIDCApp{{
+			Method:      "idp",
+			Issuer:      p.url,
+			ID:          "app",
+			Secret:      "secret",
+			RedirectURL: site.URL + "/idp/callback",
+		}},
+		StateKey:   []byte("state-key"),
+		SessionKey: []byte("session-key"),
+		MFA:        mfaStore,
+		Audit:      site.audit,
+		SignInCheck: func(_ *aries.C, u *UserMeta, _ string) (
+			string, error,
+		) {
+			site.meta = u
+			return u.Name, nil
+		},
+	})
+	site.module = m
+	return site
+}
+
+// signIn follows the redirects of sign in and authorize, and returns the
+// response of the callback.
+func (s *oidcTestSite) signIn(t *testing.T) *http.Response {
+	t.Helper()
+
+	u := s.URL + "/idp/signin"
+	for i := 0; ; i++ {
+		resp, err := s.client.Get(u)
+		if err != nil {
+			t.Fatalf("get %q: %s", u, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound || i == 2 {
+			return resp
+		}
+		loc, err := resp.Location()
+		if err != nil {
+			t.Fatal("read redirect location: ", err)
+		}
+		u = loc.String()
+	}
+}
+
+// sessionCookie returns the session cookie set by the response, if any.
+func sessionCookie(resp *http.Response) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == "session" {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestOIDC(t *testing.T) {
+	p, idp := newFakeIDP(t)
+	defer idp.Close()
+	site := newOIDCTestSite(p, nil)
+	defer site.Close()
+
+	resp := site.signIn(t)
+	if site.meta == nil {
+		t.Fatalf("sign in failed with status %d", resp.StatusCode)
+	}
+	want := &UserMeta{
+		Method: "idp",
+		ID:     "u1234",
+		Name:   "alice",
+		Email:  "alice@example.com",
+	}
+	if *site.meta != *want {
+		t.Errorf("got user meta %+v, want %+v", site.meta, want)
+	}
+	if sessionCookie(resp) == nil {
+		t.Error("session cookie not set")
+	}
+
+	events, err := site.audit.Query(&audit.Query{Target: "alice"})
+	if err != nil {
+		t.Fatal("query audit log: ", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d audit events, want 1", len(events))
+	}
+	if e := events[0]; e.Result != audit.ResultOK || e.Detail != "idp:u1234" {
+		t.Errorf("got audit event %+v", e)
+	}
+}
+
+func TestOIDCBadNonce(t *testing.T) {
+	p, idp := newFakeIDP(t)
+	defer idp.Close()
+	site := newOIDCTestSite(p, nil)
+	defer site.Close()
+
+	p.nonce = "replayed"
+	resp := site.signIn(t)
+	if site.meta != nil {
+		t.Errorf("signed in as %+v with a wrong nonce", site.meta)
+	}
+	if resp.StatusCode == http.StatusFound {
+		t.Error("want sign in to fail")
+	}
+}
+
+func TestOIDCNonceCookie(t *testing.T) {
+	p, idp := newFakeIDP(t)
+	defer idp.Close()
+	site := newOIDCTestSite(p, nil)
+	defer site.Close()
+
+	// The sign in is started by another browser, which has the nonce
+	// cookie, and the victim's browser is lured to the provider.
+	resp, err := site.client.Get(site.URL + "/idp/signin")
+	if err != nil {
+		t.Fatal("start sign in: ", err)
+	}
+	resp.Body.Close()
+	loc, err := resp.Location()
+	if err != nil {
+		t.Fatal("read redirect location: ", err)
+	}
+	victim := &http.Client{CheckRedirect: site.client.CheckRedirect}
+	u := loc.String()
+	for i := 0; i < 2; i++ {
+		resp, err = victim.Get(u)
+		if err != nil {
+			t.Fatalf("get %q: %s", u, err)
+		}
+		resp.Body.Close()
+		if i == 0 {
+			if loc, err = resp.Location(); err != nil {
+				t.Fatal("read redirect location: ", err)
+			}
+			u = loc.String()
+		}
+	}
+	if site.meta != nil {
+		t.Errorf("signed in as %+v without the nonce cookie", site.meta)
+	}
+}
+
+func TestOIDCUserMetaUnverifiedEmail(t *testing.T) {
+	claims := &jwt.ClaimSet{
+		Sub: "u1",
+		Extra: map[string]any{
+			"name":           "Alice",
+			"email":          "alice@example.com",
+			"email_verified": false,
+		},
+	}
+	meta := oidcUserMeta("idp", claims)
+	if meta.Name != "Alice" {
+		t.Errorf("got name %q, want Alice", meta.Name)
+	}
+	if meta.Email != "" {
+		t.Errorf("got unverified email %q", meta.Email)
+	}
+}
+
+func TestOIDCSignInURLDiscoveryError(t *testing.T) {
+	idp := httptest.NewServer(http.NotFoundHandler())
+	defer idp.Close()
+
+	app := &OIDCApp{Method: "idp", Issuer: idp.URL, ID: "app"}
+	states := signer.NewSessions([]byte("state-key"), time.Hour)
+	c := newOIDC(app, states, []byte("state-key")).client()
+	if _, err := c.SignInURLErr(&State{Dest: "/"}); err == nil {
+		t.Error("want error when discovery fails")
+	}
+	if u := c.SignInURL(&State{Dest: "/"}); u != "" {
+		t.Errorf("got sign in URL %q when discovery fails", u)
+	}
+}
@@ -26,6 +26,5 @@ func (h *signInHandler) Serve(c *aries.C) error {
 		redirect = parsed
 	}
 	state := &State{Dest: redirect}
-	c.Redirect(h.client.SignInURL(state))
-	return nil
+	return h.client.redirectSignIn(c, state)
 }
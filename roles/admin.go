@@ -0,0 +1,159 @@
+package roles
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/identity"
+	"shanhu.io/g/roles/rolesapi"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// AdminConfig is the configuration for creating an admin service.
+type AdminConfig struct {
+	// Optional notifier that delivers pass codes to the roles' contacts.
+	// When it is nil or the role has no contact, pass codes are returned
+	// to the admin.
+	Notifier Notifier
+
+	Now func() time.Time
+}
+
+// Admin provides the API for admins to manage roles.
+type Admin struct {
+	roles    *Roles
+	notifier Notifier
+	now      func() time.Time
+}
+
+// NewAdmin creates an admin service for the roles.
+func NewAdmin(r *Roles, config *AdminConfig) *Admin {
+	if config == nil {
+		config = new(AdminConfig)
+	}
+	return &Admin{
+		roles:    r,
+		notifier: config.Notifier,
+		now:      timeutil.NowFunc(config.Now),
+	}
+}
+
+func checkName(name string) error {
+	if name == "" {
+		return errcode.InvalidArgf("role name missing")
+	}
+	if hasControl(name) {
+		return errcode.InvalidArgf("role name %q has control characters", name)
+	}
+	return nil
+}
+
+func (a *Admin) apiCreate(c *aries.C, req *rolesapi.CreateRequest) error {
+	if err := checkName(req.Name); err != nil {
+		return err
+	}
+	roles := a.roles.WithActor(c)
+	if err := roles.New(req.Name, a.now()); err != nil {
+		return err
+	}
+	if req.Contact == "" {
+		return nil
+	}
+	if err := roles.SetContact(req.Name, req.Contact); err != nil {
+		// Do not leave a role that is created without its contact.
+		if err := roles.Remove(req.Name); err != nil {
+			log.Printf("roll back creating role %q: %s", req.Name, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (a *Admin) apiList(c *aries.C) (*rolesapi.List, error) {
+	list, err := a.roles.List()
+	if err != nil {
+		return nil, err
+	}
+	return &rolesapi.List{Roles: list}, nil
+}
+
+func (a *Admin) apiDisable(c *aries.C, req *rolesapi.NameRequest) error {
+	return a.roles.WithActor(c).Disable(req.Name)
+}
+
+func (a *Admin) apiEnable(c *aries.C, req *rolesapi.NameRequest) error {
+	return a.roles.WithActor(c).Enable(req.Name)
+}
+
+func (a *Admin) apiSetContact(c *aries.C, req *rolesapi.ContactRequest) error {
+	return a.roles.WithActor(c).SetContact(req.Name, req.Contact)
+}
+
+// IssuePassCode creates a new pass code for the role, and sends it to the
+// role's contact when possible.
+func (a *Admin) IssuePassCode(ctx context.Context, name string) (
+	*rolesapi.PassCodeResult, error,
+) {
+	return a.issuePassCode(ctx, a.roles, name)
+}
+
+func (a *Admin) issuePassCode(
+	ctx context.Context, roles *Roles, name string,
+) (*rolesapi.PassCodeResult, error) {
+	r, err := roles.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	code, err := roles.NewPassCode(name, a.now())
+	if err != nil {
+		return nil, err
+	}
+	if a.notifier == nil || r.Contact == "" {
+		return &rolesapi.PassCodeResult{PassCode: code}, nil
+	}
+	notice := &PassCodeNotice{
+		Role:     name,
+		Contact:  r.Contact,
+		PassCode: code,
+	}
+	if err := a.notifier.NotifyPassCode(ctx, notice); err != nil {
+		return nil, errcode.Annotate(err, "send pass code")
+	}
+	return &rolesapi.PassCodeResult{SentTo: r.Contact}, nil
+}
+
+func (a *Admin) apiPassCode(c *aries.C, req *rolesapi.NameRequest) (
+	*rolesapi.PassCodeResult, error,
+) {
+	return a.issuePassCode(c.Context, a.roles.WithActor(c), req.Name)
+}
+
+func (a *Admin) apiIdentity(c *aries.C, req *rolesapi.NameRequest) (
+	*identity.Identity, error,
+) {
+	return a.roles.Identity(req.Name)
+}
+
+func (a *Admin) apiRemoveKey(
+	c *aries.C, req *rolesapi.RemoveKeyRequest,
+) error {
+	return a.roles.WithActor(c).RemoveKey(req.Name, req.KeyID)
+}
+
+// API returns the admin API router. It should only be mounted where admins
+// can access.
+func (a *Admin) API() *aries.Router {
+	r := aries.NewRouter()
+	r.Call("create", a.apiCreate)
+	r.Call("list", a.apiList)
+	r.Call("disable", a.apiDisable)
+	r.Call("enable", a.apiEnable)
+	r.Call("set-contact", a.apiSetContact)
+	r.Call("passcode", a.apiPassCode)
+	r.Call("identity", a.apiIdentity)
+	r.Call("remove-key", a.apiRemoveKey)
+	return r
+}
@@ -0,0 +1,170 @@
+package roles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/httputil"
+	"shanhu.io/g/identity"
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/roles/rolesapi"
+	"shanhu.io/g/timeutil"
+)
+
+func readNotices(t *testing.T, f string) []*PassCodeNotice {
+	t.Helper()
+	bs, err := os.ReadFile(f)
+	if err != nil {
+		t.Fatal("read notices: ", err)
+	}
+	var notices []*PassCodeNotice
+	for _, line := range bytes.Split(bytes.TrimSpace(bs), []byte("\n")) {
+		n := new(PassCodeNotice)
+		if err := json.Unmarshal(line, n); err != nil {
+			t.Fatal("parse notice: ", err)
+		}
+		notices = append(notices, n)
+	}
+	return notices
+}
+
+func TestAdmin(t *testing.T) {
+	now := time.Now()
+	b := New(pisces.NewMemTables())
+	noticeFile := filepath.Join(t.TempDir(), "notices")
+	admin := NewAdmin(b, &AdminConfig{
+		Notifier: NewFileNotifier(noticeFile),
+		Now:      func() time.Time { return now },
+	})
+	s := httptest.NewServer(aries.Serve(admin.API()))
+	defer s.Close()
+
+	client, err := httputil.NewClient(s.URL)
+	if err != nil {
+		t.Fatal("make client: ", err)
+	}
+	call := func(p string, req, resp any) {
+		t.Helper()
+		if err := client.Call(p, req, resp); err != nil {
+			t.Fatalf("call %q: %s", p, err)
+		}
+	}
+
+	call("/create", &rolesapi.CreateRequest{
+		Name:    "alice",
+		Contact: "alice@example.com",
+	}, nil)
+	call("/create", &rolesapi.CreateRequest{Name: "bob"}, nil)
+	err = client.Call("/create", &rolesapi.CreateRequest{Name: "eve\r\n"}, nil)
+	if err == nil {
+		t.Error("created a role with line breaks in its name")
+	}
+
+	list := new(rolesapi.List)
+	call("/list", nil, list)
+	if len(list.Roles) != 2 {
+		t.Fatalf("got %d roles, want 2", len(list.Roles))
+	}
+
+	// Pass codes are sent to roles that have a contact.
+	result := new(rolesapi.PassCodeResult)
+	call("/passcode", &rolesapi.NameRequest{Name: "alice"}, result)
+	if result.SentTo != "alice@example.com" || result.PassCode != nil {
+		t.Errorf("got pass code result %+v", result)
+	}
+	notices := readNotices(t, noticeFile)
+	if len(notices) != 1 || notices[0].Role != "alice" {
+		t.Fatalf("got notices %+v", notices)
+	}
+
+	result = new(rolesapi.PassCodeResult)
+	call("/passcode", &rolesapi.NameRequest{Name: "bob"}, result)
+	if result.PassCode == nil {
+		t.Error("pass code not returned for role without contact")
+	}
+
+	core := identity.NewMemCore(nil)
+	id, err := core.Init(identity.SingleKeyCoreConfig(now.Add(time.Hour)))
+	if err != nil {
+		t.Fatal("init identity: ", err)
+	}
+	code := notices[0].PassCode.Code
+	if err := b.SetupWithCode("alice", id, code, now); err != nil {
+		t.Fatal("setup with code: ", err)
+	}
+
+	got := new(identity.Identity)
+	call("/identity", &rolesapi.NameRequest{Name: "alice"}, got)
+	if len(got.PublicKeys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(got.PublicKeys))
+	}
+	call("/remove-key", &rolesapi.RemoveKeyRequest{
+		Name:  "alice",
+		KeyID: got.PublicKeys[0].ID,
+	}, nil)
+	got = new(identity.Identity)
+	call("/identity", &rolesapi.NameRequest{Name: "alice"}, got)
+	if len(got.PublicKeys) != 0 {
+		t.Errorf("got %d keys after removing, want 0", len(got.PublicKeys))
+	}
+
+	call("/disable", &rolesapi.NameRequest{Name: "bob"}, nil)
+	err = client.Call("/passcode", &rolesapi.NameRequest{Name: "bob"}, nil)
+	if err == nil {
+		t.Error("issued pass code for a disabled role")
+	}
+	call("/enable", &rolesapi.NameRequest{Name: "bob"}, nil)
+	call("/passcode", &rolesapi.NameRequest{Name: "bob"}, nil)
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	n := NewSMTPNotifier(&SMTPConfig{From: "noreply@example.com"})
+	var gotAddr string
+	var gotMsg []byte
+	n.send = func(
+		addr string, _ smtp.Auth, _ string, _ []string, msg []byte,
+	) error {
+		gotAddr = addr
+		gotMsg = msg
+		return nil
+	}
+
+	notice := &PassCodeNotice{
+		Role:    "alice",
+		Contact: "alice@example.com",
+		PassCode: &rolesapi.PassCode{
+			Code:   "12345678",
+			Expire: timeutil.TimestampNow(),
+		},
+	}
+	ctx := context.Background()
+	if err := n.NotifyPassCode(ctx, notice); err != nil {
+		t.Fatal("notify: ", err)
+	}
+	if gotAddr != "localhost:25" {
+		t.Errorf("got SMTP address %q, want localhost:25", gotAddr)
+	}
+	if !strings.Contains(string(gotMsg), "12345678") {
+		t.Errorf("pass code not in message: %q", gotMsg)
+	}
+
+	notice.Contact = "alice@example.com\r\nBcc: eve@example.com"
+	if err := n.NotifyPassCode(ctx, notice); err == nil {
+		t.Error("sent to a contact with line breaks")
+	}
+
+	notice.Contact = "alice@example.com"
+	notice.Role = "alice\r\nBcc: eve@example.com"
+	if err := n.NotifyPassCode(ctx, notice); err == nil {
+		t.Error("sent for a role name with line breaks")
+	}
+}
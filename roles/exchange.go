@@ -1,13 +1,35 @@
 package roles
 
 import (
+	"context"
 	"time"
 
 	"shanhu.io/g/aries"
+	"shanhu.io/g/audit"
+	"shanhu.io/g/identity"
+	"shanhu.io/g/jwt"
 	"shanhu.io/g/signin"
 	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/std/errcode"
 )
 
+// VerifySelfToken checks the self-signed JWT token.
+func (b *Roles) VerifySelfToken(
+	ctx context.Context, name, token string, t time.Time,
+) (*jwt.Token, error) {
+	r, err := b.get(name)
+	if err != nil {
+		return nil, err
+	}
+	if r.Role.Disabled {
+		return nil, errcode.Unauthorizedf("role is disabled")
+	}
+	if r.Identity == nil {
+		return nil, errcode.Unauthorizedf("no identity found")
+	}
+	return identity.VerifySelfToken(ctx, token, name, b.host, r.Identity, t)
+}
+
 // SignInRequest signs in with a self-signed token.
 type SignInRequest struct {
 	User      string
@@ -33,15 +55,50 @@ func NewExchange(r *Roles, tokener signin.Tokener) *Exchange {
 // Exchange exchanges self token for session token.
 func (x *Exchange) Exchange(c *aries.C, req *SignInRequest) (
 	*signinapi.Creds, error,
+) {
+	creds, err := x.exchange(c, req)
+	x.roles.audit.Record(c, &audit.Event{
+		Actor:  req.User,
+		Action: audit.Exchange,
+		Target: req.User,
+	}, err)
+	return creds, err
+}
+
+func (x *Exchange) exchange(c *aries.C, req *SignInRequest) (
+	*signinapi.Creds, error,
 ) {
 	t := time.Now()
-	if _, err := x.roles.VerifySelfToken(
-		c.Context, req.User, req.SelfToken, t,
-	); err != nil {
+	tok, err := x.roles.VerifySelfToken(c.Context, req.User, req.SelfToken, t)
+	if err != nil {
 		return nil, altAuthErr(err, "verify self token")
 	}
+	r, err := x.roles.Get(req.User)
+	if err != nil {
+		return nil, altAuthErr(err, "get role")
+	}
 
 	const ttl = 30 * time.Minute
-	token := x.tokener.Token(req.User, ttl)
+	scopes := grantScopes(r.Scopes, tok.ClaimSet.Scopes())
+	token, err := signin.IssueTokenErr(x.tokener, req.User, scopes, ttl)
+	if err != nil {
+		return nil, aries.AltInternal(err, "issue token")
+	}
 	return signin.TokenCreds(req.User, token), nil
 }
+
+// grantScopes returns the scopes to grant for a session. When the self token
+// does not ask for any scope, all scopes of the role are granted. Otherwise,
+// only the asked scopes that the role has are granted.
+func grantScopes(role, asked []string) []string {
+	if len(asked) == 0 {
+		return role
+	}
+	var ret []string
+	for _, s := range asked {
+		if aries.ScopeGranted(role, s) {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
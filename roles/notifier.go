@@ -0,0 +1,147 @@
+package roles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"shanhu.io/g/roles/rolesapi"
+	"shanhu.io/std/errcode"
+)
+
+// PassCodeNotice is a notice that sends a pass code to a role's contact.
+type PassCodeNotice struct {
+	Role     string
+	Contact  string
+	PassCode *rolesapi.PassCode
+}
+
+// Notifier delivers pass codes to roles.
+type Notifier interface {
+	NotifyPassCode(ctx context.Context, n *PassCodeNotice) error
+}
+
+// SMTPConfig is the configuration of an SMTP notifier.
+type SMTPConfig struct {
+	// Addr is the address of the SMTP server. Default is "localhost:25",
+	// which is often a local mail relay.
+	Addr string
+
+	From string
+
+	// Optional user name and password for PLAIN authentication.
+	User     string
+	Password string
+}
+
+type sendMailFunc func(
+	addr string, a smtp.Auth, from string, to []string, msg []byte,
+) error
+
+// SMTPNotifier sends pass codes as emails via an SMTP server.
+type SMTPNotifier struct {
+	config *SMTPConfig
+	send   sendMailFunc
+}
+
+// NewSMTPNotifier creates a notifier that sends emails via an SMTP server.
+func NewSMTPNotifier(config *SMTPConfig) *SMTPNotifier {
+	return &SMTPNotifier{
+		config: config,
+		send:   smtp.SendMail,
+	}
+}
+
+// hasControl checks if s has control characters, like line breaks, which
+// can inject headers into an email.
+func hasControl(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
+
+func passCodeMessage(from string, n *PassCodeNotice) []byte {
+	expire := n.PassCode.Expire.Time().Format(time.RFC1123Z)
+	var lines = []string{
+		"From: " + from,
+		"To: " + n.Contact,
+		fmt.Sprintf("Subject: Pass code for role %s", n.Role),
+		"",
+		fmt.Sprintf("The pass code of role %q is: %s", n.Role, n.PassCode.Code),
+		"",
+		"It can only be used once, and expires at " + expire + ".",
+		"",
+	}
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+// NotifyPassCode sends the pass code to the contact as an email.
+func (s *SMTPNotifier) NotifyPassCode(
+	_ context.Context, n *PassCodeNotice,
+) error {
+	if strings.ContainsAny(n.Contact, "\r\n") {
+		return errcode.InvalidArgf("invalid contact %q", n.Contact)
+	}
+	if hasControl(n.Role) {
+		return errcode.InvalidArgf("invalid role name %q", n.Role)
+	}
+	addr := s.config.Addr
+	if addr == "" {
+		addr = "localhost:25"
+	}
+	var auth smtp.Auth
+	if s.config.User != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return errcode.Annotate(err, "parse SMTP address")
+		}
+		auth = smtp.PlainAuth("", s.config.User, s.config.Password, host)
+	}
+	msg := passCodeMessage(s.config.From, n)
+	to := []string{n.Contact}
+	if err := s.send(addr, auth, s.config.From, to, msg); err != nil {
+		return errcode.Annotate(err, "send email")
+	}
+	return nil
+}
+
+// FileNotifier appends pass code notices to a file as JSON lines. It is
+// useful for testing and local development.
+type FileNotifier struct {
+	mu   sync.Mutex
+	file string
+}
+
+// NewFileNotifier creates a notifier that appends notices to the file.
+func NewFileNotifier(file string) *FileNotifier {
+	return &FileNotifier{file: file}
+}
+
+// NotifyPassCode appends the notice to the file.
+func (f *FileNotifier) NotifyPassCode(
+	_ context.Context, n *PassCodeNotice,
+) error {
+	bs, err := json.Marshal(n)
+	if err != nil {
+		return errcode.Annotate(err, "marshal notice")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	out, err := os.OpenFile(f.file, flag, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := out.Write(append(bs, '\n')); err != nil {
+		return err
+	}
+	return out.Close()
+}
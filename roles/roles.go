@@ -1,12 +1,13 @@
 package roles
 
 import (
-	"context"
 	"sort"
+	"strings"
 	"time"
 
+	"shanhu.io/g/aries"
+	"shanhu.io/g/audit"
 	"shanhu.io/g/identity"
-	"shanhu.io/g/jwt"
 	"shanhu.io/g/pisces"
 	"shanhu.io/g/rand"
 	"shanhu.io/g/roles/rolesapi"
@@ -26,6 +27,9 @@ type Roles struct {
 	host string
 
 	passCodeExpiry time.Duration
+
+	audit *audit.Log
+	actor *aries.C // request that makes the changes, for auditing
 }
 
 // New creates the roles table.
@@ -51,6 +55,19 @@ type role struct {
 	PassCode *passCode `json:",omitempty"`
 }
 
+// WithActor returns a view of the roles whose changes are recorded in the
+// audit log as made by the request c, with the user and the remote IP of c.
+func (b *Roles) WithActor(c *aries.C) *Roles {
+	ret := *b
+	ret.actor = c
+	return &ret
+}
+
+// record records a change of a role in the audit log, if any.
+func (b *Roles) record(action, name string, err error) {
+	b.audit.Record(b.actor, &audit.Event{Action: action, Target: name}, err)
+}
+
 // New creates a new empty role. Its identity is empty.
 func (b *Roles) New(name string, t time.Time) error {
 	pub := &rolesapi.Role{
@@ -58,7 +75,9 @@ func (b *Roles) New(name string, t time.Time) error {
 		TimeCreate: timeutil.NewTimestamp(t),
 	}
 	r := &role{Role: pub}
-	return b.t.Add(name, r)
+	err := b.t.Add(name, r)
+	b.record(audit.RoleCreate, name, err)
+	return err
 }
 
 func (b *Roles) get(name string) (*role, error) {
@@ -78,8 +97,22 @@ func (b *Roles) Get(name string) (*rolesapi.Role, error) {
 	return r.Role, nil
 }
 
+// Scopes returns the permission scopes of a role. It can be used as the
+// CookieScopes function of a sign in gate.
+func (b *Roles) Scopes(name string) ([]string, error) {
+	r, err := b.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.Role.Scopes, nil
+}
+
 // Remove removes a role.
-func (b *Roles) Remove(name string) error { return b.t.Remove(name) }
+func (b *Roles) Remove(name string) error {
+	err := b.t.Remove(name)
+	b.record(audit.RoleRemove, name, err)
+	return err
+}
 
 func (b *Roles) setDisabled(name string, v bool) error {
 	return b.mutate(name, func(r *role) error {
@@ -90,12 +123,40 @@ func (b *Roles) setDisabled(name string, v bool) error {
 
 // Disable disables a role.
 func (b *Roles) Disable(name string) error {
-	return b.setDisabled(name, true)
+	err := b.setDisabled(name, true)
+	b.record(audit.RoleDisable, name, err)
+	return err
 }
 
 // Enable enables a role.
 func (b *Roles) Enable(name string) error {
-	return b.setDisabled(name, false)
+	err := b.setDisabled(name, false)
+	b.record(audit.RoleEnable, name, err)
+	return err
+}
+
+// SetScopes sets the permission scopes of a role.
+func (b *Roles) SetScopes(name string, scopes []string) error {
+	err := b.mutate(name, func(r *role) error {
+		r.Role.Scopes = scopes
+		return nil
+	})
+	b.audit.Record(b.actor, &audit.Event{
+		Action: audit.RoleSetScopes,
+		Target: name,
+		Detail: strings.Join(scopes, " "),
+	}, err)
+	return err
+}
+
+// SetContact sets the contact of a role, where pass codes are sent to.
+func (b *Roles) SetContact(name, contact string) error {
+	err := b.mutate(name, func(r *role) error {
+		r.Role.Contact = contact
+		return nil
+	})
+	b.record(audit.RoleSetContact, name, err)
+	return err
 }
 
 // List lists all roles.
@@ -138,6 +199,10 @@ func (b *Roles) SetPassCodeExpiry(d time.Duration) {
 // SetHostDomain sets the host domain for checking JWT.
 func (b *Roles) SetHostDomain(domain string) { b.host = domain }
 
+// SetAudit sets the audit log that records role changes and token
+// exchanges.
+func (b *Roles) SetAudit(l *audit.Log) { b.audit = l }
+
 func (b *Roles) mutate(name string, f func(r *role) error) error {
 	r := new(role)
 	return b.t.Mutate(name, r, func(v any) error {
@@ -155,13 +220,15 @@ func (b *Roles) NewPassCode(name string, now time.Time) (
 		Valid:  timeutil.NewTimestamp(now.Add(-buffer)),
 		Expire: timeutil.NewTimestamp(now.Add(b.passCodeExpiry)),
 	}
-	if err := b.mutate(name, func(r *role) error {
+	err := b.mutate(name, func(r *role) error {
 		if r.Role.Disabled {
 			return errcode.InvalidArgf("role is disabled")
 		}
 		r.PassCode = code
 		return nil
-	}); err != nil {
+	})
+	b.record(audit.RolePassCode, name, err)
+	if err != nil {
 		return nil, err
 	}
 	return code.public(), nil
@@ -184,7 +251,7 @@ func (b *Roles) GetPassCode(name string) (*rolesapi.PassCode, error) {
 func (b *Roles) SetupWithCode(
 	name string, id *identity.Identity, code string, t time.Time,
 ) error {
-	return b.mutate(name, func(r *role) error {
+	err := b.mutate(name, func(r *role) error {
 		if r.Role.Disabled {
 			return errcode.InvalidArgf("role is disabled")
 		}
@@ -198,21 +265,46 @@ func (b *Roles) SetupWithCode(
 		r.PassCode.Consumed = true
 		return nil
 	})
+	b.audit.Record(b.actor, &audit.Event{
+		Actor:  name,
+		Action: audit.RoleSetup,
+		Target: name,
+	}, err)
+	return err
 }
 
-// VerifySelfToken checks the self-signed JWT token.
-func (b *Roles) VerifySelfToken(
-	ctx context.Context, name, token string, t time.Time,
-) (*jwt.Token, error) {
+// Identity returns the identity of a role. It returns an empty identity if
+// the role has not set up one.
+func (b *Roles) Identity(name string) (*identity.Identity, error) {
 	r, err := b.get(name)
 	if err != nil {
 		return nil, err
 	}
-	if r.Role.Disabled {
-		return nil, errcode.Unauthorizedf("role is disabled")
-	}
 	if r.Identity == nil {
-		return nil, errcode.Unauthorizedf("no identity found")
+		return new(identity.Identity), nil
 	}
-	return identity.VerifySelfToken(ctx, token, name, b.host, r.Identity, t)
+	return r.Identity, nil
+}
+
+// RemoveKey removes a public key from the identity of a role.
+func (b *Roles) RemoveKey(name, keyID string) error {
+	err := b.mutate(name, func(r *role) error {
+		if r.Identity == nil {
+			return errcode.NotFoundf("key %q not found", keyID)
+		}
+		keys := r.Identity.PublicKeys
+		for i, k := range keys {
+			if k.ID == keyID {
+				r.Identity.PublicKeys = append(keys[:i], keys[i+1:]...)
+				return nil
+			}
+		}
+		return errcode.NotFoundf("key %q not found", keyID)
+	})
+	b.audit.Record(b.actor, &audit.Event{
+		Action: audit.RoleRemoveKey,
+		Target: name,
+		Detail: keyID,
+	}, err)
+	return err
 }
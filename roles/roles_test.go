@@ -1,11 +1,15 @@
 package roles
 
 import (
+	"reflect"
 	"testing"
 
 	"context"
+	"net/http/httptest"
 	"time"
 
+	"shanhu.io/g/aries"
+	"shanhu.io/g/audit"
 	"shanhu.io/g/identity"
 	"shanhu.io/g/pisces"
 	"shanhu.io/g/timeutil"
@@ -18,11 +22,17 @@ func TestRoles(t *testing.T) {
 	const name = "h8liu"
 	const host = "shanhu.io"
 	b.SetHostDomain(host)
+	auditLog := audit.New(pisces.NewOrderedMemKV(), nil)
+	b.SetAudit(auditLog)
 
 	now := time.Now()
 	nowFunc := func() time.Time { return now }
+	c := &aries.C{
+		User: "admin",
+		Req:  httptest.NewRequest("POST", "/", nil),
+	}
 
-	if err := b.New(name, now); err != nil {
+	if err := b.WithActor(c).New(name, now); err != nil {
 		t.Fatal("create role: ", err)
 	}
 
@@ -37,7 +47,7 @@ func TestRoles(t *testing.T) {
 		t.Errorf("create time, got %q, want %q", timeCreate, now)
 	}
 
-	code, err := b.NewPassCode(name, now)
+	code, err := b.WithActor(c).NewPassCode(name, now)
 	if err != nil {
 		t.Fatal("get new passcode: ", err)
 	}
@@ -61,4 +71,73 @@ func TestRoles(t *testing.T) {
 	if _, err := b.VerifySelfToken(ctx, name, self, now); err != nil {
 		t.Fatal("verify self-sign ID token: ", err)
 	}
+
+	scopes := []string{"keys:read"}
+	if err := b.WithActor(c).SetScopes(name, scopes); err != nil {
+		t.Fatal("set scopes: ", err)
+	}
+	r, err = b.Get(name)
+	if err != nil {
+		t.Fatal("get role: ", err)
+	}
+	if !reflect.DeepEqual(r.Scopes, scopes) {
+		t.Errorf("got scopes %q, want %q", r.Scopes, scopes)
+	}
+
+	if err := b.WithActor(c).Disable(name); err != nil {
+		t.Fatal("disable role: ", err)
+	}
+	if _, err := b.WithActor(c).NewPassCode(name, now); err == nil {
+		t.Error("created passcode for a disabled role")
+	}
+
+	events, err := auditLog.Query(&audit.Query{Target: name})
+	if err != nil {
+		t.Fatal("query audit log: ", err)
+	}
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Action+":"+e.Result+":"+e.Actor)
+		if e.Actor == "admin" && e.RemoteIP != "192.0.2.1" {
+			t.Errorf("event %d: got remote IP %q", e.Seq, e.RemoteIP)
+		}
+	}
+	wantActions := []string{
+		"role.create:ok:admin",
+		"role.passcode:ok:admin",
+		"role.setup:ok:" + name,
+		"role.set-scopes:ok:admin",
+		"role.disable:ok:admin",
+		"role.passcode:error:admin",
+	}
+	if !reflect.DeepEqual(actions, wantActions) {
+		t.Errorf("got audit events %q, want %q", actions, wantActions)
+	}
+	if err := auditLog.Verify(); err != nil {
+		t.Error("verify audit log: ", err)
+	}
+}
+
+func TestGrantScopes(t *testing.T) {
+	role := []string{"keys:*", "roles:read"}
+	for _, test := range []struct {
+		asked []string
+		want  []string
+	}{
+		{asked: nil, want: role},
+		{asked: []string{"keys:read"}, want: []string{"keys:read"}},
+		{
+			asked: []string{"roles:read", "roles:write"},
+			want:  []string{"roles:read"},
+		},
+		{asked: []string{"ssh"}, want: nil},
+	} {
+		got := grantScopes(role, test.asked)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf(
+				"grantScopes(%q) got %q, want %q",
+				test.asked, got, test.want,
+			)
+		}
+	}
 }
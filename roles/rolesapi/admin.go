@@ -0,0 +1,37 @@
+package rolesapi
+
+// CreateRequest is the request to create a role.
+type CreateRequest struct {
+	Name    string
+	Contact string `json:",omitempty"`
+}
+
+// NameRequest is a request on a role of the name.
+type NameRequest struct {
+	Name string
+}
+
+// ContactRequest is the request to set the contact of a role.
+type ContactRequest struct {
+	Name    string
+	Contact string
+}
+
+// RemoveKeyRequest is the request to remove a public key from the identity
+// of a role.
+type RemoveKeyRequest struct {
+	Name  string
+	KeyID string
+}
+
+// List is a list of roles.
+type List struct {
+	Roles []*Role
+}
+
+// PassCodeResult is the result of issuing a pass code. When the pass code is
+// sent to the role's contact, the code is not returned.
+type PassCodeResult struct {
+	SentTo   string    `json:",omitempty"`
+	PassCode *PassCode `json:",omitempty"`
+}
@@ -9,6 +9,12 @@ type Role struct {
 	Name       string
 	TimeCreate *timeutil.Timestamp
 	Disabled   bool `json:",omitempty"`
+
+	// Scopes are the permission scopes granted to the role's sessions.
+	Scopes []string `json:",omitempty"`
+
+	// Contact is where pass codes are sent to, often an email address.
+	Contact string `json:",omitempty"`
 }
 
 // PassCode contains the info of a pass code.
@@ -0,0 +1,71 @@
+package signer
+
+import (
+	"strings"
+
+	"shanhu.io/std/errcode"
+)
+
+// RingKey is a key in a key ring.
+type RingKey struct {
+	ID  string
+	Key []byte
+
+	// Retired keys are no longer used for signing or verifying.
+	Retired bool `json:",omitempty"`
+}
+
+// KeyRing is a list of keys that can be rotated. The newest key, which is
+// the last one that is not retired, is used for signing. All keys that are
+// not retired are used for verifying. To rotate keys, append a new key, and
+// retire the old keys after the sessions signed by them expire.
+type KeyRing struct {
+	Keys []*RingKey
+}
+
+// NewKeyRing creates a key ring with a single key.
+func NewKeyRing(id string, key []byte) *KeyRing {
+	return &KeyRing{Keys: []*RingKey{{ID: id, Key: key}}}
+}
+
+// Check checks if the key ring is valid to use.
+func (r *KeyRing) Check() error {
+	ids := make(map[string]bool)
+	for _, k := range r.Keys {
+		if k.ID == "" {
+			return errcode.InvalidArgf("key ID missing")
+		}
+		if strings.Contains(k.ID, ".") {
+			return errcode.InvalidArgf("key ID %q has a dot", k.ID)
+		}
+		if ids[k.ID] {
+			return errcode.InvalidArgf("duplicate key ID %q", k.ID)
+		}
+		ids[k.ID] = true
+		if len(k.Key) == 0 && !k.Retired {
+			return errcode.InvalidArgf("key %q is empty", k.ID)
+		}
+	}
+	if r.newest() == nil {
+		return errcode.InvalidArgf("no key for signing")
+	}
+	return nil
+}
+
+func (r *KeyRing) newest() *RingKey {
+	for i := len(r.Keys) - 1; i >= 0; i-- {
+		if k := r.Keys[i]; !k.Retired {
+			return k
+		}
+	}
+	return nil
+}
+
+func (r *KeyRing) find(id string) *RingKey {
+	for _, k := range r.Keys {
+		if k.ID == id && !k.Retired {
+			return k
+		}
+	}
+	return nil
+}
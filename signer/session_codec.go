@@ -0,0 +1,117 @@
+package signer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"shanhu.io/g/rand"
+)
+
+// sessionCodec encodes the content of a session, which is the expire
+// timestamp followed by the data, into a session string.
+type sessionCodec interface {
+	encode(bs []byte) (string, error)
+	decode(session string) ([]byte, bool)
+}
+
+// hexCodec signs sessions with a single key, and encodes them in hex.
+type hexCodec struct {
+	s *Signer
+}
+
+func (c *hexCodec) encode(bs []byte) (string, error) {
+	return c.s.SignHex(bs), nil
+}
+
+func (c *hexCodec) decode(session string) ([]byte, bool) {
+	ok, bs := c.s.CheckHex(session)
+	return bs, ok
+}
+
+// ringCodec signs or encrypts sessions with the keys in a key ring. A
+// session is the key ID and the base64 encoded payload joined by a dot.
+type ringCodec struct {
+	ring    *KeyRing
+	encrypt bool
+}
+
+func ringMAC(k *RingKey, bs []byte) []byte {
+	m := hmac.New(sha256.New, k.Key)
+	m.Write([]byte(k.ID))
+	m.Write([]byte{0})
+	m.Write(bs)
+	return m.Sum(nil)
+}
+
+func ringAEAD(k *RingKey) (cipher.AEAD, error) {
+	// Derives the encryption key, so that keys of any length can be used.
+	m := hmac.New(sha256.New, k.Key)
+	m.Write([]byte("signer session encryption"))
+	block, err := aes.NewCipher(m.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (c *ringCodec) encode(bs []byte) (string, error) {
+	k := c.ring.newest()
+	var payload []byte
+	if c.encrypt {
+		aead, err := ringAEAD(k)
+		if err != nil {
+			return "", err
+		}
+		nonce := rand.Bytes(aead.NonceSize())
+		payload = aead.Seal(nonce, nonce, bs, []byte(k.ID))
+	} else {
+		payload = append(append([]byte(nil), bs...), ringMAC(k, bs)...)
+	}
+	return k.ID + "." + base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+func (c *ringCodec) decode(session string) ([]byte, bool) {
+	id, encoded, ok := strings.Cut(session, ".")
+	if !ok {
+		return nil, false
+	}
+	k := c.ring.find(id)
+	if k == nil {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+
+	if c.encrypt {
+		aead, err := ringAEAD(k)
+		if err != nil {
+			return nil, false
+		}
+		n := aead.NonceSize()
+		if len(payload) < n {
+			return nil, false
+		}
+		nonce, sealed := payload[:n], payload[n:]
+		bs, err := aead.Open(nil, nonce, sealed, []byte(id))
+		if err != nil {
+			return nil, false
+		}
+		return bs, true
+	}
+
+	n := len(payload)
+	if n < sha256.Size {
+		return nil, false
+	}
+	bs, mac := payload[:n-sha256.Size], payload[n-sha256.Size:]
+	if !hmac.Equal(mac, ringMAC(k, bs)) {
+		return nil, false
+	}
+	return bs, true
+}
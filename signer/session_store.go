@@ -0,0 +1,97 @@
+package signer
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/rand"
+	"shanhu.io/std/errcode"
+)
+
+type storedSession struct {
+	ID      string // Key of the session in the store.
+	Hash    []byte // SHA256 hash of the secret part of the session.
+	Content []byte // Expire timestamp followed by the data.
+}
+
+// storeCodec saves sessions on the server side. A session is a random ID
+// and a random secret joined by a dot. The ID is the key of the session's
+// content in the store, and only the hash of the secret is saved, so the
+// sessions cannot be recovered from the store.
+type storeCodec struct {
+	kv *pisces.KV
+}
+
+func randString(n int) string {
+	return base64.RawURLEncoding.EncodeToString(rand.Bytes(n))
+}
+
+func (c *storeCodec) encode(bs []byte) (string, error) {
+	id := randString(16)
+	secret := randString(32)
+	hash := sha256.Sum256([]byte(secret))
+	s := &storedSession{ID: id, Hash: hash[:], Content: bs}
+	if err := c.kv.Add(id, s); err != nil {
+		return "", err
+	}
+	return id + "." + secret, nil
+}
+
+func (c *storeCodec) decode(session string) ([]byte, bool) {
+	id, secret, ok := strings.Cut(session, ".")
+	if !ok || id == "" {
+		return nil, false
+	}
+	s := new(storedSession)
+	if err := c.kv.Get(id, s); err != nil {
+		return nil, false
+	}
+	hash := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(hash[:], s.Hash) != 1 {
+		return nil, false
+	}
+	return s.Content, true
+}
+
+func (c *storeCodec) remove(session string) error {
+	id, _, _ := strings.Cut(session, ".")
+	return c.kv.Remove(id)
+}
+
+// clean removes the sessions that expire before now.
+func (c *storeCodec) clean(now time.Time) error {
+	var expired []string
+	it := &pisces.Iter{
+		Make: func() any { return new(storedSession) },
+		Do: func(_ string, v any) error {
+			s := v.(*storedSession)
+			if s.ID == "" {
+				return nil
+			}
+			if len(s.Content) < timestampLen {
+				expired = append(expired, s.ID)
+				return nil
+			}
+			ts := binary.LittleEndian.Uint64(s.Content[:timestampLen])
+			if !now.Before(time.Unix(0, int64(ts))) {
+				expired = append(expired, s.ID)
+			}
+			return nil
+		},
+	}
+	if err := c.kv.Walk(it); err != nil {
+		return err
+	}
+	for _, id := range expired {
+		err := c.kv.Remove(id)
+		if err != nil && !errcode.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,15 +4,25 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"log"
+	"sync"
 	"time"
+
+	"shanhu.io/g/pisces"
+	"shanhu.io/std/errcode"
 )
 
 // Sessions signs a session data so that the server can run statelessly.
+// Optionally, sessions can be encrypted, or saved on the server side.
 type Sessions struct {
-	s          *Signer
+	codec      sessionCodec
+	store      *storeCodec // Set in server-side mode.
 	ttl        time.Duration
 	refreshTTL time.Duration
 
+	mu        sync.Mutex
+	lastClean time.Time // Last time that expired sessions are cleaned.
+
 	// TimeFunc is an optional function for reading the current timestamp.
 	// When it is nil, the Sessions object uses time.Now().
 	TimeFunc func() time.Time
@@ -28,22 +38,88 @@ func refreshTTL(ttl time.Duration) time.Duration {
 // NewSessions creates a new session store.
 func NewSessions(key []byte, ttl time.Duration) *Sessions {
 	return &Sessions{
-		s:          New(key),
+		codec:      &hexCodec{s: New(key)},
 		ttl:        ttl,
 		refreshTTL: refreshTTL(ttl),
 	}
 }
 
-// New creates a new session with some data.
+// SessionsConfig is the configuration for creating a session store.
+type SessionsConfig struct {
+	// Keys signs and verifies the sessions. When it is nil, a random key
+	// is used.
+	Keys *KeyRing
+
+	// Encrypt encrypts the sessions with AES-GCM, so that clients cannot
+	// read the session data.
+	Encrypt bool
+
+	// Store saves the sessions on the server side when it is not nil.
+	// Sessions are then random IDs and secrets, and Keys and Encrypt are
+	// not used. Expired sessions are removed from the store every TTL when
+	// new sessions are created, or by calling Clean.
+	Store *pisces.KV
+
+	TTL time.Duration
+	Now func() time.Time
+}
+
+// NewSessionsFromConfig creates a new session store with the config.
+func NewSessionsFromConfig(config *SessionsConfig) (*Sessions, error) {
+	s := &Sessions{
+		ttl:        config.TTL,
+		refreshTTL: refreshTTL(config.TTL),
+		TimeFunc:   config.Now,
+	}
+	if config.Store != nil {
+		s.store = &storeCodec{kv: config.Store}
+		s.codec = s.store
+		return s, nil
+	}
+
+	ring := config.Keys
+	if ring == nil {
+		ring = NewKeyRing("0", New(nil).key)
+	}
+	if err := ring.Check(); err != nil {
+		return nil, errcode.Annotate(err, "invalid key ring")
+	}
+	s.codec = &ringCodec{ring: ring, encrypt: config.Encrypt}
+	return s, nil
+}
+
+// New creates a new session with some data. In server-side mode, it returns
+// an empty session when the session cannot be saved. Use NewSession to get
+// the error.
 func (s *Sessions) New(data []byte, ttl time.Duration) (string, time.Time) {
+	ret, expires, err := s.NewSession(data, ttl)
+	if err != nil {
+		log.Println(err)
+		return "", expires
+	}
+	return ret, expires
+}
+
+// NewSession creates a new session with some data. It returns an error when
+// the session cannot be encoded, or cannot be saved in server-side mode.
+func (s *Sessions) NewSession(data []byte, ttl time.Duration) (
+	string, time.Time, error,
+) {
 	buf := new(bytes.Buffer)
 
 	if ttl <= 0 || ttl > s.ttl {
 		ttl = s.ttl
 	}
 
+	timeNow := now(s.TimeFunc)
+	if s.store != nil && s.needClean(timeNow) {
+		if err := s.store.clean(timeNow); err != nil {
+			log.Printf("clean expired sessions: %s", err)
+		}
+	}
+
 	// write the timestamp
-	expires := now(s.TimeFunc).Add(ttl)
+	expires := timeNow.Add(ttl)
 	ts := make([]byte, timestampLen)
 	binary.LittleEndian.PutUint64(ts, uint64(expires.UnixNano()))
 	buf.Write(ts)
@@ -52,9 +128,37 @@ func (s *Sessions) New(data []byte, ttl time.Duration) (string, time.Time) {
 		buf.Write(data)
 	}
 
-	return s.s.SignHex(buf.Bytes()), expires
+	ret, err := s.codec.encode(buf.Bytes())
+	if err != nil {
+		return "", expires, errcode.Annotate(err, "create session")
+	}
+	return ret, expires, nil
+}
+
+// needClean checks if it is time to clean the expired sessions, and if so,
+// marks now as the last time of cleaning.
+func (s *Sessions) needClean(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.lastClean.IsZero() && now.Sub(s.lastClean) < s.ttl {
+		return false
+	}
+	s.lastClean = now
+	return true
+}
+
+// Clean removes the expired sessions in server-side mode. It does nothing
+// in other modes.
+func (s *Sessions) Clean() error {
+	if s.store == nil {
+		return nil
+	}
+	return s.store.clean(now(s.TimeFunc))
 }
 
+// ServerSide returns true if the sessions are saved on the server side.
+func (s *Sessions) ServerSide() bool { return s.store != nil }
+
 // NewJSON creates a new session with a JSON marshallabe data.
 func (s *Sessions) NewJSON(data any) (string, time.Time, error) {
 	bs, err := json.Marshal(data)
@@ -63,13 +167,12 @@ func (s *Sessions) NewJSON(data any) (string, time.Time, error) {
 		return "", t, err
 	}
 
-	ret, expires := s.New(bs, 0)
-	return ret, expires, nil
+	return s.NewSession(bs, 0)
 }
 
 // Check checks if it is a signed data
 func (s *Sessions) Check(session string) ([]byte, time.Duration, bool) {
-	ok, bs := s.s.CheckHex(session)
+	bs, ok := s.codec.decode(session)
 	if !ok {
 		return nil, 0, false
 	}
@@ -83,6 +186,10 @@ func (s *Sessions) Check(session string) ([]byte, time.Duration, bool) {
 	timeNow := now(s.TimeFunc)
 
 	if !timeNow.Before(expire) {
+		if s.store != nil {
+			// Best effort clean up.
+			s.store.remove(session)
+		}
 		return nil, 0, false
 	}
 
@@ -99,7 +206,9 @@ func (s *Sessions) CheckJSON(session string, dat any) bool {
 	return json.Unmarshal(bs, dat) == nil
 }
 
-// NewState creates a new state, which is a session with no data.
+// NewState creates a new state, which is a session with no data. It returns
+// an empty state when the session cannot be saved. Use NewSession(nil, 0)
+// to get the error.
 func (s *Sessions) NewState() string {
 	ret, _ := s.New(nil, 0)
 	return ret
@@ -118,3 +227,18 @@ func (s *Sessions) CheckState(session string) bool {
 func (s *Sessions) NeedRefresh(ttl time.Duration) bool {
 	return ttl < s.refreshTTL
 }
+
+// Delete deletes a session in server-side mode, so that it is no longer
+// valid. Sessions that are not saved on the server side cannot be deleted.
+func (s *Sessions) Delete(session string) error {
+	if s.store == nil {
+		return errcode.Internalf("sessions not saved on server")
+	}
+	if err := s.store.remove(session); err != nil {
+		if errcode.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
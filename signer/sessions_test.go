@@ -1,11 +1,22 @@
 package signer
 
 import (
+	"strings"
 	"testing"
-
 	"time"
+
+	"shanhu.io/g/pisces"
 )
 
+func newSession(t *testing.T, s *Sessions, data string) string {
+	t.Helper()
+	session, _, err := s.NewSession([]byte(data), 0)
+	if err != nil {
+		t.Fatal("new session: ", err)
+	}
+	return session
+}
+
 func TestStates(t *testing.T) {
 	s := NewSessions(nil, time.Second)
 	state := s.NewState()
@@ -36,3 +47,152 @@ func TestStatesExpire(t *testing.T) {
 		t.Errorf("check passed, should fail because of time out")
 	}
 }
+
+func newConfigSessions(t *testing.T, config *SessionsConfig) *Sessions {
+	t.Helper()
+	s, err := NewSessionsFromConfig(config)
+	if err != nil {
+		t.Fatal("create sessions: ", err)
+	}
+	return s
+}
+
+func TestSessionsKeyRing(t *testing.T) {
+	ring := NewKeyRing("k1", []byte("key1"))
+	config := &SessionsConfig{Keys: ring, TTL: time.Hour}
+	s := newConfigSessions(t, config)
+	old := newSession(t, s, "data")
+	if !strings.HasPrefix(old, "k1.") {
+		t.Errorf("session %q not signed by k1", old)
+	}
+
+	// Rotates in a new key.
+	ring.Keys = append(ring.Keys, &RingKey{ID: "k2", Key: []byte("key2")})
+	cur := newSession(t, s, "data")
+	if !strings.HasPrefix(cur, "k2.") {
+		t.Errorf("session %q not signed by k2", cur)
+	}
+	for _, session := range []string{old, cur} {
+		if bs, _, ok := s.Check(session); !ok || string(bs) != "data" {
+			t.Errorf("check on session %q failed", session)
+		}
+	}
+
+	// Retires the old key.
+	ring.Keys[0].Retired = true
+	if _, _, ok := s.Check(old); ok {
+		t.Error("session signed by retired key is valid")
+	}
+	if _, _, ok := s.Check(cur); !ok {
+		t.Error("check on current session failed")
+	}
+
+	// Tampered sessions are invalid.
+	for _, session := range []string{
+		"k1" + strings.TrimPrefix(cur, "k2"),
+		cur[:len(cur)-2],
+		"k2.",
+		"",
+	} {
+		if _, _, ok := s.Check(session); ok {
+			t.Errorf("tampered session %q is valid", session)
+		}
+	}
+}
+
+func TestSessionsEncrypt(t *testing.T) {
+	s := newConfigSessions(t, &SessionsConfig{
+		Keys:    NewKeyRing("k", []byte("key")),
+		Encrypt: true,
+		TTL:     time.Hour,
+	})
+	data := map[string]string{"user": "h8liu"}
+	session, _, err := s.NewJSON(data)
+	if err != nil {
+		t.Fatal("new session: ", err)
+	}
+
+	// The payload is not readable by only decoding.
+	codec := &ringCodec{ring: NewKeyRing("k", []byte("key"))}
+	if _, ok := codec.decode(session); ok {
+		t.Error("encrypted session decoded without decryption")
+	}
+
+	got := make(map[string]string)
+	if !s.CheckJSON(session, &got) || got["user"] != "h8liu" {
+		t.Errorf("check on encrypted session got %v", got)
+	}
+}
+
+func TestSessionsStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	kv := pisces.NewMemKV()
+	s := newConfigSessions(t, &SessionsConfig{
+		Store: kv,
+		TTL:   time.Hour,
+		Now:   func() time.Time { return now },
+	})
+
+	session := newSession(t, s, "data")
+	if bs, _, ok := s.Check(session); !ok || string(bs) != "data" {
+		t.Fatalf("check on session %q failed", session)
+	}
+	if err := s.Delete(session); err != nil {
+		t.Fatal("delete session: ", err)
+	}
+	if _, _, ok := s.Check(session); ok {
+		t.Error("deleted session is valid")
+	}
+
+	// Expired sessions are removed on checking.
+	session = newSession(t, s, "data")
+	now = now.Add(time.Hour)
+	if _, _, ok := s.Check(session); ok {
+		t.Error("expired session is valid")
+	}
+	if n, err := kv.Count(); err != nil {
+		t.Fatal("count sessions: ", err)
+	} else if n != 0 {
+		t.Errorf("got %d sessions in store, want 0", n)
+	}
+
+	if err := NewSessions(nil, time.Hour).Delete(session); err == nil {
+		t.Error("deleted a signed session")
+	}
+}
+
+func TestSessionsStoreClean(t *testing.T) {
+	now := time.Unix(0, 0)
+	kv := pisces.NewMemKV()
+	s := newConfigSessions(t, &SessionsConfig{
+		Store: kv,
+		TTL:   time.Hour,
+		Now:   func() time.Time { return now },
+	})
+
+	session := newSession(t, s, "data")
+	id, _, _ := strings.Cut(session, ".")
+	if _, _, ok := s.Check(id + ".wrong"); ok {
+		t.Error("session with a wrong secret is valid")
+	}
+
+	now = now.Add(time.Hour)
+	if err := s.Clean(); err != nil {
+		t.Fatal("clean: ", err)
+	}
+	if n, err := kv.Count(); err != nil {
+		t.Fatal("count sessions: ", err)
+	} else if n != 0 {
+		t.Errorf("got %d sessions after clean, want 0", n)
+	}
+
+	// Creating sessions after a TTL also cleans the expired ones.
+	newSession(t, s, "data")
+	now = now.Add(2 * time.Hour)
+	newSession(t, s, "data")
+	if n, err := kv.Count(); err != nil {
+		t.Fatal("count sessions: ", err)
+	} else if n != 1 {
+		t.Errorf("got %d sessions, want 1", n)
+	}
+}
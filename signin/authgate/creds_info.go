@@ -12,6 +12,8 @@ type CredsInfo struct {
 	TokenType string
 	User      string
 	UserLevel int
+	Scopes    []string // Scopes granted to the token.
+	MFA       bool     // If verified with a second factor.
 
 	Data any
 }
@@ -21,11 +23,13 @@ func ApplyCredsInfo(c *aries.C, info *CredsInfo) {
 	if !info.Valid {
 		c.User = ""
 		c.UserLevel = 0
+		c.Scopes = nil
 		return
 	}
 
 	c.User = info.User
 	c.UserLevel = info.UserLevel
+	c.Scopes = info.Scopes
 	if info.Data != nil {
 		c.UserData = info.Data
 	}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"shanhu.io/g/aries"
+	"shanhu.io/g/audit"
 	"shanhu.io/g/identity"
 	"shanhu.io/g/jwt"
 	"shanhu.io/g/signin"
@@ -18,7 +19,21 @@ type ExchangeConfig struct {
 	Audience string
 	Issuer   string
 	Card     identity.Card
-	Now      func() time.Time
+
+	// JWKSURL is the URL of the issuer's JSON web key set. It is used to
+	// verify tokens from standard identity providers when Card is nil.
+	JWKSURL string
+
+	// Scopes returns the permission scopes that a user has. Sessions only
+	// grant the scopes that the access token asks for and the user has, or
+	// all scopes of the user if the access token asks for none. When it is
+	// nil, sessions grant no scopes.
+	Scopes func(user string) ([]string, error)
+
+	// Optional audit log that records the exchanges.
+	Audit *audit.Log
+
+	Now func() time.Time
 }
 
 // Exchange exchanges an access tokens for a session token. An access token is
@@ -34,18 +49,29 @@ type Exchange struct {
 	card     identity.Card
 	verifier jwt.Verifier
 	tokener  signin.Tokener
+	scopes   func(user string) ([]string, error)
+	audit    *audit.Log
 	now      func() time.Time
 }
 
 // NewExchange creates an exchange that exchnages access tokens
 // for session tokens from tok.
 func NewExchange(tok signin.Tokener, config *ExchangeConfig) *Exchange {
+	card := config.Card
+	if card == nil && config.JWKSURL != "" {
+		card = identity.NewJWKSCard(&identity.JWKSCardConfig{
+			URL: config.JWKSURL,
+			Now: config.Now,
+		})
+	}
 	return &Exchange{
 		audience: config.Audience,
 		issuer:   config.Issuer,
-		card:     config.Card,
-		verifier: identity.NewJWTVerifier(config.Card),
+		card:     card,
+		verifier: identity.NewJWTVerifier(card),
 		tokener:  tok,
+		scopes:   config.Scopes,
+		audit:    config.Audit,
 		now:      timeutil.NowFunc(config.Now),
 	}
 }
@@ -54,6 +80,18 @@ func NewExchange(tok signin.Tokener, config *ExchangeConfig) *Exchange {
 // form of credentials.
 func (x *Exchange) Exchange(c *aries.C, req *signinapi.Request) (
 	*signinapi.Creds, error,
+) {
+	creds, err := x.exchange(c, req)
+	x.audit.Record(c, &audit.Event{
+		Actor:  req.User,
+		Action: audit.Exchange,
+		Target: req.User,
+	}, err)
+	return creds, err
+}
+
+func (x *Exchange) exchange(c *aries.C, req *signinapi.Request) (
+	*signinapi.Creds, error,
 ) {
 	if req.AccessToken == "" {
 		return nil, errcode.InvalidArgf("access token missing")
@@ -81,6 +119,35 @@ func (x *Exchange) Exchange(c *aries.C, req *signinapi.Request) (
 		return nil, errcode.Unauthorizedf("ttl too short")
 	}
 
-	token := x.tokener.Token(req.User, ttl)
+	scopes, err := x.grantScopes(req.User, tok.ClaimSet.Scopes())
+	if err != nil {
+		return nil, errcode.Annotate(err, "get scopes")
+	}
+	token, err := signin.IssueTokenErr(x.tokener, req.User, scopes, ttl)
+	if err != nil {
+		return nil, errcode.Annotate(err, "issue token")
+	}
 	return signin.TokenCreds(req.User, token), nil
 }
+
+// grantScopes returns the scopes that the session of user grants, out of
+// the scopes that the access token asks for.
+func (x *Exchange) grantScopes(user string, asked []string) ([]string, error) {
+	if x.scopes == nil {
+		return nil, nil
+	}
+	has, err := x.scopes(user)
+	if err != nil {
+		return nil, err
+	}
+	if len(asked) == 0 {
+		return has, nil
+	}
+	var ret []string
+	for _, s := range asked {
+		if aries.ScopeGranted(has, s) {
+			ret = append(ret, s)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,86 @@
+package authgate
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/identity"
+	"shanhu.io/g/jwt"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/g/timeutil"
+)
+
+func TestExchangeJWKS(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	nowFunc := func() time.Time { return now }
+
+	core := identity.NewMemCore(nowFunc)
+	config := identity.SingleKeyCoreConfig(now.Add(time.Hour))
+	if _, err := core.Init(config); err != nil {
+		t.Fatal("init core: ", err)
+	}
+	s := httptest.NewServer(aries.Serve(identity.NewService(core)))
+	defer s.Close()
+
+	const issuer = "https://id.example.com"
+	gate := New(&Config{SessionKey: []byte("test-key"), Now: nowFunc})
+	x := NewExchange(gate, &ExchangeConfig{
+		Audience: "app",
+		Issuer:   issuer,
+		JWKSURL:  s.URL + "/jwks.json",
+		Scopes: func(user string) ([]string, error) {
+			return []string{"ssh:*"}, nil
+		},
+		Now: nowFunc,
+	})
+
+	claims := &jwt.ClaimSet{
+		Iss:   issuer,
+		Aud:   "app",
+		Sub:   "h8liu",
+		Iat:   now.Unix(),
+		Exp:   now.Add(time.Hour).Unix(),
+		Scope: "ssh:login roles:write",
+	}
+	signer := identity.NewJWTSigner(core)
+	tok, err := jwt.EncodeAndSign(ctx, claims, signer)
+	if err != nil {
+		t.Fatal("sign token: ", err)
+	}
+
+	exchange := func(user string) (*signinapi.Creds, error) {
+		c := aries.NewContext(
+			httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil),
+		)
+		return x.Exchange(c, &signinapi.Request{
+			User:        user,
+			AccessToken: tok,
+			TTLDuration: timeutil.NewDuration(time.Hour),
+		})
+	}
+
+	creds, err := exchange("h8liu")
+	if err != nil {
+		t.Fatal("exchange: ", err)
+	}
+	info, err := gate.CheckToken(creds.Token, TokenBearer)
+	if err != nil {
+		t.Fatal("check token: ", err)
+	}
+	if !info.Valid {
+		t.Error("exchanged token is not valid")
+	}
+	// Only the asked scopes that the user has are granted.
+	if want := []string{"ssh:login"}; !reflect.DeepEqual(info.Scopes, want) {
+		t.Errorf("got scopes %q, want %q", info.Scopes, want)
+	}
+
+	if _, err := exchange("other"); err == nil {
+		t.Error("exchanged a token of another user")
+	}
+}
@@ -1,12 +1,14 @@
 package authgate
 
 import (
+	"log"
 	"time"
 
 	"shanhu.io/g/aries"
 	"shanhu.io/g/signer"
 	"shanhu.io/g/signin"
 	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
 )
 
 const cookieKey = "session"
@@ -27,6 +29,28 @@ type Config struct {
 	SessionLifeTime time.Duration
 
 	Check func(user string) (any, int, error)
+
+	// Optional revocation list that is checked for every session token.
+	Revocations *Revocations
+
+	// Optional refresh token store. When set, tokens issued with Token also
+	// carry refresh tokens.
+	RefreshTokens *RefreshTokens
+
+	// Optional function that checks if a user is required to present a
+	// second factor on signing in. When set, sessions of such users are
+	// only valid when they are set up with SetupMFACookie, and refresh
+	// tokens of such users are rejected.
+	MFARequired func(user string) (bool, error)
+
+	// Optional function that returns the scopes granted to the cookie
+	// sessions of a user, such as the scopes of the user's role. The scopes
+	// are looked up again when the cookie is refreshed. When nil, cookie
+	// sessions grant no scopes, and can not access routes that require a
+	// scope.
+	CookieScopes func(user string) ([]string, error)
+
+	Now func() time.Time
 }
 
 // Gate is a token checking gate that checks the session token and saves the
@@ -35,6 +59,12 @@ type Gate struct {
 	sessions *signer.Sessions
 
 	check func(user string) (any, int, error)
+
+	revocations  *Revocations
+	refresh      *RefreshTokens
+	mfaRequired  func(user string) (bool, error)
+	cookieScopes func(user string) ([]string, error)
+	now          func() time.Time
 }
 
 // New creates a new session token checking gate.
@@ -46,6 +76,7 @@ func New(config *Config) *Gate {
 			sessionLifeTime = timeutil.Week
 		}
 		sessions = signer.NewSessions(config.SessionKey, sessionLifeTime)
+		sessions.TimeFunc = config.Now
 	}
 
 	check := config.Check
@@ -54,8 +85,13 @@ func New(config *Config) *Gate {
 	}
 
 	return &Gate{
-		sessions: sessions,
-		check:    check,
+		sessions:     sessions,
+		check:        check,
+		revocations:  config.Revocations,
+		refresh:      config.RefreshTokens,
+		mfaRequired:  config.MFARequired,
+		cookieScopes: config.CookieScopes,
+		now:          timeutil.NowFunc(config.Now),
 	}
 }
 
@@ -81,7 +117,28 @@ func (g *Gate) CheckToken(token, typ string) (*CredsInfo, error) {
 	}
 	info.NeedRefresh = g.sessions.NeedRefresh(left)
 
-	user := string(bs)
+	data := decodeSessionData(bs)
+	user := data.user
+	if g.revocations != nil {
+		revoked, err := g.revocations.Revoked(user, token, data.issued)
+		if err != nil {
+			return nil, errcode.Annotate(err, "check revocation")
+		}
+		if revoked {
+			return info, nil
+		}
+	}
+
+	if !data.mfa {
+		required, err := g.MFARequired(user)
+		if err != nil {
+			return nil, errcode.Annotate(err, "check MFA")
+		}
+		if required {
+			return info, nil
+		}
+	}
+
 	dat, lvl, err := g.check(user)
 	if err != nil {
 		return nil, err
@@ -90,6 +147,8 @@ func (g *Gate) CheckToken(token, typ string) (*CredsInfo, error) {
 	info.UserLevel = lvl
 	info.Valid = lvl >= 0
 	info.Data = dat
+	info.Scopes = data.scopes
+	info.MFA = data.mfa
 
 	return info, nil
 }
@@ -101,20 +160,64 @@ func (g *Gate) Check(c *aries.C) (*CredsInfo, error) {
 	return g.CheckToken(authToken(c))
 }
 
-// Token returns an auth token that is valid for ttl. It returns the token
-// and the expiry time.
-func (g *Gate) Token(user string, ttl time.Duration) *signin.Token {
-	token, expire := g.sessions.New([]byte(user), ttl)
+// MFARequired checks if the user is required to present a second factor
+// on signing in. Sessions and tokens that are not verified with a second
+// factor are not valid for such users.
+func (g *Gate) MFARequired(user string) (bool, error) {
+	if g.mfaRequired == nil {
+		return false, nil
+	}
+	return g.mfaRequired(user)
+}
+
+func (g *Gate) sessionToken(
+	user string, scopes []string, ttl time.Duration,
+) (*signin.Token, error) {
+	return g.newSession(&sessionData{user: user, scopes: scopes}, ttl)
+}
+
+func (g *Gate) newSession(d *sessionData, ttl time.Duration) (
+	*signin.Token, error,
+) {
+	d.issued = g.now()
+	token, expire, err := g.sessions.NewSession(encodeSessionData(d), ttl)
+	if err != nil {
+		return nil, err
+	}
 	return &signin.Token{
 		Token:  token,
 		Expire: expire,
-	}
+	}, nil
 }
 
-// SetupCookie sets up the cookie for a particular user.
-func (g *Gate) SetupCookie(c *aries.C, user string) {
-	token := g.Token(user, 0)
+func (g *Gate) writeCookie(c *aries.C, d *sessionData) error {
+	if g.cookieScopes != nil {
+		scopes, err := g.cookieScopes(d.user)
+		if err != nil {
+			return errcode.Annotate(err, "get cookie scopes")
+		}
+		d.scopes = scopes
+	}
+	token, err := g.newSession(d, 0)
+	if err != nil {
+		return err
+	}
 	c.WriteCookie(cookieKey, token.Token, token.Expire)
+	return nil
+}
+
+// ClearSession clears the session cookie. When sessions are saved on the
+// server side, it also deletes the session, so that it is no longer valid.
+func (g *Gate) ClearSession(c *aries.C) error {
+	if session := c.ReadCookie(cookieKey); session != "" {
+		if g.sessions.ServerSide() {
+			if err := g.sessions.Delete(session); err != nil {
+				return errcode.Annotate(err, "delete session")
+			}
+		}
+	}
+	ClearCookie(c)
+	return nil
 }
 
 // ClearCookie clears the gate's session cookie.
@@ -138,7 +241,12 @@ func (g *Gate) CheckAndSetup(c *aries.C) (bool, error) {
 		if !creds.Valid {
 			ClearCookie(c)
 		} else if creds.NeedRefresh {
-			g.SetupCookie(c, creds.User)
+			// The current session is still valid, so failing to refresh it
+			// does not fail the request.
+			d := &sessionData{user: creds.User, mfa: creds.MFA}
+			if err := g.writeCookie(c, d); err != nil {
+				log.Printf("refresh session of %q: %s", creds.User, err)
+			}
 		}
 	}
 
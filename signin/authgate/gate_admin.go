@@ -0,0 +1,109 @@
+package authgate
+
+import (
+	"shanhu.io/g/aries"
+	"shanhu.io/g/signin"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// checkRefreshUser checks if the user can still refresh sessions.
+func (g *Gate) checkRefreshUser(user string) error {
+	_, lvl, err := g.check(user)
+	if err != nil {
+		return errcode.Annotate(err, "check user")
+	}
+	if lvl < 0 {
+		return errcode.Unauthorizedf("user not valid")
+	}
+	// Refresh tokens are not verified with a second factor.
+	required, err := g.MFARequired(user)
+	if err != nil {
+		return errcode.Annotate(err, "check MFA")
+	}
+	if required {
+		return errcode.Unauthorizedf("MFA required")
+	}
+	return nil
+}
+
+// Refresh is the API that exchanges a refresh token for a new session
+// token and a new refresh token.
+func (g *Gate) Refresh(c *aries.C, req *signinapi.RefreshRequest) (
+	*signinapi.Creds, error,
+) {
+	if g.refresh == nil {
+		return nil, errcode.NotFoundf("refresh token not supported")
+	}
+	if req.RefreshToken == "" {
+		return nil, errcode.InvalidArgf("refresh token missing")
+	}
+
+	// The user is checked before the token is used, so that a token is not
+	// consumed by a failed refresh.
+	res, err := g.refresh.use(req.RefreshToken, g.checkRefreshUser)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := timeutil.TimeDuration(req.TTLDuration)
+	tok, err := g.sessionToken(res.User, res.Scopes, ttl)
+	if err != nil {
+		return nil, err
+	}
+	tok.Refresh = res.Token
+	tok.RefreshExpire = res.Expire
+	return signin.TokenCreds(res.User, tok), nil
+}
+
+// RevokeUser revokes all sessions and refresh tokens of a user.
+func (g *Gate) RevokeUser(user string) error {
+	if g.revocations == nil {
+		return errcode.Internalf("revocation not supported")
+	}
+	if err := g.revocations.RevokeUser(user); err != nil {
+		return errcode.Annotate(err, "revoke sessions")
+	}
+	if g.refresh != nil {
+		if err := g.refresh.RevokeUser(user); err != nil {
+			return errcode.Annotate(err, "revoke refresh tokens")
+		}
+	}
+	return nil
+}
+
+// RevokeToken revokes a single session token.
+func (g *Gate) RevokeToken(tok string) error {
+	if g.revocations == nil {
+		return errcode.Internalf("revocation not supported")
+	}
+	return g.revocations.RevokeToken(tok)
+}
+
+func (g *Gate) apiRevokeUser(
+	c *aries.C, req *signinapi.RevokeUserRequest,
+) error {
+	if req.User == "" {
+		return errcode.InvalidArgf("user missing")
+	}
+	return g.RevokeUser(req.User)
+}
+
+func (g *Gate) apiRevokeToken(
+	c *aries.C, req *signinapi.RevokeTokenRequest,
+) error {
+	if req.Token == "" {
+		return errcode.InvalidArgf("token missing")
+	}
+	return g.RevokeToken(req.Token)
+}
+
+// AdminAPI returns the API router for revoking sessions. It should only be
+// mounted where admins can access.
+func (g *Gate) AdminAPI() *aries.Router {
+	r := aries.NewRouter()
+	r.Call("revoke-user", g.apiRevokeUser)
+	r.Call("revoke-token", g.apiRevokeToken)
+	return r
+}
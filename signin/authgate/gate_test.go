@@ -0,0 +1,243 @@
+package authgate
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/signin"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/std/errcode"
+)
+
+func newTestGate(now func() time.Time) *Gate {
+	return New(&Config{
+		SessionKey: []byte("test-key"),
+		Revocations: NewRevocations(pisces.NewMemKV(), &RevocationsConfig{
+			Now: now,
+		}),
+		RefreshTokens: NewRefreshTokens(pisces.NewMemKV(), &RefreshTokensConfig{
+			Now: now,
+		}),
+		Now: now,
+	})
+}
+
+func newToken(t *testing.T, g *Gate, user string) *signin.Token {
+	t.Helper()
+	tok, err := g.TokenErr(user, time.Hour)
+	if err != nil {
+		t.Fatal("issue token: ", err)
+	}
+	return tok
+}
+
+func checkValid(t *testing.T, g *Gate, tok string, want bool) {
+	t.Helper()
+	info, err := g.CheckToken(tok, TokenBearer)
+	if err != nil {
+		t.Fatal("check token: ", err)
+	}
+	if info.Valid != want {
+		t.Errorf("token valid is %t, want %t", info.Valid, want)
+	}
+}
+
+func TestGateRevokeUser(t *testing.T) {
+	now := time.Now()
+	nowFunc := func() time.Time { return now }
+	g := newTestGate(nowFunc)
+
+	const user = "h8liu"
+	tok := newToken(t, g, user)
+	other := newToken(t, g, "other")
+	checkValid(t, g, tok.Token, true)
+
+	if err := g.RevokeUser(user); err != nil {
+		t.Fatal("revoke user: ", err)
+	}
+	checkValid(t, g, tok.Token, false)
+	checkValid(t, g, other.Token, true)
+
+	// Refresh tokens are revoked too.
+	req := &signinapi.RefreshRequest{RefreshToken: tok.Refresh}
+	if _, err := g.Refresh(new(aries.C), req); err == nil {
+		t.Error("refresh succeeded after user is revoked")
+	}
+
+	// Sessions issued after the revoke are valid.
+	now = now.Add(time.Second)
+	checkValid(t, g, newToken(t, g, user).Token, true)
+
+	// Legacy sessions without issuing time are revoked.
+	legacy, _, err := g.sessions.NewSession([]byte(user), time.Hour)
+	if err != nil {
+		t.Fatal("new legacy session: ", err)
+	}
+	checkValid(t, g, legacy, false)
+}
+
+func TestGateRevokeToken(t *testing.T) {
+	g := newTestGate(nil)
+	tok1 := newToken(t, g, "h8liu")
+	tok2 := newToken(t, g, "h8liu")
+	if err := g.RevokeToken(tok1.Token); err != nil {
+		t.Fatal("revoke token: ", err)
+	}
+	checkValid(t, g, tok1.Token, false)
+	checkValid(t, g, tok2.Token, true)
+}
+
+func TestGateRefresh(t *testing.T) {
+	g := newTestGate(nil)
+	c := new(aries.C)
+
+	tok := newToken(t, g, "h8liu")
+	if tok.Refresh == "" {
+		t.Fatal("refresh token missing")
+	}
+
+	creds, err := g.Refresh(c, &signinapi.RefreshRequest{
+		RefreshToken: tok.Refresh,
+	})
+	if err != nil {
+		t.Fatal("refresh: ", err)
+	}
+	if creds.User != "h8liu" {
+		t.Errorf("got user %q, want h8liu", creds.User)
+	}
+	checkValid(t, g, creds.Token, true)
+	if creds.RefreshToken == "" || creds.RefreshToken == tok.Refresh {
+		t.Fatal("refresh token not rotated")
+	}
+
+	// Reusing the old refresh token fails, and revokes the rotated one.
+	_, err = g.Refresh(c, &signinapi.RefreshRequest{
+		RefreshToken: tok.Refresh,
+	})
+	if !errcode.IsUnauthorized(err) {
+		t.Errorf("reuse refresh token got %v, want unauthorized", err)
+	}
+	_, err = g.Refresh(c, &signinapi.RefreshRequest{
+		RefreshToken: creds.RefreshToken,
+	})
+	if !errcode.IsUnauthorized(err) {
+		t.Errorf("use revoked refresh token got %v, want unauthorized", err)
+	}
+}
+
+func TestGateScopedToken(t *testing.T) {
+	g := newTestGate(nil)
+	scopes := []string{"roles:read", "ssh:*"}
+	tok, err := g.ScopedTokenErr("h8liu", scopes, time.Hour)
+	if err != nil {
+		t.Fatal("issue token: ", err)
+	}
+
+	info, err := g.CheckToken(tok.Token, TokenBearer)
+	if err != nil {
+		t.Fatal("check token: ", err)
+	}
+	if !reflect.DeepEqual(info.Scopes, scopes) {
+		t.Errorf("got scopes %q, want %q", info.Scopes, scopes)
+	}
+
+	c := new(aries.C)
+	ApplyCredsInfo(c, info)
+	if !c.HasScope("ssh:sign") || c.HasScope("roles:write") {
+		t.Errorf("unexpected scope check result on %q", c.Scopes)
+	}
+
+	// Refreshed sessions keep the scopes.
+	creds, err := g.Refresh(c, &signinapi.RefreshRequest{
+		RefreshToken: tok.Refresh,
+	})
+	if err != nil {
+		t.Fatal("refresh: ", err)
+	}
+	info, err = g.CheckToken(creds.Token, TokenBearer)
+	if err != nil {
+		t.Fatal("check refreshed token: ", err)
+	}
+	if !reflect.DeepEqual(info.Scopes, scopes) {
+		t.Errorf("refreshed token got scopes %q, want %q", info.Scopes, scopes)
+	}
+}
+
+func TestGateMFARequired(t *testing.T) {
+	aliceMFA := true
+	g := New(&Config{
+		SessionKey: []byte("test-key"),
+		RefreshTokens: NewRefreshTokens(
+			pisces.NewMemKV(), &RefreshTokensConfig{},
+		),
+		MFARequired: func(user string) (bool, error) {
+			return user == "alice" && aliceMFA, nil
+		},
+	})
+
+	tok := newToken(t, g, "alice")
+	checkValid(t, g, tok.Token, false)
+	checkValid(t, g, newToken(t, g, "bob").Token, true)
+
+	_, err := g.Refresh(new(aries.C), &signinapi.RefreshRequest{
+		RefreshToken: tok.Refresh,
+	})
+	if !errcode.IsUnauthorized(err) {
+		t.Errorf("refresh got %v, want unauthorized", err)
+	}
+
+	// The failed refresh does not use the refresh token.
+	aliceMFA = false
+	if _, err := g.Refresh(new(aries.C), &signinapi.RefreshRequest{
+		RefreshToken: tok.Refresh,
+	}); err != nil {
+		t.Error("refresh after MFA is no longer required: ", err)
+	}
+	aliceMFA = true
+
+	w := httptest.NewRecorder()
+	c := aries.NewContext(w, httptest.NewRequest("GET", "/", nil))
+	g.SetupMFACookie(c, "alice")
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	info, err := g.CheckToken(cookies[0].Value, TokenCookie)
+	if err != nil {
+		t.Fatal("check cookie: ", err)
+	}
+	if !info.Valid || !info.MFA {
+		t.Errorf("MFA session got valid %t, MFA %t", info.Valid, info.MFA)
+	}
+}
+
+func TestGateCookieScopes(t *testing.T) {
+	scopes := []string{"roles:read"}
+	g := New(&Config{
+		SessionKey: []byte("test-key"),
+		CookieScopes: func(user string) ([]string, error) {
+			return scopes, nil
+		},
+	})
+
+	w := httptest.NewRecorder()
+	c := aries.NewContext(w, httptest.NewRequest("GET", "/", nil))
+	if err := g.SetupCookieErr(c, "h8liu"); err != nil {
+		t.Fatal("setup cookie: ", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	info, err := g.CheckToken(cookies[0].Value, TokenCookie)
+	if err != nil {
+		t.Fatal("check cookie: ", err)
+	}
+	if !reflect.DeepEqual(info.Scopes, scopes) {
+		t.Errorf("got scopes %q, want %q", info.Scopes, scopes)
+	}
+}
@@ -0,0 +1,93 @@
+package authgate
+
+import (
+	"log"
+	"time"
+
+	"shanhu.io/g/aries"
+	"shanhu.io/g/signin"
+	"shanhu.io/std/errcode"
+)
+
+// Token returns an auth token that is valid for ttl. It returns the token
+// and the expiry time. If the gate has a refresh token store, the token
+// also carries a refresh token. It returns nil when the token cannot be
+// issued. Use TokenErr to get the error.
+func (g *Gate) Token(user string, ttl time.Duration) *signin.Token {
+	return g.ScopedToken(user, nil, ttl)
+}
+
+// TokenErr is like Token, but returns an error when the token cannot be
+// issued.
+func (g *Gate) TokenErr(user string, ttl time.Duration) (
+	*signin.Token, error,
+) {
+	return g.ScopedTokenErr(user, nil, ttl)
+}
+
+// ScopedToken returns an auth token that is valid for ttl, and only grants
+// the given scopes. When scopes is empty, the token grants no scopes. It
+// returns nil when the token cannot be issued. Use ScopedTokenErr to get the
+// error.
+func (g *Gate) ScopedToken(
+	user string, scopes []string, ttl time.Duration,
+) *signin.Token {
+	tok, err := g.ScopedTokenErr(user, scopes, ttl)
+	if err != nil {
+		log.Printf("issue token for %q: %s", user, err)
+		return nil
+	}
+	return tok
+}
+
+// ScopedTokenErr is like ScopedToken, but returns an error when the token
+// cannot be issued.
+func (g *Gate) ScopedTokenErr(
+	user string, scopes []string, ttl time.Duration,
+) (*signin.Token, error) {
+	tok, err := g.sessionToken(user, scopes, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if g.refresh != nil {
+		refresh, expire, err := g.refresh.IssueScoped(user, scopes)
+		if err != nil {
+			return nil, errcode.Annotate(err, "issue refresh token")
+		}
+		tok.Refresh = refresh
+		tok.RefreshExpire = expire
+	}
+	return tok, nil
+}
+
+// SetupCookie sets up the cookie for a particular user. The session is not
+// valid if the user is required to present a second factor. The session
+// grants the scopes returned by the CookieScopes function of the config.
+// The cookie is not set when the session cannot be created. Use
+// SetupCookieErr to get the error.
+func (g *Gate) SetupCookie(c *aries.C, user string) {
+	if err := g.SetupCookieErr(c, user); err != nil {
+		log.Printf("setup cookie for %q: %s", user, err)
+	}
+}
+
+// SetupCookieErr is like SetupCookie, but returns an error when the session
+// cannot be created.
+func (g *Gate) SetupCookieErr(c *aries.C, user string) error {
+	return g.writeCookie(c, &sessionData{user: user})
+}
+
+// SetupMFACookie sets up the cookie for a user that has presented a second
+// factor. The cookie is not set when the session cannot be created. Use
+// SetupMFACookieErr to get the error.
+func (g *Gate) SetupMFACookie(c *aries.C, user string) {
+	if err := g.SetupMFACookieErr(c, user); err != nil {
+		log.Printf("setup MFA cookie for %q: %s", user, err)
+	}
+}
+
+// SetupMFACookieErr is like SetupMFACookie, but returns an error when the
+// session cannot be created.
+func (g *Gate) SetupMFACookieErr(c *aries.C, user string) error {
+	return g.writeCookie(c, &sessionData{user: user, mfa: true})
+}
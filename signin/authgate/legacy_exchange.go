@@ -56,6 +56,9 @@ func (x *LegacyExchange) Exchange(c *aries.C, req *signinapi.Request) (
 	}
 
 	ttl := req.GetTTL()
-	token := x.tokener.Token(req.User, ttl)
+	token, err := signin.IssueTokenErr(x.tokener, req.User, nil, ttl)
+	if err != nil {
+		return nil, errcode.Annotate(err, "issue token")
+	}
 	return signin.TokenCreds(req.User, token), nil
 }
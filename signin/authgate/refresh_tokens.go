@@ -0,0 +1,260 @@
+package authgate
+
+import (
+	"encoding/base64"
+	"log"
+	"time"
+
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/rand"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+type refreshEntry struct {
+	Hash   string // Hash of the token, which is also the key.
+	User   string
+	Family string // All tokens rotated from the same sign in.
+	Expire int64
+	Used   bool `json:",omitempty"`
+
+	Scopes []string `json:",omitempty"` // Scopes granted to the session.
+}
+
+// RefreshTokensConfig is the configuration for creating a refresh token
+// store.
+type RefreshTokensConfig struct {
+	// TTL is the life time of a refresh token. Default is 30 days.
+	TTL time.Duration
+
+	Now func() time.Time
+}
+
+// RefreshTokens is a store of refresh tokens. Refresh tokens are long-lived
+// and rotate on every use: using a refresh token invalidates it and issues a
+// new one. Only hashes of the tokens are saved. When a used refresh token is
+// presented again, the token might have been stolen, and all tokens rotated
+// from the same sign in are revoked.
+type RefreshTokens struct {
+	kv  *pisces.KV
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewRefreshTokens creates a new refresh token store that saves the tokens
+// in kv.
+func NewRefreshTokens(
+	kv *pisces.KV, config *RefreshTokensConfig,
+) *RefreshTokens {
+	if config == nil {
+		config = new(RefreshTokensConfig)
+	}
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = 30 * timeutil.Day
+	}
+	return &RefreshTokens{
+		kv:  kv,
+		ttl: ttl,
+		now: timeutil.NowFunc(config.Now),
+	}
+}
+
+func newRefreshToken() string {
+	return base64.RawURLEncoding.EncodeToString(rand.Bytes(32))
+}
+
+func (r *RefreshTokens) issue(user, family string, scopes []string) (
+	string, time.Time, error,
+) {
+	tok := newRefreshToken()
+	if family == "" {
+		family = rand.HexBytes(16)
+	}
+	expire := r.now().Add(r.ttl)
+	k := tokenHash(tok)
+	entry := &refreshEntry{
+		Hash:   k,
+		User:   user,
+		Family: family,
+		Expire: expire.UnixNano(),
+		Scopes: scopes,
+	}
+	// Entries are classed by user, so that they can be revoked by user.
+	if err := r.kv.AddClass(k, user, entry); err != nil {
+		return "", time.Time{}, errcode.Annotate(err, "save refresh token")
+	}
+	return tok, expire, nil
+}
+
+// Issue issues a new refresh token for the user. It returns the token and
+// its expire time.
+func (r *RefreshTokens) Issue(user string) (string, time.Time, error) {
+	return r.issue(user, "", nil)
+}
+
+// IssueScoped issues a new refresh token for the user, which only refreshes
+// sessions with the given scopes.
+func (r *RefreshTokens) IssueScoped(user string, scopes []string) (
+	string, time.Time, error,
+) {
+	return r.issue(user, "", scopes)
+}
+
+// Use uses a refresh token. It returns the user of the token, and a new
+// refresh token that replaces the used one.
+func (r *RefreshTokens) Use(tok string) (*RefreshResult, error) {
+	return r.use(tok, nil)
+}
+
+// reuse revokes all the tokens rotated from the same sign in as a reused
+// token.
+func (r *RefreshTokens) reuse(entry *refreshEntry) error {
+	if err := r.revokeFamily(entry.User, entry.Family); err != nil {
+		return errcode.Annotate(err, "revoke reused tokens")
+	}
+	return errcode.Unauthorizedf("refresh token reused")
+}
+
+// use is like Use, but when check is not nil, it checks the user of the
+// token before using it. A token that fails the check is not used, and
+// stays valid.
+func (r *RefreshTokens) use(tok string, check func(user string) error) (
+	*RefreshResult, error,
+) {
+	k := tokenHash(tok)
+	entry := new(refreshEntry)
+	if err := r.kv.Get(k, entry); err != nil {
+		if errcode.IsNotFound(err) {
+			return nil, errcode.Unauthorizedf("invalid refresh token")
+		}
+		return nil, errcode.Annotate(err, "read refresh token")
+	}
+	if entry.Used {
+		return nil, r.reuse(entry)
+	}
+	if !r.now().Before(time.Unix(0, entry.Expire)) {
+		return nil, errcode.Unauthorizedf("refresh token expired")
+	}
+	if check != nil {
+		if err := check(entry.User); err != nil {
+			return nil, err
+		}
+	}
+
+	var reused bool
+	if err := r.kv.Mutate(k, new(refreshEntry), func(v any) error {
+		e := v.(*refreshEntry)
+		if e.Used {
+			reused = true
+			return pisces.ErrCancel
+		}
+		e.Used = true
+		return nil
+	}); err != nil {
+		return nil, errcode.Annotate(err, "mark refresh token used")
+	}
+	if reused {
+		return nil, r.reuse(entry)
+	}
+
+	next, expire, err := r.issue(entry.User, entry.Family, entry.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	// The new token is issued, so failing to clean does not fail the use.
+	if err := r.CleanUser(entry.User); err != nil {
+		log.Printf("clean refresh tokens of %q: %s", entry.User, err)
+	}
+	return &RefreshResult{
+		User:   entry.User,
+		Token:  next,
+		Expire: expire,
+		Scopes: entry.Scopes,
+	}, nil
+}
+
+// RefreshResult is the result of using a refresh token.
+type RefreshResult struct {
+	User   string
+	Token  string // The new refresh token.
+	Expire time.Time
+	Scopes []string // Scopes of the session, nil for unscoped.
+}
+
+func (r *RefreshTokens) userEntries(user string) ([]*refreshEntry, error) {
+	var entries []*refreshEntry
+	it := &pisces.Iter{
+		Make: func() any { return new(refreshEntry) },
+		Do: func(_ string, v any) error {
+			entries = append(entries, v.(*refreshEntry))
+			return nil
+		},
+	}
+	if err := r.kv.WalkClass(user, it); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *RefreshTokens) remove(entries []*refreshEntry) error {
+	for _, e := range entries {
+		err := r.kv.Remove(e.Hash)
+		if err != nil && !errcode.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RefreshTokens) revokeMatch(
+	user string, match func(e *refreshEntry) bool,
+) error {
+	entries, err := r.userEntries(user)
+	if err != nil {
+		return err
+	}
+	var matched []*refreshEntry
+	for _, e := range entries {
+		if match(e) {
+			matched = append(matched, e)
+		}
+	}
+	return r.remove(matched)
+}
+
+func (r *RefreshTokens) revokeFamily(user, family string) error {
+	return r.revokeMatch(user, func(e *refreshEntry) bool {
+		return e.Family == family
+	})
+}
+
+// RevokeUser revokes all refresh tokens of the user.
+func (r *RefreshTokens) RevokeUser(user string) error {
+	return r.revokeMatch(user, func(*refreshEntry) bool { return true })
+}
+
+// CleanUser removes the refresh tokens of the user that are expired, and
+// the used ones that are rotated from a sign in which has no valid token
+// left. Used tokens of other sign ins are kept for detecting reuses. It is
+// called on every rotation.
+func (r *RefreshTokens) CleanUser(user string) error {
+	entries, err := r.userEntries(user)
+	if err != nil {
+		return err
+	}
+	now := r.now().UnixNano()
+	alive := make(map[string]bool) // families that have a valid token
+	for _, e := range entries {
+		if !e.Used && e.Expire > now {
+			alive[e.Family] = true
+		}
+	}
+	var dead []*refreshEntry
+	for _, e := range entries {
+		if e.Expire <= now || (e.Used && !alive[e.Family]) {
+			dead = append(dead, e)
+		}
+	}
+	return r.remove(dead)
+}
@@ -0,0 +1,85 @@
+package authgate
+
+import (
+	"testing"
+	"time"
+
+	"shanhu.io/g/pisces"
+	"shanhu.io/std/errcode"
+)
+
+func TestRefreshTokensClean(t *testing.T) {
+	now := time.Now()
+	kv := pisces.NewMemKV()
+	r := NewRefreshTokens(kv, &RefreshTokensConfig{
+		TTL: time.Hour,
+		Now: func() time.Time { return now },
+	})
+
+	checkCount := func(want int64) {
+		t.Helper()
+		n, err := kv.Count()
+		if err != nil {
+			t.Fatal("count refresh tokens: ", err)
+		}
+		if n != want {
+			t.Errorf("got %d refresh tokens, want %d", n, want)
+		}
+	}
+	issue := func() string {
+		t.Helper()
+		tok, _, err := r.Issue("h8liu")
+		if err != nil {
+			t.Fatal("issue refresh token: ", err)
+		}
+		return tok
+	}
+	use := func(tok string) {
+		t.Helper()
+		if _, err := r.Use(tok); err != nil {
+			t.Fatal("use refresh token: ", err)
+		}
+	}
+
+	use(issue())
+	issue()
+	checkCount(3) // The used token is kept for detecting reuses.
+
+	// Rotating removes the expired tokens, and keeps the used token of a
+	// sign in that still has a valid token.
+	now = now.Add(2 * time.Hour)
+	tok := issue()
+	use(tok)
+	checkCount(2)
+
+	if _, err := r.Use(tok); !errcode.IsUnauthorized(err) {
+		t.Errorf("reuse refresh token got %v, want unauthorized", err)
+	}
+	checkCount(0)
+}
+
+func TestRefreshTokensConcurrentReuse(t *testing.T) {
+	r := NewRefreshTokens(pisces.NewMemKV(), nil)
+	tok, _, err := r.Issue("h8liu")
+	if err != nil {
+		t.Fatal("issue: ", err)
+	}
+
+	// The token is used by another request after it is read, and before
+	// it is marked as used.
+	var other *RefreshResult
+	_, err = r.use(tok, func(string) error {
+		res, err := r.Use(tok)
+		if err != nil {
+			t.Fatal("use in another request: ", err)
+		}
+		other = res
+		return nil
+	})
+	if !errcode.IsUnauthorized(err) {
+		t.Errorf("concurrent reuse got %v, want unauthorized", err)
+	}
+	if _, err := r.Use(other.Token); !errcode.IsUnauthorized(err) {
+		t.Errorf("use token of revoked family got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package authgate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+func tokenHash(tok string) string {
+	h := sha256.Sum256([]byte(tok))
+	return hex.EncodeToString(h[:])
+}
+
+type revocation struct {
+	// Time is the revoking time. For a user, all sessions issued before
+	// this time are revoked. For a token, it is just for recording.
+	Time int64
+}
+
+type revokeCacheEntry struct {
+	r      *revocation // nil for not revoked.
+	expire time.Time
+}
+
+// RevocationsConfig is the configuration for creating a revocation list.
+type RevocationsConfig struct {
+	// CacheTTL is how long a looked-up entry is cached in memory. Default
+	// is 30 seconds. Revocations made by other server instances that share
+	// the same storage take at most this long to take effect.
+	CacheTTL time.Duration
+
+	// MaxCache is the soft limit of the number of cache entries. Default is
+	// 10000.
+	MaxCache int
+
+	Now func() time.Time
+}
+
+// Revocations is a list of revoked session tokens and users, saved in a
+// key-value store, with an in-memory cache in front.
+type Revocations struct {
+	kv       *pisces.KV
+	cacheTTL time.Duration
+	maxCache int
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]*revokeCacheEntry
+}
+
+// NewRevocations creates a revocation list that saves entries in kv.
+func NewRevocations(kv *pisces.KV, config *RevocationsConfig) *Revocations {
+	if config == nil {
+		config = new(RevocationsConfig)
+	}
+	ttl := config.CacheTTL
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	maxCache := config.MaxCache
+	if maxCache <= 0 {
+		maxCache = 10000
+	}
+	return &Revocations{
+		kv:       kv,
+		cacheTTL: ttl,
+		maxCache: maxCache,
+		now:      timeutil.NowFunc(config.Now),
+		cache:    make(map[string]*revokeCacheEntry),
+	}
+}
+
+func revokeUserKey(user string) string { return "u/" + user }
+
+func revokeTokenKey(tok string) string { return "t/" + tokenHash(tok) }
+
+func (r *Revocations) putCache(k string, v *revocation, now time.Time) {
+	// Must hold the lock.
+	if len(r.cache) >= r.maxCache {
+		for k, entry := range r.cache {
+			if now.After(entry.expire) {
+				delete(r.cache, k)
+			}
+		}
+		if len(r.cache) >= r.maxCache {
+			r.cache = make(map[string]*revokeCacheEntry)
+		}
+	}
+	r.cache[k] = &revokeCacheEntry{r: v, expire: now.Add(r.cacheTTL)}
+}
+
+func (r *Revocations) get(k string) (*revocation, error) {
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.cache[k]; ok && now.Before(entry.expire) {
+		return entry.r, nil
+	}
+
+	v := new(revocation)
+	if err := r.kv.Get(k, v); err != nil {
+		if !errcode.IsNotFound(err) {
+			return nil, err
+		}
+		v = nil
+	}
+	r.putCache(k, v, now)
+	return v, nil
+}
+
+func (r *Revocations) put(k string, v *revocation) error {
+	if err := r.kv.Replace(k, v); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.putCache(k, v, r.now())
+	return nil
+}
+
+// RevokeUser revokes all sessions of the user that are issued up to now.
+func (r *Revocations) RevokeUser(user string) error {
+	now := r.now()
+	return r.put(revokeUserKey(user), &revocation{Time: now.UnixNano()})
+}
+
+// RevokeToken revokes a single session token.
+func (r *Revocations) RevokeToken(tok string) error {
+	v := &revocation{Time: r.now().UnixNano()}
+	return r.put(revokeTokenKey(tok), v)
+}
+
+// Revoked checks if a session token of the user that is issued at the
+// given time is revoked. A zero issued time means unknown, and the token is
+// revoked if the user has ever been revoked.
+func (r *Revocations) Revoked(user, tok string, issued time.Time) (
+	bool, error,
+) {
+	u, err := r.get(revokeUserKey(user))
+	if err != nil {
+		return false, errcode.Annotate(err, "check user")
+	}
+	if u != nil && (issued.IsZero() || issued.UnixNano() <= u.Time) {
+		return true, nil
+	}
+
+	t, err := r.get(revokeTokenKey(tok))
+	if err != nil {
+		return false, errcode.Annotate(err, "check token")
+	}
+	return t != nil, nil
+}
@@ -0,0 +1,56 @@
+package authgate
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type sessionData struct {
+	user   string
+	issued time.Time // Zero for unknown.
+	scopes []string
+	mfa    bool // If the user has presented a second factor.
+}
+
+const mfaFlag = "mfa"
+
+// encodeSessionData encodes the session data, which is the user name,
+// followed by a zero byte and the issuing time in decimal Unix nanoseconds,
+// and optionally another zero byte and the space-delimited scopes. Sessions
+// that are verified with a second factor end with another zero byte and
+// "mfa". Legacy sessions only have the user name.
+func encodeSessionData(d *sessionData) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(d.user)
+	buf.WriteByte(0)
+	buf.WriteString(strconv.FormatInt(d.issued.UnixNano(), 10))
+	if len(d.scopes) > 0 || d.mfa {
+		buf.WriteByte(0)
+		buf.WriteString(strings.Join(d.scopes, " "))
+	}
+	if d.mfa {
+		buf.WriteByte(0)
+		buf.WriteString(mfaFlag)
+	}
+	return buf.Bytes()
+}
+
+func decodeSessionData(bs []byte) *sessionData {
+	parts := bytes.SplitN(bs, []byte{0}, 4)
+	d := &sessionData{user: string(parts[0])}
+	if len(parts) < 2 {
+		return d
+	}
+	if ns, err := strconv.ParseInt(string(parts[1]), 10, 64); err == nil {
+		d.issued = time.Unix(0, ns)
+	}
+	if len(parts) >= 3 {
+		d.scopes = strings.Fields(string(parts[2]))
+	}
+	if len(parts) == 4 {
+		d.mfa = string(parts[3]) == mfaFlag
+	}
+	return d
+}
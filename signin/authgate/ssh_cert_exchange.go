@@ -142,7 +142,10 @@ func (s *SSHCertExchange) apiSignIn(
 
 	// Get a token.
 	ttl := timeutil.TimeDuration(record.TTL)
-	token := s.tokener.Token(user, ttl)
+	token, err := signin.IssueTokenErr(s.tokener, user, nil, ttl)
+	if err != nil {
+		return nil, errcode.Annotate(err, "issue token")
+	}
 	return signin.TokenCreds(user, token), nil
 }
 
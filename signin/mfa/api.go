@@ -0,0 +1,52 @@
+package mfa
+
+import (
+	"shanhu.io/g/aries"
+	"shanhu.io/g/signin/signinapi"
+)
+
+func (m *MFA) apiStatus(c *aries.C) (*signinapi.MFAStatus, error) {
+	return m.Status(c.User)
+}
+
+func (m *MFA) apiEnroll(c *aries.C, req *signinapi.MFACodeRequest) (
+	*signinapi.MFAEnrollment, error,
+) {
+	return m.StartEnroll(c.User, req.Code)
+}
+
+func (m *MFA) apiFinishEnroll(c *aries.C, req *signinapi.MFACodeRequest) (
+	*signinapi.MFARecoveryCodes, error,
+) {
+	codes, err := m.FinishEnroll(c.User, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return &signinapi.MFARecoveryCodes{Codes: codes}, nil
+}
+
+func (m *MFA) apiRecoveryCodes(c *aries.C, req *signinapi.MFACodeRequest) (
+	*signinapi.MFARecoveryCodes, error,
+) {
+	codes, err := m.NewRecoveryCodes(c.User, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	return &signinapi.MFARecoveryCodes{Codes: codes}, nil
+}
+
+// API returns the API for signed-in users to enroll their authenticator
+// apps and renew their recovery codes.
+func (m *MFA) API() aries.Func {
+	r := aries.NewRouter()
+	r.Call("status", m.apiStatus)
+	r.Call("enroll", m.apiEnroll)
+	r.Call("finish-enroll", m.apiFinishEnroll)
+	r.Call("recovery-codes", m.apiRecoveryCodes)
+	return func(c *aries.C) error {
+		if c.User == "" {
+			return aries.NeedSignIn
+		}
+		return r.Serve(c)
+	}
+}
@@ -0,0 +1,272 @@
+// Package mfa provides multi-factor authentication with TOTP codes and
+// one-time recovery codes.
+package mfa
+
+import (
+	"time"
+
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+type userMFA struct {
+	// Required is the per-user flag that requires the second factor on
+	// signing in.
+	Required bool `json:",omitempty"`
+
+	Secret   []byte          `json:",omitempty"` // Enrolled TOTP secret.
+	Pending  []byte          `json:",omitempty"` // Secret being enrolled.
+	LastStep int64           `json:",omitempty"` // Last used TOTP step.
+	Recovery []*recoveryCode `json:",omitempty"`
+
+	Failures  int   `json:",omitempty"` // Consecutive failed attempts.
+	WaitUntil int64 `json:",omitempty"` // Unix nanoseconds.
+}
+
+// Failed attempts are throttled with an exponential backoff, so that codes
+// cannot be guessed by brute force. The first freeFailures consecutive
+// failures are not throttled; after that, every failure doubles the wait
+// before the next attempt, from minBackoff up to maxBackoff. Attempts
+// during the wait fail without being counted. There is no hard lock, so
+// that others who know a user's password cannot lock the user out; the
+// user can still pass by retrying after at most maxBackoff.
+const (
+	freeFailures = 3
+	minBackoff   = time.Second
+	maxBackoff   = time.Minute
+)
+
+func backoff(failures int) time.Duration {
+	if failures <= freeFailures {
+		return 0
+	}
+	n := failures - freeFailures - 1
+	if n >= 16 {
+		return maxBackoff
+	}
+	return min(minBackoff<<n, maxBackoff)
+}
+
+// check checks a TOTP code or a recovery code of an enrolled user, and
+// updates the failure count and the backoff.
+func (u *userMFA) check(code string, now time.Time) error {
+	if u.Secret == nil {
+		return errcode.Unauthorizedf("not enrolled")
+	}
+	if now.UnixNano() < u.WaitUntil {
+		return errcode.Unauthorizedf("too many failed attempts, retry later")
+	}
+	if step := CheckTOTP(u.Secret, code, now); step > u.LastStep {
+		u.LastStep = step
+	} else if !useRecoveryCode(u.Recovery, code) {
+		u.Failures++
+		u.WaitUntil = now.Add(backoff(u.Failures)).UnixNano()
+		return errcode.Unauthorizedf("wrong code")
+	}
+	u.Failures = 0
+	u.WaitUntil = 0
+	return nil
+}
+
+// Config is the configuration for creating an MFA store.
+type Config struct {
+	// Issuer is the name shown in authenticator apps.
+	Issuer string
+
+	// RecoveryCodes is the number of recovery codes to create on
+	// enrolling. Default is 10.
+	RecoveryCodes int
+
+	Now func() time.Time
+}
+
+// MFA saves the second factors of users, and verifies MFA codes.
+type MFA struct {
+	kv            *pisces.KV
+	issuer        string
+	recoveryCodes int
+	now           func() time.Time
+}
+
+// New creates a new MFA store that saves the users' factors in kv.
+func New(kv *pisces.KV, config *Config) *MFA {
+	if config == nil {
+		config = new(Config)
+	}
+	n := config.RecoveryCodes
+	if n <= 0 {
+		n = 10
+	}
+	return &MFA{
+		kv:            kv,
+		issuer:        config.Issuer,
+		recoveryCodes: n,
+		now:           timeutil.NowFunc(config.Now),
+	}
+}
+
+func (m *MFA) get(user string) (*userMFA, error) {
+	u := new(userMFA)
+	if err := m.kv.Get(user, u); err != nil {
+		if errcode.IsNotFound(err) {
+			return u, nil
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func (m *MFA) mutate(user string, f func(u *userMFA) error) error {
+	if err := m.kv.Emplace(user, new(userMFA)); err != nil {
+		return err
+	}
+	return m.kv.Mutate(user, new(userMFA), func(v any) error {
+		return f(v.(*userMFA))
+	})
+}
+
+// Status returns the MFA status of the user.
+func (m *MFA) Status(user string) (*signinapi.MFAStatus, error) {
+	u, err := m.get(user)
+	if err != nil {
+		return nil, err
+	}
+	return &signinapi.MFAStatus{
+		Enrolled:          u.Secret != nil,
+		Required:          u.Required,
+		RecoveryCodesLeft: countRecoveryCodes(u.Recovery),
+	}, nil
+}
+
+// Required checks if the user is required to present a second factor on
+// signing in.
+func (m *MFA) Required(user string) (bool, error) {
+	u, err := m.get(user)
+	if err != nil {
+		return false, err
+	}
+	return u.Required, nil
+}
+
+// SetRequired sets if the user is required to present a second factor on
+// signing in. A user that is required but not enrolled cannot sign in.
+func (m *MFA) SetRequired(user string, required bool) error {
+	return m.mutate(user, func(u *userMFA) error {
+		u.Required = required
+		return nil
+	})
+}
+
+// StartEnroll creates a new TOTP secret for the user to enroll. The
+// enrollment takes effect after FinishEnroll. If the user is already
+// enrolled, code must be a current TOTP code or a recovery code, so that
+// only the owner of the current factors can replace them.
+func (m *MFA) StartEnroll(user, code string) (
+	*signinapi.MFAEnrollment, error,
+) {
+	secret := NewTOTPSecret()
+	now := m.now()
+	var checkErr error
+	if err := m.mutate(user, func(u *userMFA) error {
+		if u.Secret != nil {
+			if checkErr = u.check(code, now); checkErr != nil {
+				return nil // Saves the failure.
+			}
+		}
+		u.Pending = secret
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if checkErr != nil {
+		return nil, checkErr
+	}
+	return &signinapi.MFAEnrollment{
+		Secret: EncodeSecret(secret),
+		URI:    TOTPURI(m.issuer, user, secret),
+	}, nil
+}
+
+// FinishEnroll finishes the enrollment with a TOTP code from the
+// authenticator app. On success, MFA becomes required for the user, and a
+// new set of recovery codes is returned. Only enrollments started by
+// StartEnroll can be finished, which checks the current factors of enrolled
+// users.
+func (m *MFA) FinishEnroll(user, code string) ([]string, error) {
+	codes, hashed, err := newRecoveryCodes(m.recoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	if err := m.mutate(user, func(u *userMFA) error {
+		if u.Pending == nil {
+			return errcode.InvalidArgf("no pending enrollment")
+		}
+		step := CheckTOTP(u.Pending, code, now)
+		if step < 0 {
+			return errcode.Unauthorizedf("wrong code")
+		}
+		u.Secret = u.Pending
+		u.Pending = nil
+		u.LastStep = step
+		u.Recovery = hashed
+		u.Required = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// NewRecoveryCodes replaces the recovery codes of an enrolled user. code
+// must be a current TOTP code or a recovery code of the user.
+func (m *MFA) NewRecoveryCodes(user, code string) ([]string, error) {
+	codes, hashed, err := newRecoveryCodes(m.recoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	var checkErr error
+	if err := m.mutate(user, func(u *userMFA) error {
+		if u.Secret == nil {
+			return errcode.InvalidArgf("not enrolled")
+		}
+		if checkErr = u.check(code, now); checkErr != nil {
+			return nil // Saves the failure.
+		}
+		u.Recovery = hashed
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if checkErr != nil {
+		return nil, checkErr
+	}
+	return codes, nil
+}
+
+// Verify verifies a TOTP code or a recovery code of the user. A TOTP code
+// cannot be used twice, and a recovery code can only be used once. It
+// returns a not found error if the user has no factors.
+func (m *MFA) Verify(user, code string) error {
+	now := m.now()
+	var checkErr error
+	if err := m.kv.Mutate(user, new(userMFA), func(v any) error {
+		checkErr = v.(*userMFA).check(code, now)
+		return nil // Saves the failure.
+	}); err != nil {
+		return err
+	}
+	return checkErr
+}
+
+// Reset removes all the factors of the user, and clears the requirement.
+// It is for admins to recover users that lost their factors.
+func (m *MFA) Reset(user string) error {
+	if err := m.kv.Remove(user); err != nil && !errcode.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+
+	"shanhu.io/g/pisces"
+	"shanhu.io/std/errcode"
+)
+
+func TestMFA(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	kv := pisces.NewMemKV()
+	m := New(kv, &Config{
+		Issuer:        "shanhu",
+		RecoveryCodes: 2,
+		Now:           func() time.Time { return now },
+	})
+	const user = "h8liu"
+
+	if err := m.Verify(user, "123456"); !errcode.IsNotFound(err) {
+		t.Errorf("verify before enroll got %v, want not found", err)
+	}
+	if err := kv.Get(user, new(userMFA)); !errcode.IsNotFound(err) {
+		t.Errorf("verify created an entry for an unknown user: %v", err)
+	}
+
+	enroll, err := m.StartEnroll(user, "")
+	if err != nil {
+		t.Fatal("start enroll: ", err)
+	}
+	secret, err := DecodeSecret(enroll.Secret)
+	if err != nil {
+		t.Fatal("decode secret: ", err)
+	}
+	if _, err := m.FinishEnroll(user, "000000"); err == nil {
+		t.Error("enrolled with a wrong code")
+	}
+	codes, err := m.FinishEnroll(user, TOTPCode(secret, now))
+	if err != nil {
+		t.Fatal("finish enroll: ", err)
+	}
+	if len(codes) != 2 {
+		t.Fatalf("got %d recovery codes, want 2", len(codes))
+	}
+
+	required, err := m.Required(user)
+	if err != nil {
+		t.Fatal("check required: ", err)
+	}
+	if !required {
+		t.Error("MFA not required after enrolling")
+	}
+
+	// The code used for enrolling cannot be used again.
+	if err := m.Verify(user, TOTPCode(secret, now)); err == nil {
+		t.Error("reused TOTP code passed")
+	}
+	now = now.Add(time.Minute)
+	if err := m.Verify(user, TOTPCode(secret, now)); err != nil {
+		t.Error("verify TOTP code: ", err)
+	}
+
+	// Recovery codes can only be used once.
+	if err := m.Verify(user, codes[0]); err != nil {
+		t.Error("verify recovery code: ", err)
+	}
+	if err := m.Verify(user, codes[0]); err == nil {
+		t.Error("reused recovery code passed")
+	}
+	status, err := m.Status(user)
+	if err != nil {
+		t.Fatal("get status: ", err)
+	}
+	if status.RecoveryCodesLeft != 1 {
+		t.Errorf("got %d recovery codes left, want 1", status.RecoveryCodesLeft)
+	}
+
+	// Renewing recovery codes and enrolling again need a current factor.
+	if _, err := m.NewRecoveryCodes(user, ""); err == nil {
+		t.Error("renewed recovery codes without a code")
+	}
+	newCodes, err := m.NewRecoveryCodes(user, codes[1])
+	if err != nil {
+		t.Fatal("renew recovery codes: ", err)
+	}
+	if len(newCodes) != 2 {
+		t.Errorf("got %d new recovery codes, want 2", len(newCodes))
+	}
+	if _, err := m.StartEnroll(user, ""); err == nil {
+		t.Error("started enrolling again without a code")
+	}
+	now = now.Add(time.Minute)
+	if _, err := m.StartEnroll(user, TOTPCode(secret, now)); err != nil {
+		t.Error("start enrolling again: ", err)
+	}
+}
+
+func TestMFABackoff(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := New(pisces.NewMemKV(), &Config{
+		RecoveryCodes: 1,
+		Now:           func() time.Time { return now },
+	})
+	const user = "h8liu"
+	enroll, err := m.StartEnroll(user, "")
+	if err != nil {
+		t.Fatal("start enroll: ", err)
+	}
+	secret, err := DecodeSecret(enroll.Secret)
+	if err != nil {
+		t.Fatal("decode secret: ", err)
+	}
+	if _, err := m.FinishEnroll(user, TOTPCode(secret, now)); err != nil {
+		t.Fatal("finish enroll: ", err)
+	}
+
+	for _, test := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: freeFailures, want: 0},
+		{failures: freeFailures + 1, want: minBackoff},
+		{failures: freeFailures + 3, want: 4 * minBackoff},
+		{failures: 100, want: maxBackoff},
+	} {
+		if got := backoff(test.failures); got != test.want {
+			t.Errorf(
+				"backoff after %d failures got %s, want %s",
+				test.failures, got, test.want,
+			)
+		}
+	}
+
+	for i := 0; i < freeFailures; i++ {
+		m.Verify(user, "wrong")
+	}
+	now = now.Add(time.Minute)
+	if err := m.Verify(user, TOTPCode(secret, now)); err != nil {
+		t.Error("verify after free failures: ", err)
+	}
+
+	for i := 0; i < freeFailures+10; i++ {
+		m.Verify(user, "wrong")
+		now = now.Add(maxBackoff)
+	}
+	if err := m.Verify(user, TOTPCode(secret, now)); err != nil {
+		t.Error("verify after at most max backoff: ", err)
+	}
+	m.Verify(user, "wrong")
+	now = now.Add(time.Millisecond)
+	if err := m.Verify(user, TOTPCode(secret, now)); err == nil {
+		t.Error("verify passed during backoff")
+	}
+}
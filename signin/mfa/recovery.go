@@ -0,0 +1,67 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"shanhu.io/g/argon2"
+	mrand "shanhu.io/g/rand"
+	"shanhu.io/std/errcode"
+)
+
+type recoveryCode struct {
+	Password *argon2.Password
+	Used     bool `json:",omitempty"`
+}
+
+func newRecoveryCode() string {
+	s := mrand.LowerLetters(10)
+	return s[:5] + "-" + s[5:]
+}
+
+func normRecoveryCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+// newRecoveryCodes creates n recovery codes. It returns the codes in plain
+// text for showing to the user, and their hashes for saving.
+func newRecoveryCodes(n int) ([]string, []*recoveryCode, error) {
+	var codes []string
+	var hashed []*recoveryCode
+	for i := 0; i < n; i++ {
+		code := newRecoveryCode()
+		pwd, err := argon2.NewPassword([]byte(code), rand.Reader)
+		if err != nil {
+			return nil, nil, errcode.Annotate(err, "hash recovery code")
+		}
+		codes = append(codes, code)
+		hashed = append(hashed, &recoveryCode{Password: pwd})
+	}
+	return codes, hashed, nil
+}
+
+// useRecoveryCode finds an unused recovery code that matches, and marks
+// it used.
+func useRecoveryCode(codes []*recoveryCode, code string) bool {
+	code = normRecoveryCode(code)
+	for _, c := range codes {
+		if c.Used || c.Password == nil {
+			continue
+		}
+		if c.Password.CheckString(code) {
+			c.Used = true
+			return true
+		}
+	}
+	return false
+}
+
+func countRecoveryCodes(codes []*recoveryCode) int {
+	n := 0
+	for _, c := range codes {
+		if !c.Used {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,96 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+
+	"shanhu.io/g/rand"
+)
+
+// TOTP parameters. These are the defaults of most authenticator apps.
+const (
+	totpPeriod = 30 // In seconds.
+	totpDigits = 6
+	totpSkew   = 1 // Number of periods allowed for clock skew.
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret creates a new random TOTP secret.
+func NewTOTPSecret() []byte { return rand.Bytes(20) }
+
+// EncodeSecret encodes a TOTP secret in base32 without padding, which is the
+// format that authenticator apps accept.
+func EncodeSecret(secret []byte) string {
+	return secretEncoding.EncodeToString(secret)
+}
+
+// DecodeSecret decodes a TOTP secret encoded by EncodeSecret.
+func DecodeSecret(s string) ([]byte, error) {
+	return secretEncoding.DecodeString(s)
+}
+
+// hotp computes the HOTP value of RFC 4226.
+func hotp(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	v := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", v%1000000)
+}
+
+func totpStep(t time.Time) int64 { return t.Unix() / totpPeriod }
+
+// TOTPCode returns the TOTP code of RFC 6238 at time t.
+func TOTPCode(secret []byte, t time.Time) string {
+	return hotp(secret, uint64(totpStep(t)))
+}
+
+// CheckTOTP checks a TOTP code at time t, allowing one period of clock
+// skew. It returns the time step that matches, or -1 if the code is wrong.
+func CheckTOTP(secret []byte, code string, t time.Time) int64 {
+	if len(code) != totpDigits {
+		return -1
+	}
+	step := totpStep(t)
+	for i := -totpSkew; i <= totpSkew; i++ {
+		s := step + int64(i)
+		if s < 0 {
+			continue
+		}
+		if hmac.Equal([]byte(hotp(secret, uint64(s))), []byte(code)) {
+			return s
+		}
+	}
+	return -1
+}
+
+// TOTPURI returns the "otpauth://" URI for enrolling the secret in an
+// authenticator app, normally presented as a QR code.
+func TOTPURI(issuer, account string, secret []byte) string {
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	q := make(url.Values)
+	q.Set("secret", EncodeSecret(secret))
+	if issuer != "" {
+		q.Set("issuer", issuer)
+	}
+	u := &url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
@@ -0,0 +1,52 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Test vectors from RFC 6238, truncated to 6 digits.
+	secret := []byte("12345678901234567890")
+	for _, test := range []struct {
+		sec  int64
+		want string
+	}{
+		{sec: 59, want: "287082"},
+		{sec: 1111111109, want: "081804"},
+		{sec: 1111111111, want: "050471"},
+		{sec: 1234567890, want: "005924"},
+		{sec: 2000000000, want: "279037"},
+	} {
+		got := TOTPCode(secret, time.Unix(test.sec, 0))
+		if got != test.want {
+			t.Errorf("code at %d: got %q, want %q", test.sec, got, test.want)
+		}
+	}
+}
+
+func TestCheckTOTP(t *testing.T) {
+	secret := NewTOTPSecret()
+	now := time.Unix(1700000000, 0)
+	code := TOTPCode(secret, now)
+
+	if step := CheckTOTP(secret, code, now); step != totpStep(now) {
+		t.Errorf("got step %d, want %d", step, totpStep(now))
+	}
+	if CheckTOTP(secret, code, now.Add(totpPeriod*time.Second)) < 0 {
+		t.Error("code rejected with one period of skew")
+	}
+	if CheckTOTP(secret, code, now.Add(3*totpPeriod*time.Second)) >= 0 {
+		t.Error("code accepted after three periods")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	got := TOTPURI("shanhu", "h8liu", secret)
+	const want = "otpauth://totp/shanhu:h8liu?" +
+		"issuer=shanhu&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
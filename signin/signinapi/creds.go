@@ -12,6 +12,11 @@ type Creds struct {
 	Token       string
 	ExpiresTime *timeutil.Timestamp `json:",omitempty"`
 
+	// RefreshToken is an optional long-lived token that can be exchanged
+	// for a new session token. Each refresh token can only be used once.
+	RefreshToken       string              `json:",omitempty"`
+	RefreshExpiresTime *timeutil.Timestamp `json:",omitempty"`
+
 	Expires int64 `json:",omitempty"` // Nanosecond timestamp, legacy use.
 }
 
@@ -0,0 +1,41 @@
+package signinapi
+
+// MFAEnrollment contains the TOTP secret for enrolling an authenticator
+// app.
+type MFAEnrollment struct {
+	Secret string // Base32 encoded secret.
+	URI    string // The "otpauth://" URI, for rendering a QR code.
+}
+
+// MFACodeRequest is a request that carries an MFA code, which is either a
+// TOTP code or a recovery code. For enrolling, the code is only required
+// when the user is already enrolled.
+type MFACodeRequest struct {
+	Code string
+}
+
+// MFARecoveryCodes contains one-time recovery codes, which are only shown
+// once when they are created.
+type MFARecoveryCodes struct {
+	Codes []string
+}
+
+// MFAStatus is the MFA status of a user.
+type MFAStatus struct {
+	Enrolled bool
+	Required bool
+
+	RecoveryCodesLeft int `json:",omitempty"`
+}
+
+// MFAVerifyRequest is the request of the second sign in step, which
+// verifies an MFA code for a pending sign in.
+type MFAVerifyRequest struct {
+	Challenge string
+	Code      string
+}
+
+// MFAVerifyResponse is the response of the second sign in step.
+type MFAVerifyResponse struct {
+	Redirect string `json:",omitempty"`
+}
@@ -0,0 +1,23 @@
+package signinapi
+
+import (
+	"shanhu.io/g/timeutil"
+)
+
+// RefreshRequest is the request to exchange a refresh token for a new
+// session token and a new refresh token.
+type RefreshRequest struct {
+	RefreshToken string
+	TTLDuration  *timeutil.Duration `json:",omitempty"`
+}
+
+// RevokeUserRequest is the request to revoke all sessions and refresh
+// tokens of a user.
+type RevokeUserRequest struct {
+	User string
+}
+
+// RevokeTokenRequest is the request to revoke a single session token.
+type RevokeTokenRequest struct {
+	Token string
+}
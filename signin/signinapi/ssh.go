@@ -25,3 +25,16 @@ type SSHSignature struct {
 	Blob   []byte
 	Rest   []byte
 }
+
+// SSHCertRequest is the request to sign an SSH user certificate.
+type SSHCertRequest struct {
+	PublicKey string             // In SSH authorized key format.
+	TTL       *timeutil.Duration `json:",omitempty"`
+}
+
+// SSHCertResponse is the response that contains a signed SSH user
+// certificate.
+type SSHCertResponse struct {
+	Certificate string // In SSH authorized key format.
+	Principals  []string
+}
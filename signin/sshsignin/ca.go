@@ -0,0 +1,192 @@
+package sshsignin
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"shanhu.io/g/aries"
+	"shanhu.io/g/roles"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// SignCertScope is the scope that a user's token must be granted to get a
+// certificate signed by the CA API.
+const SignCertScope = "ssh"
+
+// CAConfig is the configuration of an SSH certificate authority.
+type CAConfig struct {
+	// Signer signs the certificates with the CA key.
+	Signer ssh.Signer
+
+	// Principals returns the principals that a user can log in as.
+	Principals func(user string) ([]string, error)
+
+	// TTL is the default life time of a certificate. Default is one hour.
+	TTL time.Duration
+
+	// MaxTTL is the maximum life time of a certificate. Default is 12
+	// hours.
+	MaxTTL time.Duration
+
+	Now func() time.Time
+}
+
+// CA is an SSH certificate authority that signs short-lived user
+// certificates for signed-in users.
+type CA struct {
+	signer     ssh.Signer
+	principals func(user string) ([]string, error)
+	ttl        time.Duration
+	maxTTL     time.Duration
+	now        func() time.Time
+}
+
+// NewCA creates a new SSH certificate authority.
+func NewCA(config *CAConfig) *CA {
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	maxTTL := config.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = 12 * time.Hour
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return &CA{
+		signer:     config.Signer,
+		principals: config.Principals,
+		ttl:        ttl,
+		maxTTL:     maxTTL,
+		now:        timeutil.NowFunc(config.Now),
+	}
+}
+
+// RolePrincipals returns a principals function that maps a role to
+// principals. A role can log in as its own name, and every "ssh:<name>"
+// scope of the role grants principal <name>. Wildcard scopes like "ssh:*"
+// grant no principals, as certificates can only carry literal principals.
+// Disabled roles have no principals.
+func RolePrincipals(r *roles.Roles) func(user string) ([]string, error) {
+	return func(user string) ([]string, error) {
+		role, err := r.Get(user)
+		if err != nil {
+			return nil, err
+		}
+		if role.Disabled {
+			return nil, errcode.Unauthorizedf("role is disabled")
+		}
+		principals := []string{role.Name}
+		for _, s := range role.Scopes {
+			p, ok := strings.CutPrefix(s, "ssh:")
+			if !ok || p == "" || strings.ContainsAny(p, "*?") {
+				continue
+			}
+			principals = append(principals, p)
+		}
+		return principals, nil
+	}
+}
+
+func randSerial() (uint64, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// SignCert signs a user certificate of the public key for the user.
+func (ca *CA) SignCert(user string, pub ssh.PublicKey, ttl time.Duration) (
+	*ssh.Certificate, error,
+) {
+	if _, ok := pub.(*ssh.Certificate); ok {
+		return nil, errcode.InvalidArgf("public key is a certificate")
+	}
+	principals, err := ca.principals(user)
+	if err != nil {
+		return nil, errcode.Annotate(err, "get principals")
+	}
+	if len(principals) == 0 {
+		return nil, errcode.Unauthorizedf("no principals")
+	}
+
+	if ttl <= 0 {
+		ttl = ca.ttl
+	}
+	if ttl > ca.maxTTL {
+		ttl = ca.maxTTL
+	}
+	serial, err := randSerial()
+	if err != nil {
+		return nil, errcode.Annotate(err, "make serial")
+	}
+
+	// Allows a minute of clock skew.
+	now := ca.now()
+	cert := &ssh.Certificate{
+		Key:             pub,
+		Serial:          serial,
+		CertType:        ssh.UserCert,
+		KeyId:           user,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-agent-forwarding": "",
+				"permit-port-forwarding":  "",
+				"permit-pty":              "",
+				"permit-user-rc":          "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, ca.signer); err != nil {
+		return nil, errcode.Annotate(err, "sign certificate")
+	}
+	return cert, nil
+}
+
+func (ca *CA) apiSignCert(c *aries.C, req *signinapi.SSHCertRequest) (
+	*signinapi.SSHCertResponse, error,
+) {
+	if c.User == "" {
+		return nil, aries.NeedSignIn
+	}
+	if !c.HasScope(SignCertScope) {
+		return nil, errcode.Unauthorizedf("scope %q required", SignCertScope)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil {
+		return nil, errcode.InvalidArgf("parse public key: %s", err)
+	}
+	ttl := timeutil.TimeDuration(req.TTL)
+	cert, err := ca.SignCert(c.User, pub, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &signinapi.SSHCertResponse{
+		Certificate: string(ssh.MarshalAuthorizedKey(cert)),
+		Principals:  cert.ValidPrincipals,
+	}, nil
+}
+
+// API returns the API router of the CA. It should be mounted where users
+// are signed in. Only users whose tokens are granted with SignCertScope can
+// get certificates signed.
+func (ca *CA) API() *aries.Router {
+	r := aries.NewRouter()
+	r.Call("sign-cert", ca.apiSignCert)
+	return r
+}
+
+// AddAPI adds the API under /sshca .
+func (ca *CA) AddAPI(r *aries.Router) {
+	r.DirService("sshca", ca.API())
+}
@@ -0,0 +1,141 @@
+package sshsignin
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"shanhu.io/g/aries"
+	"shanhu.io/g/httputil"
+	"shanhu.io/g/keyreg/testkeys"
+	"shanhu.io/g/pisces"
+	"shanhu.io/g/roles"
+	"shanhu.io/g/rsautil"
+	"shanhu.io/g/signin/authgate"
+)
+
+func TestCA(t *testing.T) {
+	caKey, err := rsautil.ParsePrivateKey([]byte(testkeys.Pem1))
+	if err != nil {
+		t.Fatal("parse CA key: ", err)
+	}
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal("make CA signer: ", err)
+	}
+	ca := NewCA(&CAConfig{
+		Signer: caSigner,
+		Principals: func(user string) ([]string, error) {
+			return []string{user}, nil
+		},
+	})
+
+	gate := authgate.New(&authgate.Config{SessionKey: []byte("key")})
+	exchange, err := authgate.NewSSHCertExchange(
+		gate, &authgate.SSHCertExchangeConfig{
+			CAPublicKey:  []byte(testkeys.Pub1),
+			ChallengeKey: []byte("challenge-key"),
+		},
+	)
+	if err != nil {
+		t.Fatal("make exchange: ", err)
+	}
+
+	guest := aries.NewRouter()
+	exchange.AddAPI(guest)
+	user := aries.NewRouter()
+	ca.AddAPI(user)
+	s := httptest.NewServer(aries.Serve(&aries.ServiceSet{
+		Auth:  gate,
+		Guest: guest,
+		User:  user,
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	guestClient, err := httputil.NewClient(s.URL)
+	if err != nil {
+		t.Fatal("make client: ", err)
+	}
+	keyring := agent.NewKeyring().(agent.ExtendedAgent)
+	config := &CertConfig{Agent: keyring, TTL: time.Minute}
+	if _, err := LoadCert(ctx, guestClient, config); err == nil {
+		t.Error("signed a certificate without signing in")
+	}
+
+	client, err := httputil.NewClient(s.URL)
+	if err != nil {
+		t.Fatal("make client: ", err)
+	}
+	tok, err := gate.TokenErr("h8liu", time.Hour)
+	if err != nil {
+		t.Fatal("issue token: ", err)
+	}
+	client.TokenSource = httputil.NewStaticToken(tok.Token)
+	if _, err := LoadCert(ctx, client, config); err == nil {
+		t.Error("signed a certificate without the scope")
+	}
+
+	scopes := []string{SignCertScope}
+	tok, err = gate.ScopedTokenErr("h8liu", scopes, time.Hour)
+	if err != nil {
+		t.Fatal("issue scoped token: ", err)
+	}
+	client.TokenSource = httputil.NewStaticToken(tok.Token)
+
+	// Loads twice; the old key is replaced by the new one.
+	var cert *ssh.Certificate
+	for i := 0; i < 2; i++ {
+		cert, err = LoadCert(ctx, client, config)
+		if err != nil {
+			t.Fatal("load certificate: ", err)
+		}
+	}
+	keys, err := keyring.List()
+	if err != nil {
+		t.Fatal("list keys: ", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("got %d keys in agent, want 1", len(keys))
+	}
+	if !bytes.Equal(keys[0].Blob, cert.Marshal()) {
+		t.Error("the key in agent is not the new certificate")
+	}
+
+	if _, err := Dial(ctx, s.URL, &Config{
+		User:  "h8liu",
+		Agent: keyring,
+	}); err != nil {
+		t.Fatal("sign in with certificate: ", err)
+	}
+	if _, err := Dial(ctx, s.URL, &Config{
+		User:  "other",
+		Agent: keyring,
+	}); err == nil {
+		t.Error("signed in as a principal not in the certificate")
+	}
+}
+
+func TestRolePrincipals(t *testing.T) {
+	r := roles.New(pisces.NewMemTables())
+	if err := r.New("alice", time.Now()); err != nil {
+		t.Fatal("create role: ", err)
+	}
+	scopes := []string{"ssh", "ssh:admin", "ssh:*", "ssh:ops*", "roles:read"}
+	if err := r.SetScopes("alice", scopes); err != nil {
+		t.Fatal("set scopes: ", err)
+	}
+	got, err := RolePrincipals(r)("alice")
+	if err != nil {
+		t.Fatal("get principals: ", err)
+	}
+	want := []string{"alice", "admin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got principals %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package sshsignin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"shanhu.io/g/httputil"
+	"shanhu.io/g/signin/signinapi"
+	"shanhu.io/g/strutil"
+	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
+)
+
+// CertConfig is the configuration to request an SSH user certificate.
+type CertConfig struct {
+	Agent      agent.Agent // Default using SSH_AUTH_SOCK
+	KeyComment string      // Default is "shanhu"
+
+	// Optional key to certify. Default is a newly generated key.
+	Key *rsa.PrivateKey
+
+	// Optional life time of the certificate. Default is decided by the CA.
+	TTL time.Duration
+}
+
+func (c *CertConfig) agent() (agent.Agent, error) {
+	if c.Agent != nil {
+		return c.Agent, nil
+	}
+	return SysAgent()
+}
+
+// removeKeys removes the keys with the comment in the agent except the
+// one with the blob keep, so that old certificates do not shadow the new
+// one.
+func removeKeys(ag agent.Agent, comment string, keep []byte) error {
+	keys, err := ag.List()
+	if err != nil {
+		return errcode.Annotate(err, "list keys")
+	}
+	for _, k := range keys {
+		if k.Comment != comment || bytes.Equal(k.Blob, keep) {
+			continue
+		}
+		if err := ag.Remove(k); err != nil {
+			return errcode.Annotatef(err, "remove key %q", comment)
+		}
+	}
+	return nil
+}
+
+// LoadCert requests an SSH user certificate from the CA with a signed-in
+// client, and loads the certificate and its key into the SSH agent. Keys
+// in the agent that have the same comment are replaced, after the new one
+// is added. The key expires from the agent with the certificate.
+func LoadCert(
+	ctx context.Context, client *httputil.Client, config *CertConfig,
+) (*ssh.Certificate, error) {
+	ag, err := config.agent()
+	if err != nil {
+		return nil, errcode.Annotate(err, "get SSH agent")
+	}
+
+	key := config.Key
+	if key == nil {
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, errcode.Annotate(err, "generate key")
+		}
+		key = k
+	}
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, errcode.Annotate(err, "convert public key")
+	}
+
+	req := &signinapi.SSHCertRequest{
+		PublicKey: string(ssh.MarshalAuthorizedKey(pub)),
+	}
+	if config.TTL > 0 {
+		req.TTL = timeutil.NewDuration(config.TTL)
+	}
+	resp := new(signinapi.SSHCertResponse)
+	const p = "/sshca/sign-cert"
+	if err := client.CallContext(ctx, p, req, resp); err != nil {
+		return nil, errcode.Annotate(err, "sign certificate")
+	}
+
+	certKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Certificate))
+	if err != nil {
+		return nil, errcode.Annotate(err, "parse certificate")
+	}
+	cert, ok := certKey.(*ssh.Certificate)
+	if !ok {
+		return nil, errcode.Internalf("not a certificate")
+	}
+
+	comment := strutil.Default(config.KeyComment, "shanhu")
+	var lifetime uint32
+	if left := int64(cert.ValidBefore) - time.Now().Unix(); left > 0 {
+		lifetime = uint32(left)
+	}
+	if err := ag.Add(agent.AddedKey{
+		PrivateKey:   key,
+		Certificate:  cert,
+		Comment:      comment,
+		LifetimeSecs: lifetime,
+	}); err != nil {
+		return nil, errcode.Annotate(err, "add to agent")
+	}
+	if err := removeKeys(ag, comment, cert.Marshal()); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
@@ -5,12 +5,17 @@ import (
 
 	"shanhu.io/g/signin/signinapi"
 	"shanhu.io/g/timeutil"
+	"shanhu.io/std/errcode"
 )
 
 // Token is a token with an expire time.
 type Token struct {
 	Token  string
 	Expire time.Time
+
+	// Optional refresh token that can be used to get a new token.
+	Refresh       string
+	RefreshExpire time.Time
 }
 
 // Tokener issues auth tokens for users.
@@ -18,11 +23,79 @@ type Tokener interface {
 	Token(user string, ttl time.Duration) *Token
 }
 
+// ScopedTokener issues auth tokens that carry permission scopes.
+type ScopedTokener interface {
+	Tokener
+
+	// ScopedToken issues a token that grants only the given scopes.
+	ScopedToken(user string, scopes []string, ttl time.Duration) *Token
+}
+
+// ErrTokener is a Tokener that reports why a token cannot be issued.
+type ErrTokener interface {
+	TokenErr(user string, ttl time.Duration) (*Token, error)
+}
+
+// ScopedErrTokener is a ScopedTokener that reports why a token cannot be
+// issued.
+type ScopedErrTokener interface {
+	ScopedTokenErr(user string, scopes []string, ttl time.Duration) (
+		*Token, error,
+	)
+}
+
+// IssueToken issues a token with the given scopes. If scopes is empty, or
+// the tokener does not support scopes, it issues a plain token. It returns
+// nil when the token cannot be issued.
+func IssueToken(
+	tok Tokener, user string, scopes []string, ttl time.Duration,
+) *Token {
+	if len(scopes) > 0 {
+		if st, ok := tok.(ScopedTokener); ok {
+			return st.ScopedToken(user, scopes, ttl)
+		}
+	}
+	return tok.Token(user, ttl)
+}
+
+// IssueTokenErr is like IssueToken, but returns an error when the token
+// cannot be issued. It uses the error-returning methods of the tokener when
+// the tokener has them.
+func IssueTokenErr(
+	tok Tokener, user string, scopes []string, ttl time.Duration,
+) (*Token, error) {
+	if len(scopes) > 0 {
+		if st, ok := tok.(ScopedErrTokener); ok {
+			return st.ScopedTokenErr(user, scopes, ttl)
+		}
+	}
+	if len(scopes) == 0 || !isScoped(tok) {
+		if et, ok := tok.(ErrTokener); ok {
+			return et.TokenErr(user, ttl)
+		}
+	}
+	ret := IssueToken(tok, user, scopes, ttl)
+	if ret == nil {
+		return nil, errcode.Internalf("failed to issue token")
+	}
+	return ret, nil
+}
+
+func isScoped(tok Tokener) bool {
+	_, ok := tok.(ScopedTokener)
+	return ok
+}
+
 // TokenCreds gets the credential from a token.
 func TokenCreds(user string, tok *Token) *signinapi.Creds {
-	return &signinapi.Creds{
+	creds := &signinapi.Creds{
 		User:        user,
 		Token:       tok.Token,
 		ExpiresTime: timeutil.NewTimestamp(tok.Expire),
 	}
+	if tok.Refresh != "" {
+		creds.RefreshToken = tok.Refresh
+		creds.RefreshExpiresTime = timeutil.NewTimestamp(tok.RefreshExpire)
+	}
+	return creds
 }
@@ -0,0 +1,117 @@
+package smake
+
+import (
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"shanhu.io/std/errcode"
+)
+
+func gitLines(c *context, args ...string) ([]string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = c.workDir()
+	cmd.Env = c.env
+	cmd.Stderr = c.errLog
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if s := strings.TrimSpace(string(line)); s != "" {
+			ret = append(ret, s)
+		}
+	}
+	return ret, nil
+}
+
+// changedFiles returns the files that are changed since the git revision,
+// including the untracked ones. Files are relative to the work directory.
+func changedFiles(c *context, since string) ([]string, error) {
+	changed, err := gitLines(c, "diff", "--name-only", "--relative", since)
+	if err != nil {
+		return nil, errcode.Annotatef(err, "git diff since %q", since)
+	}
+	untracked, err := gitLines(
+		c, "ls-files", "--others", "--exclude-standard",
+	)
+	if err != nil {
+		return nil, errcode.Annotate(err, "list untracked files")
+	}
+	return append(changed, untracked...), nil
+}
+
+// affectedPkgs returns the packages which tests might be affected by the
+// changed files, in the order of pkgs. dir is the directory that the files
+// are relative to. A file belongs to the package in the nearest directory.
+// Changes on go.mod or go.sum affect all packages.
+func affectedPkgs(pkgs []*relPkg, dir string, files []string) []*relPkg {
+	byDir := make(map[string]*relPkg)
+	for _, pkg := range pkgs {
+		byDir[pkg.pkg.Dir] = pkg
+	}
+
+	changed := make(map[string]bool)
+	for _, f := range files {
+		switch filepath.Base(f) {
+		case "go.mod", "go.sum":
+			return pkgs
+		}
+		for d := filepath.Dir(filepath.Join(dir, f)); ; {
+			if pkg, ok := byDir[d]; ok {
+				changed[pkg.abs] = true
+				break
+			}
+			parent := filepath.Dir(d)
+			if d == dir || parent == d {
+				break
+			}
+			d = parent
+		}
+	}
+
+	// Changes propagate to the importers, and to the importers of them.
+	importers := make(map[string][]string)
+	for _, pkg := range pkgs {
+		for _, imp := range pkg.pkg.Imports {
+			importers[imp] = append(importers[imp], pkg.abs)
+		}
+	}
+	affected := make(map[string]bool)
+	var stack []string
+	for p := range changed {
+		affected[p] = true
+		stack = append(stack, p)
+	}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, imp := range importers[p] {
+			if !affected[imp] {
+				affected[imp] = true
+				stack = append(stack, imp)
+			}
+		}
+	}
+
+	// Test-only imports affect the tests, but do not propagate further.
+	var ret []*relPkg
+	for _, pkg := range pkgs {
+		if affected[pkg.abs] || anyIn(affected, pkg.pkg.TestImports) ||
+			anyIn(affected, pkg.pkg.XTestImports) {
+			ret = append(ret, pkg)
+		}
+	}
+	return ret
+}
+
+func anyIn(set map[string]bool, lst []string) bool {
+	for _, s := range lst {
+		if set[s] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package smake
+
+import (
+	"go/build"
+	"reflect"
+	"testing"
+)
+
+func TestAffectedPkgs(t *testing.T) {
+	newPkg := func(name string, imports, testImports []string) *relPkg {
+		return &relPkg{
+			abs: "x/" + name,
+			rel: "./" + name,
+			pkg: &build.Package{
+				Dir:         "/x/" + name,
+				Imports:     imports,
+				TestImports: testImports,
+			},
+		}
+	}
+	pkgs := []*relPkg{
+		newPkg("a", nil, nil),
+		newPkg("b", []string{"x/a"}, nil),
+		newPkg("c", []string{"x/b"}, nil),
+		newPkg("d", nil, []string{"x/a"}),
+		newPkg("e", []string{"x/d"}, nil),
+		newPkg("f", nil, nil),
+	}
+
+	for _, test := range []struct {
+		files []string
+		want  []string
+	}{
+		{files: nil, want: nil},
+		{files: []string{"f/f.go"}, want: []string{"./f"}},
+		{
+			files: []string{"a/testdata/in.txt"},
+			want:  []string{"./a", "./b", "./c", "./d"},
+		},
+		{files: []string{"d/d.go"}, want: []string{"./d", "./e"}},
+		{files: []string{"README.md"}, want: nil},
+		{
+			files: []string{"go.sum"},
+			want:  []string{"./a", "./b", "./c", "./d", "./e", "./f"},
+		},
+	} {
+		var got []string
+		for _, pkg := range affectedPkgs(pkgs, "/x", test.files) {
+			got = append(got, pkg.rel)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf(
+				"affected by %q: got %q, want %q",
+				test.files, got, test.want,
+			)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package smake
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"text/tabwriter"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/analysis/passes/appends"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/defers"
+	"golang.org/x/tools/go/analysis/passes/errorsas"
+	"golang.org/x/tools/go/analysis/passes/httpresponse"
+	"golang.org/x/tools/go/analysis/passes/ifaceassert"
+	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/nilness"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/stringintconv"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/unmarshal"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+	"golang.org/x/tools/go/packages"
+	"shanhu.io/g/gocheck"
+	"shanhu.io/std/errcode"
+	"shanhu.io/std/lexing"
+)
+
+// vetAnalyzers are the analyzers from golang.org/x/tools, which cover the
+// checks of go vet, nilness and shadow. The shadow analyzer runs in its
+// default non-strict mode, which only reports a shadowed variable when it
+// is used after the shadowing declaration. Findings can be suppressed with
+// "//gocheck:ignore shadow" comments.
+var vetAnalyzers = []*analysis.Analyzer{
+	appends.Analyzer,
+	assign.Analyzer,
+	atomic.Analyzer,
+	bools.Analyzer,
+	copylock.Analyzer,
+	defers.Analyzer,
+	errorsas.Analyzer,
+	httpresponse.Analyzer,
+	ifaceassert.Analyzer,
+	lostcancel.Analyzer,
+	nilfunc.Analyzer,
+	nilness.Analyzer,
+	printf.Analyzer,
+	shadow.Analyzer,
+	shift.Analyzer,
+	stdmethods.Analyzer,
+	stringintconv.Analyzer,
+	structtag.Analyzer,
+	unmarshal.Analyzer,
+	unreachable.Analyzer,
+	unusedresult.Analyzer,
+}
+
+// gocheckConfigs reads and caches the gocheck configs of directories.
+type gocheckConfigs struct {
+	root string
+
+	mu      sync.Mutex
+	configs map[string]*gocheck.Config
+}
+
+func (cs *gocheckConfigs) get(dir string) (*gocheck.Config, error) {
+	const textHeight = 320 // 20 lines for license notice.
+	const textWidth = 80
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if c, ok := cs.configs[dir]; ok {
+		return c, nil
+	}
+	c, err := gocheck.FindConfig(dir, cs.root)
+	if err != nil {
+		return nil, err
+	}
+	if c.Height == 0 {
+		c.Height = textHeight
+	}
+	if c.Width == 0 {
+		c.Width = textWidth
+	}
+	cs.configs[dir] = c
+	return c, nil
+}
+
+func gocheckAnalyzers(c *context) []*analysis.Analyzer {
+	configs := &gocheckConfigs{
+		root:    c.modRootDir(),
+		configs: make(map[string]*gocheck.Config),
+	}
+	return gocheck.Analyzers(configs.get)
+}
+
+// analyzeRoots splits the loaded packages into the roots for the gocheck
+// rules, which are the packages without tests, and the roots for the vet
+// analyzers, which are the packages with their tests, like go vet. The
+// roots are keyed by the paths of the packages under test.
+func analyzeRoots(loaded []*packages.Package) (
+	plain, tests []*packages.Package, owners map[*packages.Package]string,
+) {
+	owners = make(map[*packages.Package]string)
+	withTests := make(map[string]bool)
+	for _, pkg := range loaded {
+		if pkg.ForTest == pkg.PkgPath {
+			withTests[pkg.PkgPath] = true
+		}
+	}
+	for _, pkg := range loaded {
+		switch {
+		case pkg.ID == pkg.PkgPath:
+			plain = append(plain, pkg)
+			owners[pkg] = pkg.PkgPath
+			if !withTests[pkg.PkgPath] {
+				tests = append(tests, pkg)
+			}
+		case pkg.ForTest == pkg.PkgPath:
+			tests = append(tests, pkg)
+			owners[pkg] = pkg.PkgPath
+		case pkg.ForTest != "" && pkg.PkgPath == pkg.ForTest+"_test":
+			tests = append(tests, pkg)
+			owners[pkg] = pkg.ForTest
+		}
+	}
+	return plain, tests, owners
+}
+
+func runAnalyzers(
+	as []*analysis.Analyzer, roots []*packages.Package,
+	owners map[*packages.Package]string, byPath map[string]*pkgFindings,
+) error {
+	if len(roots) == 0 {
+		return nil
+	}
+	g, err := checker.Analyze(as, roots, nil)
+	if err != nil {
+		return errcode.Annotate(err, "analyze")
+	}
+	for _, act := range g.Roots {
+		if f, ok := byPath[owners[act.Package]]; ok {
+			f.addAction(act)
+		}
+	}
+	return nil
+}
+
+// analyzePkgs runs the analyzers on the packages in parallel, and returns
+// the findings of each package, in the order of the packages. The vet
+// analyzers also check the tests.
+func analyzePkgs(c *context, pkgs []*relPkg) ([]*pkgFindings, error) {
+	var patterns []string
+	for _, pkg := range pkgs {
+		patterns = append(patterns, pkg.rel)
+	}
+	loaded, err := packages.Load(&packages.Config{
+		Mode:  packages.LoadAllSyntax | packages.NeedForTest,
+		Dir:   c.workDir(),
+		Env:   c.env,
+		Tests: true,
+	}, patterns...)
+	if err != nil {
+		return nil, errcode.Annotate(err, "load packages")
+	}
+
+	byPath := make(map[string]*pkgFindings)
+	var ret []*pkgFindings
+	for _, pkg := range pkgs {
+		f := &pkgFindings{pkg: pkg}
+		byPath[pkg.abs] = f
+		ret = append(ret, f)
+	}
+	plain, tests, owners := analyzeRoots(loaded)
+	for _, pkg := range append(plain, tests...) {
+		if f, ok := byPath[owners[pkg]]; ok {
+			f.addLoadErrors(pkg)
+		}
+	}
+
+	if err := runAnalyzers(
+		gocheckAnalyzers(c), plain, owners, byPath,
+	); err != nil {
+		return nil, err
+	}
+	if err := runAnalyzers(vetAnalyzers, tests, owners, byPath); err != nil {
+		return nil, err
+	}
+	for _, f := range ret {
+		f.errs = uniqueErrs(f.errs)
+		sortErrs(f.errs)
+	}
+	return ret, nil
+}
+
+// cachedAnalyzePkgs is like analyzePkgs, but only analyzes the packages
+// that miss the cache.
+func cachedAnalyzePkgs(c *context, pkgs []*relPkg) ([]*pkgFindings, error) {
+	key := func(pkg *relPkg) (string, error) {
+		fp, err := c.cache.fps.test(pkg.abs)
+		if err != nil {
+			return "", err
+		}
+		config, err := gocheck.FindConfig(pkg.pkg.Dir, c.modRootDir())
+		if err != nil {
+			return "", err
+		}
+		bs, err := json.Marshal(config)
+		if err != nil {
+			return "", err
+		}
+		return hashLines([]string{fp, string(bs)}), nil
+	}
+	run := func(pkgs []*relPkg) ([]*findingsResult, error) {
+		findings, err := analyzePkgs(c, pkgs)
+		if err != nil {
+			return nil, err
+		}
+		var ret []*findingsResult
+		for _, f := range findings {
+			ret = append(ret, f.result())
+		}
+		return ret, nil
+	}
+	keep := func(*findingsResult) bool { return true }
+
+	results, err := cachedRun(c.cache, "analyze", pkgs, key, run, keep)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*pkgFindings
+	for i, r := range results {
+		ret = append(ret, resultFindings(pkgs[i], r))
+	}
+	return ret, nil
+}
+
+func printSummary(c *context, findings []*pkgFindings) {
+	w := tabwriter.NewWriter(c.errLog, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tFINDINGS\tBY RULE")
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", f.pkg.rel, len(f.errs), f.counts())
+	}
+	w.Flush()
+}
+
+// analyze runs the analyzers on all the packages, prints all the findings
+// and a summary table, and fails if there are any findings.
+func analyze(c *context, pkgs []*relPkg) error {
+	c.logln("analyze")
+
+	findings, err := cachedAnalyzePkgs(c, pkgs)
+	if err != nil {
+		return err
+	}
+
+	var all []*lexing.Error
+	npkg := 0
+	for _, f := range findings {
+		for _, err := range f.errs {
+			c.logf("%s (%s)\n", err, err.Code)
+		}
+		if len(f.errs) > 0 {
+			npkg++
+		}
+		all = append(all, f.errs...)
+	}
+	printSummary(c, findings)
+
+	c.res.analyzed = true
+	c.res.findings = append(c.res.findings, all...)
+	if len(all) > 0 {
+		return fmt.Errorf(
+			"analyze: %d findings in %d packages", len(all), npkg,
+		)
+	}
+	return nil
+}
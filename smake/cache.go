@@ -0,0 +1,167 @@
+package smake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"shanhu.io/g/hashutil"
+	"shanhu.io/g/jsonutil"
+	"shanhu.io/std/errcode"
+)
+
+// cacheEntry is the saved result of a stage on a package.
+type cacheEntry struct {
+	Fingerprint string
+	Result      json.RawMessage
+}
+
+// cacheStats counts the cache hits of a stage.
+type cacheStats struct {
+	hits  int
+	total int
+}
+
+// buildCache saves the results of stages on packages, keyed by the
+// fingerprints of the packages.
+type buildCache struct {
+	dir   string
+	force bool // do not read the cache, but still update it
+	fps   *fingerprints
+	stats map[string]*cacheStats
+}
+
+func newBuildCache(dir string, force bool, fps *fingerprints) *buildCache {
+	return &buildCache{
+		dir:   dir,
+		force: force,
+		fps:   fps,
+		stats: make(map[string]*cacheStats),
+	}
+}
+
+func (c *buildCache) file(stage, pkg string) string {
+	return filepath.Join(c.dir, stage, hashutil.HashStr(pkg)+".json")
+}
+
+func (c *buildCache) stat(stage string) *cacheStats {
+	s, ok := c.stats[stage]
+	if !ok {
+		s = new(cacheStats)
+		c.stats[stage] = s
+	}
+	return s
+}
+
+// load loads the result of a stage on a package. It returns false if the
+// result is missing or outdated.
+func (c *buildCache) load(stage, pkg, fp string, v any) bool {
+	s := c.stat(stage)
+	s.total++
+	if c.force {
+		return false
+	}
+
+	entry := new(cacheEntry)
+	if err := jsonutil.ReadFile(c.file(stage, pkg), entry); err != nil {
+		return false
+	}
+	if entry.Fingerprint != fp {
+		return false
+	}
+	if err := json.Unmarshal(entry.Result, v); err != nil {
+		return false
+	}
+	s.hits++
+	return true
+}
+
+func (c *buildCache) save(stage, pkg, fp string, v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f := c.file(stage, pkg)
+	if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+		return err
+	}
+	return jsonutil.WriteFile(f, &cacheEntry{
+		Fingerprint: fp,
+		Result:      bs,
+	})
+}
+
+func (c *buildCache) printSummary(out io.Writer) {
+	if len(c.stats) == 0 {
+		return
+	}
+	var stages []string
+	for stage := range c.stats {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tCACHED\tTOTAL")
+	for _, stage := range stages {
+		s := c.stats[stage]
+		fmt.Fprintf(w, "%s\t%d\t%d\n", stage, s.hits, s.total)
+	}
+	w.Flush()
+}
+
+// cachedRun runs a stage on the packages that miss the cache, and returns
+// the results of all the packages, in the order of pkgs. key returns the
+// fingerprint of a package for the stage, and keep tells if a result
+// should be saved. Results must be JSON serializable. When c is nil, it
+// runs the stage on all the packages.
+func cachedRun[T any](
+	c *buildCache, stage string, pkgs []*relPkg,
+	key func(pkg *relPkg) (string, error),
+	run func(pkgs []*relPkg) ([]T, error),
+	keep func(r T) bool,
+) ([]T, error) {
+	if c == nil {
+		return run(pkgs)
+	}
+	ret := make([]T, len(pkgs))
+
+	var misses []*relPkg
+	var missIndex []int
+	var missKeys []string
+	for i, pkg := range pkgs {
+		fp, err := key(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if c.load(stage, pkg.abs, fp, &ret[i]) {
+			continue
+		}
+		misses = append(misses, pkg)
+		missIndex = append(missIndex, i)
+		missKeys = append(missKeys, fp)
+	}
+	if len(misses) == 0 {
+		return ret, nil
+	}
+
+	results, err := run(misses)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range results {
+		ret[missIndex[i]] = r
+		if !keep(r) {
+			continue
+		}
+		pkg := misses[i].abs
+		if err := c.save(stage, pkg, missKeys[i], r); err != nil {
+			return nil, errcode.Annotatef(err, "save cache of %s", pkg)
+		}
+	}
+	return ret, nil
+}
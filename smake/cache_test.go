@@ -0,0 +1,60 @@
+package smake
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCachedRun(t *testing.T) {
+	pkgs := []*relPkg{
+		{abs: "x/a", rel: "./a"},
+		{abs: "x/b", rel: "./b"},
+		{abs: "x/c", rel: "./c"},
+	}
+	keys := map[string]string{"x/a": "1", "x/b": "1", "x/c": "1"}
+	key := func(pkg *relPkg) (string, error) { return keys[pkg.abs], nil }
+
+	var ran []string
+	run := func(pkgs []*relPkg) ([]string, error) {
+		var ret []string
+		for _, pkg := range pkgs {
+			ran = append(ran, pkg.rel)
+			ret = append(ret, pkg.rel+"@"+keys[pkg.abs])
+		}
+		return ret, nil
+	}
+	keep := func(r string) bool { return r != "./c@1" }
+
+	cache := newBuildCache(t.TempDir(), false, nil)
+	for _, test := range []struct {
+		change string
+		ran    []string
+	}{
+		{ran: []string{"./a", "./b", "./c"}},
+		{ran: []string{"./c"}},
+		{change: "x/b", ran: []string{"./b", "./c"}},
+	} {
+		if test.change != "" {
+			keys[test.change] = "2"
+		}
+		ran = nil
+		got, err := cachedRun(cache, "stage", pkgs, key, run, keep)
+		if err != nil {
+			t.Fatal("cached run: ", err)
+		}
+		if !reflect.DeepEqual(ran, test.ran) {
+			t.Errorf("ran %q, want %q", ran, test.ran)
+		}
+		var want []string
+		for _, pkg := range pkgs {
+			want = append(want, pkg.rel+"@"+keys[pkg.abs])
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got results %q, want %q", got, want)
+		}
+	}
+
+	if s := cache.stats["stage"]; s.hits != 3 || s.total != 9 {
+		t.Errorf("got %d hits in %d, want 3 in 9", s.hits, s.total)
+	}
+}
@@ -6,16 +6,45 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+
+	"shanhu.io/std/lexing"
 )
 
+type options struct {
+	dir    string
+	report string // file to write the analysis findings
+
+	test         bool   // run the test stage
+	race         bool   // run tests with the race detector
+	short        bool   // run tests in short mode
+	coverDir     string // directory to write the coverage report
+	changedSince string // only test packages affected since the revision
+
+	cacheDir string // directory to save the stage results
+	force    bool   // ignore the saved stage results
+
+	workspace bool // run over all the modules in the workspace
+}
+
+// result records the outcome of the stages on a module.
+type result struct {
+	pkgs     int
+	analyzed bool
+	findings []*lexing.Error
+	testFail int // number of failed test packages
+}
+
 type context struct {
 	dir     string
 	modRoot string
 	env     []string
 	errLog  io.Writer
+	opts    *options
+	cache   *buildCache // nil when caching is disabled
+	res     *result
 }
 
-func newContext(gopath, modRoot, dir string) *context {
+func newContext(gopath, modRoot, dir string, opts *options) *context {
 	var env []string
 	for _, v := range []string{
 		"PATH", "HOME", "SSH_AUTH_SOCK",
@@ -34,6 +63,8 @@ func newContext(gopath, modRoot, dir string) *context {
 		modRoot: modRoot,
 		env:     env,
 		errLog:  os.Stderr,
+		opts:    opts,
+		res:     new(result),
 	}
 }
 
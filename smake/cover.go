@@ -0,0 +1,161 @@
+package smake
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/cover"
+)
+
+type coverBlockKey struct {
+	startLine, startCol int
+	endLine, endCol     int
+}
+
+// mergeProfiles merges coverage profiles of the same mode. Counts of the
+// same block are added up, or or-ed in the "set" mode.
+func mergeProfiles(lists ...[]*cover.Profile) []*cover.Profile {
+	files := make(map[string]*cover.Profile)
+	blocks := make(map[string]map[coverBlockKey]*cover.ProfileBlock)
+	for _, lst := range lists {
+		for _, p := range lst {
+			merged, ok := files[p.FileName]
+			if !ok {
+				merged = &cover.Profile{FileName: p.FileName, Mode: p.Mode}
+				files[p.FileName] = merged
+				blocks[p.FileName] = make(map[coverBlockKey]*cover.ProfileBlock)
+			}
+			m := blocks[p.FileName]
+			for _, b := range p.Blocks {
+				k := coverBlockKey{b.StartLine, b.StartCol, b.EndLine, b.EndCol}
+				if cur, ok := m[k]; ok {
+					if p.Mode == "set" {
+						cur.Count = max(cur.Count, b.Count)
+					} else {
+						cur.Count += b.Count
+					}
+					continue
+				}
+				cp := b
+				m[k] = &cp
+			}
+		}
+	}
+
+	var ret []*cover.Profile
+	for name, p := range files {
+		for _, b := range blocks[name] {
+			p.Blocks = append(p.Blocks, *b)
+		}
+		sort.Slice(p.Blocks, func(i, j int) bool {
+			bi, bj := p.Blocks[i], p.Blocks[j]
+			if bi.StartLine != bj.StartLine {
+				return bi.StartLine < bj.StartLine
+			}
+			return bi.StartCol < bj.StartCol
+		})
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].FileName < ret[j].FileName
+	})
+	return ret
+}
+
+func writeProfiles(w io.Writer, profiles []*cover.Profile) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", profiles[0].Mode)
+	for _, p := range profiles {
+		for _, b := range p.Blocks {
+			fmt.Fprintf(
+				bw, "%s:%d.%d,%d.%d %d %d\n", p.FileName,
+				b.StartLine, b.StartCol, b.EndLine, b.EndCol,
+				b.NumStmt, b.Count,
+			)
+		}
+	}
+	return bw.Flush()
+}
+
+// coverage counts the covered statements.
+type coverage struct {
+	covered int
+	total   int
+}
+
+func (c *coverage) add(p *cover.Profile) {
+	for _, b := range p.Blocks {
+		c.total += b.NumStmt
+		if b.Count > 0 {
+			c.covered += b.NumStmt
+		}
+	}
+}
+
+func (c *coverage) String() string {
+	if c == nil || c.total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(c.covered)/float64(c.total))
+}
+
+// pkgCoverage sums up the coverage by package import path, and in total.
+func pkgCoverage(profiles []*cover.Profile) (
+	map[string]*coverage, *coverage,
+) {
+	m := make(map[string]*coverage)
+	total := new(coverage)
+	for _, p := range profiles {
+		pkg := path.Dir(p.FileName)
+		c, ok := m[pkg]
+		if !ok {
+			c = new(coverage)
+			m[pkg] = c
+		}
+		c.add(p)
+		total.add(p)
+	}
+	return m, total
+}
+
+// writeCoverReport writes the merged profile into cover.out, and the HTML
+// report into cover.html, in the directory.
+func writeCoverReport(c *context, dir string, profiles []*cover.Profile) (
+	string, error,
+) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	out := filepath.Join(dir, "cover.out")
+	f, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := writeProfiles(f, profiles); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	html := filepath.Join(dir, "cover.html")
+	cmd := exec.Command("go", "tool", "cover", "-html="+out, "-o", html)
+	cmd.Dir = c.workDir()
+	cmd.Env = c.env
+	cmd.Stdout = c.errLog
+	cmd.Stderr = c.errLog
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return html, nil
+}
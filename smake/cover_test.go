@@ -0,0 +1,49 @@
+package smake
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestMergeProfiles(t *testing.T) {
+	const p1 = `mode: count
+x/a/a.go:3.10,5.2 2 1
+x/a/a.go:7.10,9.2 1 0
+x/b/b.go:3.10,5.2 4 0
+`
+	const p2 = `mode: count
+x/a/a.go:3.10,5.2 2 2
+x/a/a.go:7.10,9.2 1 1
+`
+	parse := func(s string) []*cover.Profile {
+		ps, err := cover.ParseProfilesFromReader(strings.NewReader(s))
+		if err != nil {
+			t.Fatal("parse profile: ", err)
+		}
+		return ps
+	}
+
+	merged := mergeProfiles(parse(p1), parse(p2))
+	out := new(strings.Builder)
+	if err := writeProfiles(out, merged); err != nil {
+		t.Fatal("write profiles: ", err)
+	}
+	const want = `mode: count
+x/a/a.go:3.10,5.2 2 3
+x/a/a.go:7.10,9.2 1 1
+x/b/b.go:3.10,5.2 4 0
+`
+	if got := out.String(); got != want {
+		t.Errorf("got merged profile:\n%s\nwant:\n%s", got, want)
+	}
+
+	covers, total := pkgCoverage(merged)
+	if got := covers["x/a"].String(); got != "100.0%" {
+		t.Errorf("got coverage of x/a %s, want 100.0%%", got)
+	}
+	if got := total.String(); got != "42.9%" {
+		t.Errorf("got total coverage %s, want 42.9%%", got)
+	}
+}
@@ -0,0 +1,139 @@
+package smake
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+	"shanhu.io/g/gocheck"
+	"shanhu.io/std/lexing"
+)
+
+func lexingPos(p token.Position) *lexing.Pos {
+	return &lexing.Pos{File: p.Filename, Line: p.Line, Col: p.Column}
+}
+
+// pkgFindings saves the findings of a package.
+type pkgFindings struct {
+	pkg  *relPkg
+	errs []*lexing.Error
+}
+
+func (f *pkgFindings) add(code string, pos *lexing.Pos, err error) {
+	f.errs = append(f.errs, &lexing.Error{Pos: pos, Err: err, Code: code})
+}
+
+func (f *pkgFindings) addLoadErrors(pkg *packages.Package) {
+	for _, err := range pkg.Errors {
+		f.add("load", nil, err)
+	}
+}
+
+// addAction adds the diagnostics of an analyzer action. Diagnostics that
+// are suppressed by "//gocheck:ignore" comments are skipped, where the rule
+// name is the category of the diagnostic, or the name of the analyzer.
+func (f *pkgFindings) addAction(act *checker.Action) {
+	name := act.Analyzer.Name
+	if act.Err != nil {
+		f.add(name, nil, act.Err)
+	}
+	fset := act.Package.Fset
+	var errs []*lexing.Error
+	for _, d := range act.Diagnostics {
+		code := d.Category
+		if code == "" {
+			code = name
+		}
+		errs = append(errs, &lexing.Error{
+			Pos:  lexingPos(fset.Position(d.Pos)),
+			Err:  errors.New(d.Message),
+			Code: code,
+		})
+	}
+	errs = gocheck.FilterIgnored(fset, act.Package.Syntax, errs)
+	f.errs = append(f.errs, errs...)
+}
+
+// counts returns the finding counts by code, like "rect:2 shadow:1".
+func (f *pkgFindings) counts() string {
+	m := make(map[string]int)
+	for _, err := range f.errs {
+		m[err.Code]++
+	}
+	var codes []string
+	for code := range m {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var parts []string
+	for _, code := range codes {
+		parts = append(parts, fmt.Sprintf("%s:%d", code, m[code]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// uniqueErrs removes the duplicate errors, like the load errors that are
+// reported for both a package and its test variant.
+func uniqueErrs(errs []*lexing.Error) []*lexing.Error {
+	seen := make(map[string]bool)
+	var ret []*lexing.Error
+	for _, err := range errs {
+		k := fmt.Sprintf("%s (%s)", err, err.Code)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		ret = append(ret, err)
+	}
+	return ret
+}
+
+func sortErrs(errs []*lexing.Error) {
+	key := func(err *lexing.Error) (string, int, int) {
+		if err.Pos == nil {
+			return "", 0, 0
+		}
+		return err.Pos.File, err.Pos.Line, err.Pos.Col
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		fi, li, ci := key(errs[i])
+		fj, lj, cj := key(errs[j])
+		if fi != fj {
+			return fi < fj
+		}
+		if li != lj {
+			return li < lj
+		}
+		return ci < cj
+	})
+}
+
+// findingsResult is the findings of a package saved in the cache.
+type findingsResult struct {
+	Findings []*gocheck.Finding
+}
+
+func (f *pkgFindings) result() *findingsResult {
+	return &findingsResult{Findings: gocheck.Findings(f.errs)}
+}
+
+func resultFindings(pkg *relPkg, r *findingsResult) *pkgFindings {
+	f := &pkgFindings{pkg: pkg}
+	for _, finding := range r.Findings {
+		var pos *lexing.Pos
+		if finding.File != "" {
+			pos = &lexing.Pos{
+				File: finding.File,
+				Line: finding.Line,
+				Col:  finding.Col,
+			}
+		}
+		f.add(finding.Rule, pos, errors.New(finding.Message))
+	}
+	return f
+}
@@ -0,0 +1,235 @@
+package smake
+
+import (
+	"fmt"
+	"go/build"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"shanhu.io/g/hashutil"
+	"shanhu.io/std/errcode"
+)
+
+// fingerprints computes the fingerprints of the packages in a module. The
+// fingerprint of a package covers its source files, the fingerprints of
+// the packages it imports in the module, and the base fingerprint, which
+// covers go.mod, go.sum, the smake binary and the go environment.
+type fingerprints struct {
+	pkgs  map[string]*build.Package // module packages by import path
+	base  string
+	files map[string]string // file hashes
+	memo  map[string]string // package fingerprints
+}
+
+func newFingerprints(
+	root string, pkgs map[string]*build.Package, env []string,
+) (*fingerprints, error) {
+	f := &fingerprints{
+		pkgs:  pkgs,
+		files: make(map[string]string),
+		memo:  make(map[string]string),
+	}
+
+	lines := []string{"root:" + root}
+	for _, name := range []string{"go.mod", "go.sum"} {
+		h, err := f.hashFile(filepath.Join(root, name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		lines = append(lines, name+":"+h)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, errcode.Annotate(err, "find smake binary")
+	}
+	h, err := f.hashFile(exe)
+	if err != nil {
+		return nil, errcode.Annotate(err, "hash smake binary")
+	}
+	lines = append(lines, "smake:"+h)
+	lines = append(lines, env...)
+	f.base = hashLines(lines)
+	return f, nil
+}
+
+func hashLines(lines []string) string {
+	return hashutil.HashStr(strings.Join(lines, "\n"))
+}
+
+func (f *fingerprints) hashFile(p string) (string, error) {
+	if h, ok := f.files[p]; ok {
+		return h, nil
+	}
+	h, err := hashutil.HashFile(p)
+	if err != nil {
+		return "", err
+	}
+	f.files[p] = h
+	return h, nil
+}
+
+func (f *fingerprints) hashFiles(dir string, names []string) (
+	[]string, error,
+) {
+	var lines []string
+	for _, name := range names {
+		h, err := f.hashFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fmt.Sprintf("file %s:%s", name, h))
+	}
+	return lines, nil
+}
+
+func sourceFiles(pkg *build.Package) []string {
+	var files []string
+	for _, lst := range [][]string{
+		pkg.GoFiles, pkg.CgoFiles, pkg.CFiles, pkg.HFiles, pkg.SFiles,
+	} {
+		files = append(files, lst...)
+	}
+	return files
+}
+
+// embedFiles returns the files that the embed patterns match. Matched
+// directories are listed recursively.
+func embedFiles(pkg *build.Package) ([]string, error) {
+	var ret []string
+	for _, pattern := range pkg.EmbedPatterns {
+		matches, err := filepath.Glob(filepath.Join(pkg.Dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			rel, err := filepath.Rel(pkg.Dir, m)
+			if err != nil {
+				return nil, err
+			}
+			files, err := treeFiles(pkg.Dir, rel)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, files...)
+		}
+	}
+	return ret, nil
+}
+
+// importFingerprints returns the fingerprints of the imported packages in
+// the module.
+func (f *fingerprints) importFingerprints(imports []string) (
+	[]string, error,
+) {
+	var lines []string
+	for _, imp := range imports {
+		if _, ok := f.pkgs[imp]; !ok {
+			continue
+		}
+		h, err := f.pkg(imp)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fmt.Sprintf("import %s:%s", imp, h))
+	}
+	return lines, nil
+}
+
+// pkg returns the fingerprint of a package.
+func (f *fingerprints) pkg(p string) (string, error) {
+	if h, ok := f.memo[p]; ok {
+		return h, nil
+	}
+	pkg, ok := f.pkgs[p]
+	if !ok {
+		return "", errcode.NotFoundf("package %q not in module", p)
+	}
+
+	lines := []string{"base:" + f.base, "package:" + p}
+	srcs, err := f.hashFiles(pkg.Dir, sourceFiles(pkg))
+	if err != nil {
+		return "", err
+	}
+	lines = append(lines, srcs...)
+	embeds, err := embedFiles(pkg)
+	if err != nil {
+		return "", err
+	}
+	embedHashes, err := f.hashFiles(pkg.Dir, embeds)
+	if err != nil {
+		return "", err
+	}
+	lines = append(lines, embedHashes...)
+	imports, err := f.importFingerprints(pkg.Imports)
+	if err != nil {
+		return "", err
+	}
+	lines = append(lines, imports...)
+
+	h := hashLines(lines)
+	f.memo[p] = h
+	return h, nil
+}
+
+// treeFiles lists the regular files under a file tree in dir, relative
+// to dir. It returns nil if the tree does not exist.
+func treeFiles(dir, tree string) ([]string, error) {
+	var ret []string
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			ret = append(ret, rel)
+		}
+		return nil
+	}
+	err := filepath.WalkDir(filepath.Join(dir, tree), walk)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// test returns the fingerprint of the tests of a package. It also covers
+// the test files, the testdata directory and the test imports.
+func (f *fingerprints) test(p string) (string, error) {
+	h, err := f.pkg(p)
+	if err != nil {
+		return "", err
+	}
+	pkg := f.pkgs[p]
+
+	lines := []string{"package:" + h}
+	var files []string
+	files = append(files, pkg.TestGoFiles...)
+	files = append(files, pkg.XTestGoFiles...)
+	testdata, err := treeFiles(pkg.Dir, "testdata")
+	if err != nil {
+		return "", err
+	}
+	files = append(files, testdata...)
+	hashes, err := f.hashFiles(pkg.Dir, files)
+	if err != nil {
+		return "", err
+	}
+	lines = append(lines, hashes...)
+
+	var imports []string
+	imports = append(imports, pkg.TestImports...)
+	imports = append(imports, pkg.XTestImports...)
+	importHashes, err := f.importFingerprints(imports)
+	if err != nil {
+		return "", err
+	}
+	lines = append(lines, importHashes...)
+	return hashLines(lines), nil
+}
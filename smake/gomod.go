@@ -3,6 +3,7 @@ package smake
 import (
 	"path/filepath"
 
+	"shanhu.io/g/gomod"
 	"shanhu.io/g/osutil"
 	"shanhu.io/std/errcode"
 )
@@ -29,3 +30,12 @@ func findGoModuleRoot(d string) (string, error) {
 
 	return "", errcode.NotFoundf("go module not found for dir %q", d)
 }
+
+func parseGoMod(c *context) (*gomod.File, error) {
+	modFile := filepath.Join(c.modRootDir(), "go.mod")
+	mod, err := gomod.Parse(modFile)
+	if err != nil {
+		return nil, errcode.Annotate(err, "parse go.mod")
+	}
+	return mod, nil
+}
@@ -0,0 +1,46 @@
+package smake
+
+import (
+	"go/build"
+	"path/filepath"
+
+	"shanhu.io/g/golayer"
+	"shanhu.io/g/osutil"
+	"shanhu.io/std/errcode"
+)
+
+// checkLayers checks the imports of the packages with the layering rules
+// at the module root, prints and records the violating imports, and
+// returns how many there are. It does nothing if there is no rules file.
+func checkLayers(c *context, pkgs []*relPkg) (int, error) {
+	f := filepath.Join(c.modRootDir(), golayer.RulesFile)
+	ok, err := osutil.IsRegular(f)
+	if err != nil {
+		return 0, errcode.Annotate(err, "check layering rules file")
+	}
+	if !ok {
+		return 0, nil
+	}
+	c.logln("layers")
+
+	rules, err := golayer.ReadRules(f)
+	if err != nil {
+		return 0, err
+	}
+	mod, err := parseGoMod(c)
+	if err != nil {
+		return 0, err
+	}
+	m := make(map[string]*build.Package)
+	for _, pkg := range pkgs {
+		m[pkg.abs] = pkg.pkg
+	}
+
+	errs := golayer.Check(mod.Name, m, rules)
+	for _, err := range errs {
+		c.logf("%s (%s)\n", err, err.Code)
+	}
+	c.res.analyzed = true
+	c.res.findings = append(c.res.findings, errs...)
+	return len(errs), nil
+}
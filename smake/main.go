@@ -30,12 +30,13 @@ func usingGoMod() bool {
 	return strings.ToLower(v) != "off"
 }
 
-func run(dir string) error {
+func run(opts *options) error {
 	mod := usingGoMod()
 	if !mod {
 		return errcode.Internalf("must use go module")
 	}
 
+	dir := opts.dir
 	if dir == "" {
 		wd, err := workDir()
 		if err != nil {
@@ -50,6 +51,14 @@ func run(dir string) error {
 		dir = abs
 	}
 
+	gopath, err := absGOPATH()
+	if err != nil {
+		return err
+	}
+	if opts.workspace {
+		return runWorkspace(dir, gopath, opts)
+	}
+
 	modRoot, err := findGoModuleRoot(dir)
 	if err != nil {
 		return errcode.Annotate(err, "find module root")
@@ -57,25 +66,63 @@ func run(dir string) error {
 
 	// This is to make sure that we run under the absolute directory path.
 	// Otherwise, some go tools will fail to recognize the directory structure.
-	if err := os.Chdir(dir); err != nil {
+	if err = os.Chdir(dir); err != nil {
 		return err
 	}
 
-	gopath, err := absGOPATH()
-	if err != nil {
-		return err
+	c := newContext(gopath, modRoot, dir, opts)
+	runErr := smake(c)
+	if err := writeReport(opts.report, modRoot, []*result{c.res}); err != nil {
+		return errcode.Annotate(err, "write report")
 	}
+	return runErr
+}
 
-	c := newContext(gopath, modRoot, dir)
-	return smake(c)
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "smake")
 }
 
 // Main is the entry point for smake.
 func Main() {
-	workDir := flag.String("dir", "", "work directory")
+	opts := new(options)
+	flag.StringVar(&opts.dir, "dir", "", "work directory")
+	flag.StringVar(
+		&opts.report, "report", "",
+		"file to write the analysis findings, in SARIF format if the "+
+			"name ends with .sarif, or JSON format otherwise",
+	)
+	flag.BoolVar(&opts.test, "test", false, "run tests")
+	flag.BoolVar(&opts.race, "race", false, "run tests with -race")
+	flag.BoolVar(&opts.short, "short", false, "run tests with -short")
+	flag.StringVar(
+		&opts.coverDir, "cover", "",
+		"directory to write the merged coverage profile and HTML report",
+	)
+	flag.StringVar(
+		&opts.changedSince, "changed", "",
+		"only test packages affected by files changed since the git "+
+			"revision",
+	)
+	flag.StringVar(
+		&opts.cacheDir, "cache", defaultCacheDir(),
+		"directory to save the stage results; empty to disable caching",
+	)
+	flag.BoolVar(
+		&opts.force, "force", false,
+		"run all the stages without reading the cache",
+	)
+	flag.BoolVar(
+		&opts.workspace, "workspace", false,
+		"run over all the modules in the go.work workspace, or under the "+
+			"work directory if there is no go.work file",
+	)
 	flag.Parse()
 
-	if err := run(*workDir); err != nil {
+	if err := run(opts); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
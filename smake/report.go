@@ -0,0 +1,46 @@
+package smake
+
+import (
+	"os"
+	"strings"
+
+	"shanhu.io/g/gocheck"
+	"shanhu.io/std/lexing"
+)
+
+// writeReport writes the findings of the analyzed modules into the report
+// file, in SARIF format when the file name ends with ".sarif", and in JSON
+// format otherwise. File paths in SARIF are relative to root. It writes
+// nothing if no module is analyzed.
+func writeReport(file, root string, results []*result) error {
+	if file == "" {
+		return nil
+	}
+	analyzed := false
+	var errs []*lexing.Error
+	for _, r := range results {
+		if r.analyzed {
+			analyzed = true
+			errs = append(errs, r.findings...)
+		}
+	}
+	if !analyzed {
+		return nil
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(file, ".sarif") {
+		err = gocheck.WriteSARIF(f, errs, root)
+	} else {
+		err = gocheck.WriteJSON(f, errs)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
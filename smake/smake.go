@@ -2,72 +2,17 @@ package smake
 
 import (
 	"fmt"
+	"go/build"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
 
-	lintpkg "golang.org/x/lint"
-	"shanhu.io/g/gocheck"
 	"shanhu.io/g/goload"
-	"shanhu.io/g/gomod"
 	"shanhu.io/g/gotags"
 	"shanhu.io/std/errcode"
-	"shanhu.io/std/lexing"
 )
 
-func smlchkPkg(c *context, pkg *relPkg) []*lexing.Error {
-	const textHeight = 320 // 20 lines for license notice.
-	const textWidth = 80
-
-	dir := filepath.Join(c.workDir(), filepath.FromSlash(pkg.rel))
-	return gocheck.ModCheckAll(dir, pkg.abs, textHeight, textWidth)
-}
-
-func smlchk(c *context, pkgs []*relPkg) error {
-	c.logln("smlchk")
-
-	for _, pkg := range pkgs {
-		if errs := smlchkPkg(c, pkg); len(errs) != 0 {
-			for _, err := range errs {
-				c.logln(err)
-			}
-			return fmt.Errorf("smlchk %q failed", pkg.rel)
-		}
-	}
-	return nil
-}
-
-func lint(c *context, pkgs []*relPkg) error {
-	c.logln("lint")
-
-	const minConfidence = 0.8
-	for _, pkg := range pkgs {
-		files, err := fileSourceMap(pkg)
-		if err != nil {
-			return err
-		}
-
-		l := new(lintpkg.Linter)
-		ps, err := l.LintFiles(files)
-		if err != nil {
-			return err
-		}
-
-		errCount := 0
-		for _, p := range ps {
-			if p.Confidence < minConfidence {
-				continue
-			}
-			c.logf("%v: %s\n", p.Position, p.Text)
-			errCount++
-		}
-
-		if errCount > 0 {
-			return fmt.Errorf("lint %q failed", pkg.rel)
-		}
-	}
-	return nil
-}
-
 func tags(c *context, pkgs []*relPkg) error {
 	if !c.atModRoot() {
 		return nil
@@ -86,10 +31,9 @@ func listPkgs(c *context) ([]*relPkg, error) {
 	root := c.modRootDir()
 	workDir := c.workDir()
 
-	modFile := filepath.Join(root, "go.mod")
-	mod, err := gomod.Parse(modFile)
+	mod, err := parseGoMod(c)
 	if err != nil {
-		return nil, errcode.Annotate(err, "parse go.mod")
+		return nil, err
 	}
 
 	relPath, err := filepath.Rel(root, workDir)
@@ -107,16 +51,83 @@ func listPkgs(c *context) ([]*relPkg, error) {
 	return relPkgs(workPkg, scanRes)
 }
 
+// goEnvVars are the go environment variables that change the results of
+// building and testing. Build tags are set with -tags in GOFLAGS.
+var goEnvVars = []string{
+	"GOVERSION", "GOOS", "GOARCH", "GOFLAGS", "GOEXPERIMENT", "CGO_ENABLED",
+}
+
+// goEnv returns the go environment that the go commands of smake run in,
+// as lines for fingerprinting.
+func goEnv(c *context) ([]string, error) {
+	cmd := exec.Command("go", append([]string{"env"}, goEnvVars...)...)
+	cmd.Dir = c.workDir()
+	cmd.Env = c.env
+	cmd.Stderr = c.errLog
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errcode.Annotate(err, "go env")
+	}
+	values := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(values) != len(goEnvVars) {
+		return nil, errcode.Internalf(
+			"go env got %d values, want %d", len(values), len(goEnvVars),
+		)
+	}
+	var lines []string
+	for i, v := range goEnvVars {
+		lines = append(lines, fmt.Sprintf("env %s=%s", v, values[i]))
+	}
+	return lines, nil
+}
+
+// openCache scans all the packages in the module for fingerprinting, and
+// opens the cache.
+func openCache(c *context) (*buildCache, error) {
+	mod, err := parseGoMod(c)
+	if err != nil {
+		return nil, err
+	}
+	root := c.modRootDir()
+	scanRes, err := goload.ScanModPkgs(mod.Name, root, nil)
+	if err != nil {
+		return nil, errcode.Annotate(err, "scan module packages")
+	}
+	pkgs := make(map[string]*build.Package)
+	for p, pkg := range scanRes.Pkgs {
+		pkgs[p] = pkg.Build
+	}
+	env, err := goEnv(c)
+	if err != nil {
+		return nil, err
+	}
+	fps, err := newFingerprints(root, pkgs, env)
+	if err != nil {
+		return nil, errcode.Annotate(err, "fingerprint")
+	}
+	return newBuildCache(c.opts.cacheDir, c.opts.force, fps), nil
+}
+
 func smake(c *context) error {
 	pkgs, err := listPkgs(c)
 	if err != nil {
 		return errcode.Annotate(err, "list packages")
 	}
 
+	if c.opts.cacheDir != "" {
+		cache, err := openCache(c)
+		if err != nil {
+			return errcode.Annotate(err, "open cache")
+		}
+		c.cache = cache
+		defer cache.printSummary(c.errLog)
+	}
+
 	if len(pkgs) == 0 {
 		c.logln("no packages found")
 		return nil
 	}
+	c.res.pkgs = len(pkgs)
 
 	installCmd := []string{"go", "install", "-buildvcs=false", "-trimpath"}
 
@@ -129,17 +140,22 @@ func smake(c *context) error {
 		return err
 	}
 
-	if err := smlchk(c, pkgs); err != nil {
+	// Layer violations do not stop the analyzers, so that all the findings
+	// are reported in one run.
+	nlayer, err := checkLayers(c, pkgs)
+	if err != nil {
 		return err
 	}
-	if err := lint(c, pkgs); err != nil {
+	if err := analyze(c, pkgs); err != nil {
 		return err
 	}
-
-	if err := c.execPkgs(pkgs, []string{
-		"go", "vet",
-	}, nil); err != nil {
-		return err
+	if nlayer > 0 {
+		return fmt.Errorf("layers: %d violating imports", nlayer)
+	}
+	if c.opts.test {
+		if err := runTests(c, pkgs); err != nil {
+			return err
+		}
 	}
 
 	return tags(c, pkgs)
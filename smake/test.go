@@ -0,0 +1,297 @@
+package smake
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/tools/cover"
+	"shanhu.io/std/errcode"
+)
+
+// testEvent is an event in the output of "go test -json". Build events
+// have ImportPath instead of Package.
+type testEvent struct {
+	Action     string
+	Package    string
+	ImportPath string
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+// testedPkg returns the path of the package that a build event is for. The
+// import path of a build event is like "x [x.test]", or "x_test [x.test]"
+// for an external test package, where x is the package under test.
+func testedPkg(importPath string) string {
+	p, variant, ok := strings.Cut(importPath, " [")
+	if !ok {
+		return p
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(variant, "]"), ".test")
+}
+
+// pkgTestResult is the test result of a package. The exported fields are
+// saved in the cache.
+type pkgTestResult struct {
+	Action  string // "pass", "fail" or "skip"; empty if not tested
+	Elapsed float64
+
+	Pass int
+	Fail int
+	Skip int
+
+	Profiles []*cover.Profile `json:",omitempty"` // coverage
+
+	failed []string            // names of the failed tests
+	output map[string][]string // output lines by test name
+}
+
+func newPkgTestResult() *pkgTestResult {
+	return &pkgTestResult{output: make(map[string][]string)}
+}
+
+func (r *pkgTestResult) add(e *testEvent) {
+	switch e.Action {
+	case "output", "build-output":
+		r.output[e.Test] = append(r.output[e.Test], e.Output)
+		return
+	case "pass", "fail", "skip":
+	default:
+		return
+	}
+
+	if e.Test == "" {
+		r.Action = e.Action
+		r.Elapsed = e.Elapsed
+		return
+	}
+	switch e.Action {
+	case "pass":
+		r.Pass++
+	case "fail":
+		r.Fail++
+		r.failed = append(r.failed, e.Test)
+	case "skip":
+		r.Skip++
+	}
+}
+
+// printFailures prints the output of the failed tests. When the package
+// fails without any failed test, like on a build error or a panic, it
+// prints the output of the package instead.
+func (r *pkgTestResult) printFailures(w io.Writer) {
+	if r.Action != "fail" {
+		return
+	}
+	tests := r.failed
+	if len(tests) == 0 {
+		tests = []string{""}
+	}
+	for _, test := range tests {
+		for _, line := range r.output[test] {
+			io.WriteString(w, line)
+		}
+	}
+}
+
+// parseTestEvents parses the output of "go test -json", and returns the
+// results by package. The output of build events is saved as the output of
+// the package under test. Lines that are not JSON, like the build errors of
+// older go versions, are written to w.
+func parseTestEvents(r io.Reader, w io.Writer) (
+	map[string]*pkgTestResult, error,
+) {
+	results := make(map[string]*pkgTestResult)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<24)
+	for s.Scan() {
+		line := s.Bytes()
+		if !bytes.HasPrefix(line, []byte("{")) {
+			fmt.Fprintf(w, "%s\n", line)
+			continue
+		}
+		e := new(testEvent)
+		if err := json.Unmarshal(line, e); err != nil {
+			return nil, errcode.Annotate(err, "parse test event")
+		}
+		pkg := e.Package
+		if pkg == "" {
+			pkg = testedPkg(e.ImportPath)
+		}
+		if pkg == "" {
+			continue
+		}
+		res, ok := results[pkg]
+		if !ok {
+			res = newPkgTestResult()
+			results[pkg] = res
+		}
+		res.add(e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func printTestSummary(
+	c *context, pkgs []*relPkg, results []*pkgTestResult,
+	covers map[string]*coverage,
+) {
+	w := tabwriter.NewWriter(c.errLog, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tRESULT\tPASS\tFAIL\tSKIP\tCOVER\tTIME")
+	for i, pkg := range pkgs {
+		r := results[i]
+		if r.Action == "" {
+			continue
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%d\t%d\t%d\t%s\t%.2fs\n",
+			pkg.rel, r.Action, r.Pass, r.Fail, r.Skip,
+			covers[pkg.abs], r.Elapsed,
+		)
+	}
+	w.Flush()
+}
+
+func testFlags(c *context) []string {
+	var flags []string
+	if c.opts.race {
+		flags = append(flags, "-race")
+	}
+	if c.opts.short {
+		flags = append(flags, "-short")
+	}
+	return flags
+}
+
+// goTest runs the tests of the packages, prints the failures, and returns
+// the results in the order of pkgs.
+func goTest(c *context, pkgs []*relPkg) ([]*pkgTestResult, error) {
+	tmp, err := os.MkdirTemp("", "smake-cover")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	profile := filepath.Join(tmp, "cover.out")
+
+	args := []string{"go", "test", "-json"}
+	args = append(args, testFlags(c)...)
+	args = append(args, "-coverprofile="+profile)
+	out := new(bytes.Buffer)
+	runErr := c.execPkgs(pkgs, args, &execConfig{Stdout: out})
+
+	results, err := parseTestEvents(out, c.errLog)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string][]*cover.Profile)
+	if _, statErr := os.Stat(profile); statErr == nil {
+		ps, err := cover.ParseProfiles(profile)
+		if err != nil {
+			return nil, errcode.Annotate(err, "parse coverage profile")
+		}
+		for _, p := range ps {
+			pkg := path.Dir(p.FileName)
+			profiles[pkg] = append(profiles[pkg], p)
+		}
+	}
+
+	var ret []*pkgTestResult
+	failed := false
+	for _, pkg := range pkgs {
+		r, ok := results[pkg.abs]
+		if !ok {
+			r = newPkgTestResult()
+		}
+		r.printFailures(c.errLog)
+		if r.Action == "fail" {
+			failed = true
+		}
+		r.Profiles = profiles[pkg.abs]
+		ret = append(ret, r)
+	}
+	if runErr != nil && !failed {
+		return nil, errcode.Annotate(runErr, "go test")
+	}
+	return ret, nil
+}
+
+// cachedGoTest is like goTest, but only tests the packages that miss the
+// cache. Only passed and skipped results are saved.
+func cachedGoTest(c *context, pkgs []*relPkg) ([]*pkgTestResult, error) {
+	key := func(pkg *relPkg) (string, error) {
+		fp, err := c.cache.fps.test(pkg.abs)
+		if err != nil {
+			return "", err
+		}
+		return hashLines(append([]string{fp}, testFlags(c)...)), nil
+	}
+	run := func(pkgs []*relPkg) ([]*pkgTestResult, error) {
+		return goTest(c, pkgs)
+	}
+	keep := func(r *pkgTestResult) bool {
+		return r.Action == "pass" || r.Action == "skip"
+	}
+	return cachedRun(c.cache, "test", pkgs, key, run, keep)
+}
+
+// runTests runs the tests of the packages, prints the failures and a
+// summary table with the coverage, and fails if any package fails.
+func runTests(c *context, pkgs []*relPkg) error {
+	c.logln("test")
+
+	if since := c.opts.changedSince; since != "" {
+		files, err := changedFiles(c, since)
+		if err != nil {
+			return err
+		}
+		pkgs = affectedPkgs(pkgs, c.workDir(), files)
+		if len(pkgs) == 0 {
+			c.logln("no packages affected")
+			return nil
+		}
+	}
+
+	results, err := cachedGoTest(c, pkgs)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	var profiles []*cover.Profile
+	for i, r := range results {
+		if r.Action == "fail" {
+			failed = append(failed, pkgs[i].rel)
+		}
+		profiles = append(profiles, r.Profiles...)
+	}
+	profiles = mergeProfiles(profiles)
+	covers, total := pkgCoverage(profiles)
+	printTestSummary(c, pkgs, results, covers)
+	c.logf("total coverage: %s of statements\n", total)
+
+	if dir := c.opts.coverDir; dir != "" && len(profiles) > 0 {
+		html, err := writeCoverReport(c, dir, profiles)
+		if err != nil {
+			return errcode.Annotate(err, "write coverage report")
+		}
+		c.logf("coverage report: %s\n", html)
+	}
+
+	c.res.testFail = len(failed)
+	if len(failed) > 0 {
+		return fmt.Errorf(
+			"test: %d packages failed: %s",
+			len(failed), strings.Join(failed, " "),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package smake
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseTestEvents(t *testing.T) {
+	const out = `{"Action":"run","Package":"x/a","Test":"TestA"}
+{"Action":"output","Package":"x/a","Test":"TestA","Output":"a.go:3: bad\n"}
+{"Action":"fail","Package":"x/a","Test":"TestA","Elapsed":0.1}
+{"Action":"pass","Package":"x/a","Test":"TestB","Elapsed":0.1}
+{"Action":"skip","Package":"x/a","Test":"TestC"}
+{"Action":"fail","Package":"x/a","Elapsed":0.3}
+{"Action":"skip","Package":"x/b","Elapsed":0}
+`
+	results, err := parseTestEvents(strings.NewReader(out), io.Discard)
+	if err != nil {
+		t.Fatal("parse: ", err)
+	}
+
+	a := results["x/a"]
+	if a == nil {
+		t.Fatal("missing result of x/a")
+	}
+	if a.Action != "fail" || a.Pass != 1 || a.Fail != 1 || a.Skip != 1 {
+		t.Errorf("got result of x/a: %+v", a)
+	}
+	failures := new(strings.Builder)
+	a.printFailures(failures)
+	if got := failures.String(); got != "a.go:3: bad\n" {
+		t.Errorf("got failures %q", got)
+	}
+
+	if b := results["x/b"]; b == nil || b.Action != "skip" {
+		t.Errorf("got result of x/b: %+v", b)
+	}
+}
+
+func TestParseTestEventsBuildFail(t *testing.T) {
+	const out = `{"ImportPath":"a [a.test]","Action":"build-output","Output":"# a [a.test]\n"}
+{"ImportPath":"a_test [a.test]","Action":"build-output","Output":"a_test.go:3: bad\n"}
+{"ImportPath":"a [a.test]","Action":"build-fail"}
+{"Action":"start","Package":"a"}
+{"Action":"fail","Package":"a","Elapsed":0}
+`
+	results, err := parseTestEvents(strings.NewReader(out), io.Discard)
+	if err != nil {
+		t.Fatal("parse: ", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("got %d results, want 1", len(results))
+	}
+	a := results["a"]
+	if a == nil || a.Action != "fail" {
+		t.Fatalf("got result of a: %+v", a)
+	}
+	failures := new(strings.Builder)
+	a.printFailures(failures)
+	want := "# a [a.test]\na_test.go:3: bad\n"
+	if got := failures.String(); got != want {
+		t.Errorf("got failures %q, want %q", got, want)
+	}
+}
@@ -1,9 +1,7 @@
 package smake
 
 import (
-	"fmt"
 	"go/build"
-	"os"
 	"path/filepath"
 )
 
@@ -22,19 +20,3 @@ func listAbsFiles(pkg *build.Package) []string {
 	}
 	return files
 }
-
-func fileSourceMap(pkg *relPkg) (map[string][]byte, error) {
-	files := listFiles(pkg.pkg)
-	fileMap := make(map[string][]byte)
-
-	for _, f := range files {
-		path := filepath.Join(pkg.pkg.Dir, f)
-		src, err := os.ReadFile(path)
-		if err != nil {
-			return nil, fmt.Errorf("read %q: %s", path, err)
-		}
-		fileMap[path] = src
-	}
-
-	return fileMap, nil
-}
@@ -0,0 +1,241 @@
+package smake
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"shanhu.io/g/dags"
+	"shanhu.io/g/gomod"
+	"shanhu.io/std/errcode"
+)
+
+// module is a Go module in a workspace.
+type module struct {
+	dir string
+	mod *gomod.File
+}
+
+// walkModules finds all the modules under dir. It skips vendor and testdata
+// directories, and directories that start with "." or "_".
+func walkModules(dir string) ([]*module, error) {
+	var ret []*module
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p == dir {
+				return nil
+			}
+			if name == "vendor" || name == "testdata" ||
+				strings.HasPrefix(name, ".") ||
+				strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "go.mod" || !d.Type().IsRegular() {
+			return nil
+		}
+		mod, err := gomod.Parse(p)
+		if err != nil {
+			return errcode.Annotatef(err, "parse %q", p)
+		}
+		ret = append(ret, &module{dir: filepath.Dir(p), mod: mod})
+		return nil
+	}
+	if err := filepath.WalkDir(dir, walk); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// findModules finds the modules in the go.work workspace of dir, or all
+// the modules under dir when there is no go.work file. It returns the root
+// directory of the workspace and the modules.
+func findModules(dir string) (string, []*module, error) {
+	workFile, err := gomod.FindWork(dir)
+	if err != nil {
+		return "", nil, errcode.Annotate(err, "find go.work")
+	}
+	if workFile == "" {
+		mods, walkErr := walkModules(dir)
+		if walkErr != nil {
+			return "", nil, errcode.Annotate(walkErr, "find modules")
+		}
+		return dir, mods, nil
+	}
+
+	work, err := gomod.ParseWork(workFile)
+	if err != nil {
+		return "", nil, errcode.Annotate(err, "parse go.work")
+	}
+	dirs, err := work.UseDirs()
+	if err != nil {
+		return "", nil, err
+	}
+	files, err := work.Modules()
+	if err != nil {
+		return "", nil, err
+	}
+	var mods []*module
+	for i, f := range files {
+		mods = append(mods, &module{dir: dirs[i], mod: f})
+	}
+	return work.Dir(), mods, nil
+}
+
+// sortModules sorts the modules in dependency order, where the modules come
+// after the modules they require.
+func sortModules(mods []*module) ([]*module, error) {
+	byName := make(map[string]*module)
+	for _, m := range mods {
+		name := m.mod.Name
+		if other, ok := byName[name]; ok {
+			return nil, errcode.InvalidArgf(
+				"module %q in both %q and %q", name, other.dir, m.dir,
+			)
+		}
+		byName[name] = m
+	}
+
+	nodes := make(map[string][]string)
+	for _, m := range mods {
+		name := m.mod.Name
+		if _, ok := nodes[name]; !ok {
+			nodes[name] = nil
+		}
+		for _, r := range m.mod.Require {
+			if _, ok := byName[r.Path]; ok {
+				nodes[r.Path] = append(nodes[r.Path], name)
+			}
+		}
+	}
+	order, err := dags.TopoSort(dags.NewGraph(nodes))
+	if err != nil {
+		return nil, errcode.Annotate(err, "sort modules")
+	}
+
+	var ret []*module
+	for _, name := range order {
+		ret = append(ret, byName[name])
+	}
+	return ret, nil
+}
+
+// moduleRun is the run of smake on a module in a workspace.
+type moduleRun struct {
+	module  *module
+	rel     string // module directory relative to the workspace
+	res     *result
+	err     error
+	elapsed time.Duration
+}
+
+func printWorkspaceSummary(w io.Writer, runs []*moduleRun) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(
+		tw, "MODULE\tDIR\tPACKAGES\tFINDINGS\tTEST FAILS\tRESULT\tTIME",
+	)
+	for _, r := range runs {
+		res := "ok"
+		if r.err != nil {
+			res = "FAIL"
+		}
+		fmt.Fprintf(
+			tw, "%s\t%s\t%d\t%d\t%d\t%s\t%.1fs\n",
+			r.module.mod.Name, r.rel, r.res.pkgs, len(r.res.findings),
+			r.res.testFail, res, r.elapsed.Seconds(),
+		)
+	}
+	tw.Flush()
+
+	for _, r := range runs {
+		if r.err != nil {
+			fmt.Fprintf(w, "%s: %s\n", r.module.mod.Name, r.err)
+		}
+	}
+}
+
+// runWorkspace runs smake on all the modules in the workspace of dir, in
+// dependency order. It continues on failures, and fails if any module
+// fails.
+func runWorkspace(dir, gopath string, opts *options) error {
+	root, mods, err := findModules(dir)
+	if err != nil {
+		return err
+	}
+	if len(mods) == 0 {
+		return errcode.NotFoundf("no modules found in %q", dir)
+	}
+	mods, err = sortModules(mods)
+	if err != nil {
+		return err
+	}
+
+	// Modules run in their own directories, so relative output paths are
+	// resolved against the current directory first.
+	absOpts := *opts
+	for _, p := range []*string{&absOpts.report, &absOpts.coverDir} {
+		if *p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*p)
+		if err != nil {
+			return err
+		}
+		*p = abs
+	}
+	opts = &absOpts
+
+	var runs []*moduleRun
+	var results []*result
+	failed := 0
+	for _, m := range mods {
+		rel, err := filepath.Rel(root, m.dir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "== %s (%s)\n", m.mod.Name, rel)
+
+		modOpts := *opts
+		modOpts.dir = m.dir
+		if opts.coverDir != "" {
+			modOpts.coverDir = filepath.Join(opts.coverDir, rel)
+		}
+		c := newContext(gopath, m.dir, m.dir, &modOpts)
+
+		start := time.Now()
+		runErr := os.Chdir(m.dir)
+		if runErr == nil {
+			runErr = smake(c)
+		}
+		if runErr != nil {
+			failed++
+		}
+		runs = append(runs, &moduleRun{
+			module:  m,
+			rel:     rel,
+			res:     c.res,
+			err:     runErr,
+			elapsed: time.Since(start),
+		})
+		results = append(results, c.res)
+	}
+	printWorkspaceSummary(os.Stderr, runs)
+
+	if err := writeReport(opts.report, root, results); err != nil {
+		return errcode.Annotate(err, "write report")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d modules failed", failed, len(mods))
+	}
+	return nil
+}
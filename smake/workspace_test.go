@@ -0,0 +1,73 @@
+package smake
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindModules(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":             "module x\nrequire x/b v0.0.0\n",
+		"b/go.mod":           "module x/b\nrequire x/c v0.0.0\n",
+		"c/go.mod":           "module x/c\n",
+		"d/go.mod":           "module x/d\nrequire x/c v0.0.0\n",
+		"c/testdata/go.mod":  "module x/c/testdata\n",
+		".hidden/e/go.mod":   "module x/e\n",
+		"d/vendor/f/go.mod":  "module x/f\n",
+		"_skipped/g/go.mod":  "module x/g\n",
+		"c/internal/h/h.txt": "not a module",
+	} {
+		f := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root, mods, err := findModules(dir)
+	if err != nil {
+		t.Fatal("find modules: ", err)
+	}
+	if root != dir {
+		t.Errorf("got workspace root %q, want %q", root, dir)
+	}
+	mods, err = sortModules(mods)
+	if err != nil {
+		t.Fatal("sort modules: ", err)
+	}
+	var names []string
+	for _, m := range mods {
+		names = append(names, m.mod.Name)
+	}
+	want := []string{"x/c", "x/b", "x/d", "x"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got modules %q, want %q", names, want)
+	}
+
+	work := "go 1.26\nuse (\n\t./b\n\t./c\n)\n"
+	workFile := filepath.Join(dir, "go.work")
+	if err := os.WriteFile(workFile, []byte(work), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, mods, err = findModules(filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatal("find modules in workspace: ", err)
+	}
+	mods, err = sortModules(mods)
+	if err != nil {
+		t.Fatal("sort modules in workspace: ", err)
+	}
+	names = nil
+	for _, m := range mods {
+		names = append(names, m.mod.Name)
+	}
+	want = []string{"x/c", "x/b"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got workspace modules %q, want %q", names, want)
+	}
+}
@@ -1,9 +1,12 @@
 package unixhttp
 
 import (
+	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"shanhu.io/g/osutil"
 )
@@ -30,6 +33,65 @@ func Listen(p string) (net.Listener, error) {
 	return lis, nil
 }
 
+// privateListener is a listener whose socket was moved to path after it
+// was created, so it removes the socket at path by itself on closing.
+type privateListener struct {
+	*net.UnixListener
+	path string
+	once sync.Once
+}
+
+func (l *privateListener) Close() error {
+	err := l.UnixListener.Close()
+	l.once.Do(func() { os.Remove(l.path) })
+	return err
+}
+
+// ListenPrivate is like Listen, but the socket is only accessible by the
+// current user. The socket is created in a private directory and then moved
+// to p, so that it is never accessible by others. It fails if p is a socket
+// that another server is still listening on. The socket is removed when the
+// listener is closed.
+func ListenPrivate(p string) (net.Listener, error) {
+	if exist, err := osutil.Exist(p); err != nil {
+		return nil, err
+	} else if exist {
+		isSock, err := osutil.IsSock(p)
+		if err != nil {
+			return nil, err
+		}
+		if !isSock {
+			return nil, fmt.Errorf("%q exists and is not a socket", p)
+		}
+		if conn, err := net.Dial("unix", p); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%q is in use", p)
+		}
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(p), ".sock-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "sock")
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp, 0600); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	lis.SetUnlinkOnClose(false)
+	return &privateListener{UnixListener: lis, path: p}, nil
+}
+
 // ListenAndServe listens and serves at the given unix domain socket
 // path.
 func ListenAndServe(p string, h http.Handler) error {